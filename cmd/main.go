@@ -1,17 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 
 	httphandler "github.com/tartine-studio/harmony-server/internal/adapter/http"
+	authmw "github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/adapter/ldap"
+	"github.com/tartine-studio/harmony-server/internal/adapter/mail"
+	"github.com/tartine-studio/harmony-server/internal/adapter/oidc"
+	"github.com/tartine-studio/harmony-server/internal/adapter/passkey"
+	"github.com/tartine-studio/harmony-server/internal/adapter/ratelimit"
 	"github.com/tartine-studio/harmony-server/internal/adapter/repository"
 	"github.com/tartine-studio/harmony-server/internal/adapter/token"
 	"github.com/tartine-studio/harmony-server/internal/application"
 	"github.com/tartine-studio/harmony-server/internal/config"
+	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
 func main() {
@@ -29,23 +40,334 @@ func main() {
 	}
 	defer db.Close()
 
-	jwtSvc := token.NewJwtService(cfg.JWTSecret, cfg.JWTAccessTTL, cfg.JWTRefreshTTL)
+	ctx := context.Background()
+
+	mailer, err := newMailer(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to set up mailer", zap.Error(err))
+	}
+
+	var rateLimitStore domain.RateLimitStore
+	switch cfg.RateLimitStore {
+	case config.RateLimitStoreRedis:
+		opts, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			logger.Fatal("invalid redis url", zap.Error(err))
+		}
+		client := redis.NewClient(opts)
+		defer client.Close()
+		if err := client.Ping(ctx).Err(); err != nil {
+			logger.Fatal("failed to connect to redis", zap.Error(err))
+		}
+		rateLimitStore = ratelimit.NewRedisStore(client, "harmony:ratelimit:")
+	default:
+		memoryStore := ratelimit.NewMemoryStore()
+		go runPeriodically(ctx, logger, "prune rate limit buckets", time.Minute, func(ctx context.Context) error {
+			memoryStore.Prune()
+			return nil
+		})
+		rateLimitStore = memoryStore
+	}
+	rateLimiter := authmw.NewRateLimiter(rateLimitStore, cfg.RateLimitPublic, cfg.RateLimitUser, cfg.RateLimitRoutes)
+
+	keyring := token.NewKeyring(repository.NewSigningKeyRepository(db), cfg.JWTAlgorithm, max(cfg.JWTAccessTTL, cfg.JWTRefreshTTL))
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1], keyring, logger); err != nil {
+			logger.Fatal("command failed", zap.String("command", os.Args[1]), zap.Error(err))
+		}
+		return
+	}
+	if err := keyring.Load(ctx); err != nil {
+		logger.Fatal("failed to load signing keys", zap.Error(err))
+	}
+	// Access tokens signed with the secret used before signing keys are only
+	// accepted for one access token lifetime after upgrading.
+	var legacySecret string
+	if time.Since(keyring.CreatedAt()) < cfg.JWTAccessTTL {
+		if legacySecret, err = cfg.LegacyJWTSecret(); err != nil {
+			logger.Fatal("failed to read jwt secret", zap.Error(err))
+		}
+	}
+	jwtSvc := token.NewJwtService(keyring, legacySecret, cfg.JWTAccessTTL, cfg.JWTRefreshTTL)
+	jwksHandler := httphandler.NewJWKSHandler(keyring, logger)
 	userRepo := repository.NewUserRepository(db)
-	authSvc := application.NewAuthService(userRepo, jwtSvc)
+	memberRepo := repository.NewMemberRepository(db)
+	channelRepo := repository.NewChannelRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+	banRepo := repository.NewBanRepository(db)
+	auditRepo := repository.NewAuditLogRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	tokenVersions := repository.NewTokenVersionStore(db, cfg.TokenVersionCacheTTL)
+
+	auditSvc := application.NewAuditService(auditRepo, cfg.AuditLogRetention)
+	auditHandler := httphandler.NewAuditHandler(auditSvc, logger)
+
+	botRepo := repository.NewBotRepository(db)
+	botAuthenticator := application.NewBotAuthenticator(botRepo, userRepo)
+	gateway := httphandler.NewGateway(jwtSvc, sessionRepo, botAuthenticator, cfg.GatewayOrigins, logger)
+
+	sessionSvc := application.NewSessionService(sessionRepo, tokenVersions, gateway)
+	sessionHandler := httphandler.NewSessionHandler(sessionSvc, logger)
+
+	inviteSvc := application.NewInviteService(inviteRepo, memberRepo, banRepo, channelRepo, gateway)
+	inviteHandler := httphandler.NewInviteHandler(inviteSvc, logger)
+
+	emailVerificationRepo := repository.NewEmailVerificationRepository(db)
+	emailSvc := application.NewEmailService(userRepo, emailVerificationRepo, mailer, cfg.EmailVerificationTTL, cfg.PublicURL, cfg.RequireVerifiedEmail)
+	emailHandler := httphandler.NewEmailHandler(emailSvc, logger)
+
+	passkeyProvider, err := passkey.NewWebAuthnProvider(cfg.WebAuthnRPID, cfg.WebAuthnRPName, cfg.WebAuthnOrigins)
+	if err != nil {
+		logger.Fatal("failed to configure passkeys", zap.Error(err))
+	}
+	passkeyRepo := repository.NewPasskeyRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	mfaSvc := application.NewMFAService(userRepo, mfaRepo, passkeyRepo, passkeyProvider, auditSvc, cfg.RequireAdminMFA)
+	mfaHandler := httphandler.NewMFAHandler(mfaSvc, logger)
+	passkeySvc := application.NewPasskeyService(userRepo, passkeyRepo, passkeyProvider, mfaSvc)
+
+	identityRepo := repository.NewIdentityRepository(db)
+	var verifiers []domain.CredentialVerifier
+	if cfg.LDAPURL == "" || cfg.LDAPLocalLogin {
+		verifiers = append(verifiers, application.NewPasswordVerifier(userRepo))
+	}
+	var directorySvc *application.DirectoryService
+	if cfg.LDAPURL != "" {
+		directory := ldap.NewDirectory(ldap.Config{
+			URL:            cfg.LDAPURL,
+			StartTLS:       cfg.LDAPStartTLS,
+			BindDN:         cfg.LDAPBindDN,
+			BindPassword:   cfg.LDAPBindPassword,
+			BaseDN:         cfg.LDAPBaseDN,
+			UserFilter:     cfg.LDAPUserFilter,
+			IDAttribute:    cfg.LDAPIDAttribute,
+			EmailAttribute: cfg.LDAPEmailAttribute,
+			NameAttribute:  cfg.LDAPNameAttribute,
+			GroupAttribute: cfg.LDAPGroupAttribute,
+		})
+		directorySvc = application.NewDirectoryService(userRepo, memberRepo, identityRepo, directory, auditSvc, cfg.RegistrationMode, cfg.LDAPAutoProvision, cfg.LDAPAdminGroups)
+		verifiers = append(verifiers, directorySvc)
+	}
+
+	lockoutSvc := application.NewLockoutService(repository.NewLoginAttemptRepository(db), userRepo, mailer, auditSvc, application.LockoutPolicy{
+		FreeAttempts:     cfg.LoginFreeAttempts,
+		IPFreeAttempts:   cfg.LoginIPFreeAttempts,
+		BaseDelay:        cfg.LoginBackoffBase,
+		MaxDelay:         cfg.LoginBackoffMax,
+		AccountThreshold: cfg.LoginLockoutThreshold,
+		IPThreshold:      cfg.LoginIPLockoutThreshold,
+		LockoutDuration:  cfg.LoginLockoutDuration,
+		Window:           cfg.LoginFailureWindow,
+	})
+
+	authSvc := application.NewAuthService(userRepo, verifiers, memberRepo, sessionRepo, sessionSvc, emailSvc, mfaSvc, passkeySvc, lockoutSvc, inviteSvc, auditSvc, jwtSvc, cfg.RegistrationMode, cfg.JWTRefreshTTL)
 	authHandler := httphandler.NewAuthHandler(authSvc, logger)
-	userSvc := application.NewUserService(userRepo)
+	passkeyHandler := httphandler.NewPasskeyHandler(passkeySvc, authSvc, logger)
+
+	oidcProviders := make([]application.OIDCProvider, len(cfg.OIDCProviders))
+	for i, p := range cfg.OIDCProviders {
+		oidcProviders[i] = application.OIDCProvider{
+			Name:          p.Name,
+			DisplayName:   p.DisplayName,
+			Provider:      oidc.NewProvider(p.Issuer, p.ClientID, p.ClientSecret, p.RedirectURL, p.Scopes, p.GroupsClaim),
+			AutoProvision: p.AutoProvision,
+			AdminGroups:   p.AdminGroups,
+		}
+	}
+	oidcSvc := application.NewOIDCService(userRepo, repository.NewOIDCRepository(db), identityRepo, authSvc, auditSvc, oidcProviders)
+	oidcHandler := httphandler.NewOIDCHandler(oidcSvc, logger)
+
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	passwordSvc := application.NewPasswordService(userRepo, passwordResetRepo, authSvc, sessionSvc, mailer, cfg.PasswordResetTTL, cfg.PublicURL)
+	passwordHandler := httphandler.NewPasswordHandler(passwordSvc, logger)
+	userSvc := application.NewUserService(userRepo, botRepo, sessionSvc, emailSvc, auditSvc, gateway)
 	userHandler := httphandler.NewHandler(userSvc, logger)
+	accessTokenSvc := application.NewPersonalAccessTokenService(repository.NewPersonalAccessTokenRepository(db), userRepo)
+	accessTokenHandler := httphandler.NewPersonalAccessTokenHandler(accessTokenSvc, logger)
+	adminHandler := httphandler.NewAdminHandler(authSvc, userSvc, lockoutSvc, logger)
 
-	channelRepo := repository.NewChannelRepository(db)
-	channelSvc := application.NewChannelService(channelRepo)
+	channelSvc := application.NewChannelService(channelRepo, auditSvc)
 	channelHandler := httphandler.NewChannelHandler(channelSvc, logger)
 
+	botSvc := application.NewBotService(botRepo, userRepo, memberRepo, banRepo, auditSvc, gateway)
+	botHandler := httphandler.NewBotHandler(botSvc, logger)
+
+	messageRepo := repository.NewMessageRepository(db)
+	moderationSvc := application.NewModerationService(memberRepo, banRepo, userRepo, messageRepo, auditSvc, gateway)
+	moderationHandler := httphandler.NewModerationHandler(moderationSvc, logger)
+
+	relationshipRepo := repository.NewRelationshipRepository(db)
+	relationshipSvc := application.NewRelationshipService(relationshipRepo, userRepo, gateway)
+	relationshipHandler := httphandler.NewRelationshipHandler(relationshipSvc, logger)
+
+	autoModRepo := repository.NewAutoModRepository(db)
+	autoModSvc := application.NewAutoModService(autoModRepo, messageRepo, channelRepo, userRepo, moderationSvc, auditSvc)
+	autoModHandler := httphandler.NewAutoModHandler(autoModSvc, logger)
+
+	messageSvc := application.NewMessageService(messageRepo, channelRepo, repository.NewSlowmodeRepository(db), userRepo, moderationSvc, emailSvc, autoModSvc, auditSvc, relationshipRepo)
+	messageHandler := httphandler.NewMessageHandler(messageSvc, logger)
+
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookSvc := application.NewWebhookService(webhookRepo, channelRepo, messageRepo, auditSvc, cfg.PublicURL)
+	webhookHandler := httphandler.NewWebhookHandler(webhookSvc, logger)
+
+	dmRepo := repository.NewDMChannelRepository(db)
+	dmSvc := application.NewDMService(dmRepo, messageRepo, userRepo, memberRepo, relationshipRepo, emailSvc, gateway, cfg.GroupDMMaxRecipients)
+	dmHandler := httphandler.NewDMHandler(dmSvc, logger)
+
+	reportRepo := repository.NewReportRepository(db)
+	reportSvc := application.NewReportService(reportRepo, messageRepo, channelRepo, dmRepo, userRepo, auditSvc)
+	reportHandler := httphandler.NewReportHandler(reportSvc, logger)
+
+	go runPeriodically(ctx, logger, "prune expired invites", cfg.InvitePruneInterval, func(ctx context.Context) error {
+		n, err := inviteSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned expired invites", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "remove offline temporary members", cfg.ModerationPruneInterval, func(ctx context.Context) error {
+		n, err := inviteSvc.PruneTemporaryMembers(ctx)
+		if n > 0 {
+			logger.Info("removed offline temporary members", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "lift expired bans and timeouts", cfg.ModerationPruneInterval, func(ctx context.Context) error {
+		n, err := moderationSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("lifted expired bans and timeouts", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune slowmode cooldowns", time.Hour, func(ctx context.Context) error {
+		n, err := messageSvc.PruneSlowmode(ctx)
+		if n > 0 {
+			logger.Info("pruned slowmode cooldowns", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune audit log", time.Hour, func(ctx context.Context) error {
+		n, err := auditSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned audit log entries", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune password resets", time.Hour, func(ctx context.Context) error {
+		n, err := passwordSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned password resets", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune email verifications", time.Hour, func(ctx context.Context) error {
+		n, err := emailSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned email verifications", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune mfa challenges", time.Hour, func(ctx context.Context) error {
+		n, err := mfaSvc.PruneExpiredChallenges(ctx)
+		if n > 0 {
+			logger.Info("pruned mfa challenges", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune passkey ceremonies", time.Hour, func(ctx context.Context) error {
+		n, err := passkeySvc.PruneExpiredCeremonies(ctx)
+		if n > 0 {
+			logger.Info("pruned passkey ceremonies", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune oidc states", time.Hour, func(ctx context.Context) error {
+		n, err := oidcSvc.PruneExpiredStates(ctx)
+		if n > 0 {
+			logger.Info("pruned oidc states", zap.Int64("count", n))
+		}
+		return err
+	})
+	if directorySvc != nil {
+		go runPeriodically(ctx, logger, "sync ldap directory", cfg.LDAPSyncInterval, func(ctx context.Context) error {
+			n, err := directorySvc.Sync(ctx)
+			if n > 0 {
+				logger.Info("synced users from ldap directory", zap.Int("count", n))
+			}
+			return err
+		})
+	}
+	go runPeriodically(ctx, logger, "prune login attempts", time.Hour, func(ctx context.Context) error {
+		n, err := lockoutSvc.PruneStale(ctx)
+		if n > 0 {
+			logger.Info("pruned login attempts", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "reload signing keys", time.Minute, func(ctx context.Context) error {
+		return keyring.Load(ctx)
+	})
+	go runPeriodically(ctx, logger, "prune signing keys", time.Hour, func(ctx context.Context) error {
+		n, err := keyring.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned signing keys", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune personal access tokens", time.Hour, func(ctx context.Context) error {
+		n, err := accessTokenSvc.PruneExpired(ctx)
+		if n > 0 {
+			logger.Info("pruned personal access tokens", zap.Int64("count", n))
+		}
+		return err
+	})
+	go runPeriodically(ctx, logger, "prune token version cache", cfg.TokenVersionCacheTTL, func(ctx context.Context) error {
+		tokenVersions.Prune()
+		return nil
+	})
+	go runPeriodically(ctx, logger, "prune expired sessions", time.Hour, func(ctx context.Context) error {
+		n, err := authSvc.PruneExpiredSessions(ctx)
+		if n > 0 {
+			logger.Info("pruned expired sessions", zap.Int64("count", n))
+		}
+		return err
+	})
+
 	router := httphandler.NewRouter(httphandler.Dependencies{
-		AuthHandler:    authHandler,
-		UserHandler:    userHandler,
-		ChannelHandler: channelHandler,
-		JWTService:     jwtSvc,
-		Logger:         logger,
+		AuthHandler:         authHandler,
+		UserHandler:         userHandler,
+		ChannelHandler:      channelHandler,
+		MessageHandler:      messageHandler,
+		InviteHandler:       inviteHandler,
+		AdminHandler:        adminHandler,
+		ModerationHandler:   moderationHandler,
+		AuditHandler:        auditHandler,
+		AutoModHandler:      autoModHandler,
+		ReportHandler:       reportHandler,
+		DMHandler:           dmHandler,
+		RelationshipHandler: relationshipHandler,
+		SessionHandler:      sessionHandler,
+		PasswordHandler:     passwordHandler,
+		EmailHandler:        emailHandler,
+		MFAHandler:          mfaHandler,
+		PasskeyHandler:      passkeyHandler,
+		AccessTokenHandler:  accessTokenHandler,
+		BotHandler:          botHandler,
+		WebhookHandler:      webhookHandler,
+		OIDCHandler:         oidcHandler,
+		JWKSHandler:         jwksHandler,
+		Gateway:             gateway,
+		JWTService:          jwtSvc,
+		TokenVersions:       tokenVersions,
+		UserRepository:      userRepo,
+		MemberRepository:    memberRepo,
+		MFAPolicy:           mfaSvc,
+		AccessTokens:        accessTokenSvc,
+		Bots:                botAuthenticator,
+		RateLimiter:         rateLimiter,
+		TrustedProxies:      cfg.TrustedProxies,
+		Logger:              logger,
 	})
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
@@ -55,3 +377,44 @@ func main() {
 		logger.Fatal("server failed", zap.Error(err))
 	}
 }
+
+// runCommand runs a maintenance command given on the command line instead of
+// starting the server.
+func runCommand(ctx context.Context, name string, keyring *token.Keyring, logger *zap.Logger) error {
+	switch name {
+	case "rotate-signing-key":
+		// Running instances pick the new key up within a minute, and verify
+		// tokens signed with it right away.
+		id, err := keyring.Rotate(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("rotated signing key", zap.String("kid", id))
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func newMailer(cfg config.Config, logger *zap.Logger) (domain.Mailer, error) {
+	if cfg.MailDriver == config.MailDriverSMTP {
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	return mail.NewOutboxMailer(cfg.MailOutboxDir, cfg.MailFrom, logger)
+}
+
+// runPeriodically calls job every interval until ctx is cancelled.
+func runPeriodically(ctx context.Context, logger *zap.Logger, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				logger.Error("background job failed", zap.String("job", name), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE members (
+    user_id     TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    invite_code TEXT,
+    temporary   INTEGER NOT NULL DEFAULT 0,
+    joined_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO members (user_id, joined_at) SELECT id, created_at FROM users;
+
+-- +goose Down
+DROP TABLE members;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE invites (
+    code       TEXT PRIMARY KEY,
+    creator_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channel_id TEXT REFERENCES channels (id) ON DELETE CASCADE,
+    max_uses   INTEGER NOT NULL DEFAULT 0,
+    uses       INTEGER NOT NULL DEFAULT 0,
+    temporary  INTEGER NOT NULL DEFAULT 0,
+    expires_at TEXT,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_invites_expires_at ON invites (expires_at);
+
+-- +goose Down
+DROP TABLE invites;
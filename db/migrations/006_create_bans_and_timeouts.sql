@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE bans (
+    user_id      TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    moderator_id TEXT REFERENCES users (id) ON DELETE SET NULL,
+    reason       TEXT NOT NULL DEFAULT '',
+    expires_at   TEXT,
+    created_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+ALTER TABLE members ADD COLUMN timed_out_until TEXT;
+
+-- +goose Down
+ALTER TABLE members DROP COLUMN timed_out_until;
+DROP TABLE bans;
@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE channels ADD COLUMN slowmode INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE messages (
+    id         TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    author_id  TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    content    TEXT NOT NULL,
+    edited_at  TEXT,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_messages_channel_id ON messages (channel_id);
+CREATE INDEX idx_messages_channel_author ON messages (channel_id, author_id);
+
+CREATE TABLE slowmode_cooldowns (
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    posted_at  TEXT NOT NULL,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+CREATE INDEX idx_slowmode_cooldowns_posted_at ON slowmode_cooldowns (posted_at);
+
+-- +goose Down
+DROP TABLE slowmode_cooldowns;
+DROP TABLE messages;
+ALTER TABLE channels DROP COLUMN slowmode;
@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE messages_new (
+    id         TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    author_id  TEXT REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL DEFAULT 'default',
+    content    TEXT NOT NULL,
+    edited_at  TEXT,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO messages_new (rowid, id, channel_id, author_id, content, edited_at, created_at)
+SELECT rowid, id, channel_id, author_id, content, edited_at, created_at FROM messages;
+
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+
+CREATE INDEX idx_messages_channel_id ON messages (channel_id);
+CREATE INDEX idx_messages_channel_author ON messages (channel_id, author_id);
+CREATE INDEX idx_messages_author_created_at ON messages (author_id, created_at);
+
+CREATE TABLE automod_rules (
+    id              TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    trigger         TEXT NOT NULL,
+    config          TEXT NOT NULL DEFAULT '{}',
+    actions         TEXT NOT NULL DEFAULT '[]',
+    exempt_channels TEXT NOT NULL DEFAULT '[]',
+    exempt_admins   INTEGER NOT NULL DEFAULT 0,
+    enabled         INTEGER NOT NULL DEFAULT 1,
+    creator_id      TEXT REFERENCES users (id) ON DELETE SET NULL,
+    created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE TABLE automod_flags (
+    id         TEXT PRIMARY KEY,
+    rule_id    TEXT REFERENCES automod_rules (id) ON DELETE SET NULL,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    content    TEXT NOT NULL,
+    blocked    INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_automod_flags_created_at ON automod_flags (created_at);
+
+-- +goose Down
+DROP TABLE automod_flags;
+DROP TABLE automod_rules;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE reports (
+    id                  TEXT PRIMARY KEY,
+    reporter_id         TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_type         TEXT NOT NULL CHECK (target_type IN ('message', 'user')),
+    target_user_id      TEXT NOT NULL,
+    message_id          TEXT,
+    channel_id          TEXT,
+    category            TEXT NOT NULL,
+    reason              TEXT NOT NULL DEFAULT '',
+    snapshot_author_id  TEXT NOT NULL,
+    snapshot_username   TEXT NOT NULL DEFAULT '',
+    snapshot_content    TEXT NOT NULL DEFAULT '',
+    snapshot_created_at TEXT NOT NULL,
+    status              TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'escalated', 'resolved', 'dismissed')),
+    moderator_id        TEXT,
+    note                TEXT NOT NULL DEFAULT '',
+    created_at          TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at          TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_reports_status ON reports (status);
+
+-- +goose Down
+DROP TABLE reports;
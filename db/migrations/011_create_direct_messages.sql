@@ -0,0 +1,72 @@
+-- +goose NO TRANSACTION
+
+-- Rebuilding channels to widen its type constraint requires foreign keys to be
+-- disabled, otherwise dropping the old table would cascade to its messages.
+
+-- +goose Up
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE channels_new (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL CHECK (type IN ('text', 'voice', 'dm')),
+    slowmode   INTEGER NOT NULL DEFAULT 0,
+    -- dm_key holds the sorted recipient IDs of one-to-one channels, so that
+    -- two users cannot end up with two channels.
+    dm_key     TEXT UNIQUE,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO channels_new (rowid, id, name, type, slowmode, created_at, updated_at)
+SELECT rowid, id, name, type, slowmode, created_at, updated_at FROM channels;
+
+DROP TABLE channels;
+ALTER TABLE channels_new RENAME TO channels;
+
+CREATE TABLE channel_recipients (
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+CREATE INDEX idx_channel_recipients_user_id ON channel_recipients (user_id);
+
+ALTER TABLE users ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone';
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+ALTER TABLE users DROP COLUMN dm_policy;
+
+DROP TABLE channel_recipients;
+
+DELETE FROM messages WHERE channel_id IN (SELECT id FROM channels WHERE type = 'dm');
+DELETE FROM channels WHERE type = 'dm';
+
+CREATE TABLE channels_old (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL CHECK (type IN ('text', 'voice')),
+    slowmode   INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO channels_old (rowid, id, name, type, slowmode, created_at, updated_at)
+SELECT rowid, id, name, type, slowmode, created_at, updated_at FROM channels;
+
+DROP TABLE channels;
+ALTER TABLE channels_old RENAME TO channels;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,60 @@
+-- +goose NO TRANSACTION
+
+-- See 011_create_direct_messages.sql for why foreign keys are disabled while
+-- channels is rebuilt.
+
+-- +goose Up
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE channels_new (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL CHECK (type IN ('text', 'voice', 'dm', 'group_dm')),
+    slowmode   INTEGER NOT NULL DEFAULT 0,
+    dm_key     TEXT UNIQUE,
+    owner_id   TEXT REFERENCES users (id) ON DELETE SET NULL,
+    icon       TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO channels_new (rowid, id, name, type, slowmode, dm_key, created_at, updated_at)
+SELECT rowid, id, name, type, slowmode, dm_key, created_at, updated_at FROM channels;
+
+DROP TABLE channels;
+ALTER TABLE channels_new RENAME TO channels;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+DELETE FROM messages WHERE channel_id IN (SELECT id FROM channels WHERE type = 'group_dm');
+DELETE FROM channel_recipients WHERE channel_id IN (SELECT id FROM channels WHERE type = 'group_dm');
+DELETE FROM channels WHERE type = 'group_dm';
+
+CREATE TABLE channels_old (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    type       TEXT NOT NULL CHECK (type IN ('text', 'voice', 'dm')),
+    slowmode   INTEGER NOT NULL DEFAULT 0,
+    dm_key     TEXT UNIQUE,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO channels_old (rowid, id, name, type, slowmode, dm_key, created_at, updated_at)
+SELECT rowid, id, name, type, slowmode, dm_key, created_at, updated_at FROM channels;
+
+DROP TABLE channels;
+ALTER TABLE channels_old RENAME TO channels;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE relationships (
+    user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_id  TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL CHECK (type IN ('friend', 'blocked', 'pending_incoming', 'pending_outgoing')),
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY (user_id, target_id)
+);
+
+CREATE INDEX idx_relationships_target_id ON relationships (target_id);
+
+-- +goose Down
+DROP TABLE relationships;
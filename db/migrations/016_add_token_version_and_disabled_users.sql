@@ -0,0 +1,62 @@
+-- +goose NO TRANSACTION
+
+-- Widening the status constraint requires rebuilding users with foreign keys
+-- disabled, otherwise dropping the old table would cascade to everything that
+-- references it.
+
+-- +goose Up
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_new (
+    id            TEXT PRIMARY KEY,
+    username      TEXT NOT NULL,
+    email         TEXT NOT NULL UNIQUE,
+    password      TEXT NOT NULL,
+    is_admin      INTEGER NOT NULL DEFAULT 0,
+    status        TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'pending', 'disabled')),
+    dm_policy     TEXT NOT NULL DEFAULT 'everyone',
+    token_version INTEGER NOT NULL DEFAULT 0,
+    created_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+INSERT INTO users_new (rowid, id, username, email, password, is_admin, status, dm_policy, created_at, updated_at)
+SELECT rowid, id, username, email, password, is_admin, status, dm_policy, created_at, updated_at FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_old (
+    id         TEXT PRIMARY KEY,
+    username   TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    password   TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    is_admin   INTEGER NOT NULL DEFAULT 0,
+    status     TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'pending')),
+    dm_policy  TEXT NOT NULL DEFAULT 'everyone'
+);
+
+INSERT INTO users_old (rowid, id, username, email, password, created_at, updated_at, is_admin, status, dm_policy)
+SELECT rowid, id, username, email, password, created_at, updated_at, is_admin,
+       CASE status WHEN 'disabled' THEN 'pending' ELSE status END, dm_policy
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE passkeys (
+    id               TEXT PRIMARY KEY,
+    user_id          TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name             TEXT NOT NULL,
+    public_key       BLOB NOT NULL,
+    attestation_type TEXT NOT NULL DEFAULT '',
+    aaguid           BLOB,
+    sign_count       INTEGER NOT NULL DEFAULT 0,
+    transports       TEXT NOT NULL DEFAULT '',
+    backup_eligible  INTEGER NOT NULL DEFAULT 0,
+    backup_state     INTEGER NOT NULL DEFAULT 0,
+    last_used_at     TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_passkeys_user_id ON passkeys (user_id);
+
+CREATE TABLE passkey_ceremonies (
+    token_hash TEXT PRIMARY KEY,
+    type       TEXT NOT NULL CHECK (type IN ('registration', 'login')),
+    user_id    TEXT REFERENCES users (id) ON DELETE CASCADE,
+    state      BLOB NOT NULL,
+    expires_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+ALTER TABLE mfa_challenges ADD COLUMN passkey_state BLOB;
+
+-- +goose Down
+ALTER TABLE mfa_challenges DROP COLUMN passkey_state;
+DROP TABLE passkey_ceremonies;
+DROP TABLE passkeys;
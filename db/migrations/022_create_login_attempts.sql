@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE login_attempts (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TEXT NOT NULL,
+    locked_until    TEXT
+);
+
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts (last_failure_at);
+
+-- +goose Down
+DROP TABLE login_attempts;
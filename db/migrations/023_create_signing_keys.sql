@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE signing_keys (
+    id          TEXT PRIMARY KEY,
+    algorithm   TEXT NOT NULL,
+    private_key BLOB NOT NULL,
+    created_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    retired_at  TEXT,
+    expires_at  TEXT
+);
+
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys (expires_at);
+
+-- +goose Down
+DROP TABLE signing_keys;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_bot INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE bots (
+    user_id       TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    owner_id      TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash    TEXT NOT NULL UNIQUE,
+    permissions   TEXT NOT NULL DEFAULT '',
+    authorized_by TEXT REFERENCES users (id) ON DELETE SET NULL,
+    authorized_at TEXT,
+    created_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_bots_owner_id ON bots (owner_id);
+
+-- +goose Down
+DROP TABLE bots;
+
+DELETE FROM users WHERE is_bot = 1;
+ALTER TABLE users DROP COLUMN is_bot;
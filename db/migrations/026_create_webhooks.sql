@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    id         TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    avatar_url TEXT,
+    token_hash TEXT NOT NULL,
+    creator_id TEXT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+
+CREATE INDEX idx_webhooks_channel_id ON webhooks (channel_id);
+
+-- Messages outlive the webhook they were posted through, so webhook_id is not
+-- a foreign key.
+ALTER TABLE messages ADD COLUMN webhook_id TEXT;
+ALTER TABLE messages ADD COLUMN webhook_username TEXT;
+ALTER TABLE messages ADD COLUMN webhook_avatar_url TEXT;
+ALTER TABLE messages ADD COLUMN embeds TEXT;
+
+-- Reports on webhook messages name the webhook instead of an author.
+ALTER TABLE reports ADD COLUMN snapshot_webhook_id TEXT;
+
+-- +goose Down
+ALTER TABLE reports DROP COLUMN snapshot_webhook_id;
+
+DELETE FROM messages WHERE webhook_id IS NOT NULL;
+
+ALTER TABLE messages DROP COLUMN embeds;
+ALTER TABLE messages DROP COLUMN webhook_avatar_url;
+ALTER TABLE messages DROP COLUMN webhook_username;
+ALTER TABLE messages DROP COLUMN webhook_id;
+
+DROP TABLE webhooks;
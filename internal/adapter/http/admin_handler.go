@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type AdminHandler struct {
+	authSvc    *application.AuthService
+	userSvc    *application.UserService
+	lockoutSvc *application.LockoutService
+	logger     *zap.Logger
+}
+
+func NewAdminHandler(authSvc *application.AuthService, userSvc *application.UserService, lockoutSvc *application.LockoutService, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{authSvc: authSvc, userSvc: userSvc, lockoutSvc: lockoutSvc, logger: logger}
+}
+
+type createAccountRequest struct {
+	Username string `json:"username" validate:"required,min=2,max=32"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=128"`
+	IsAdmin  bool   `json:"isAdmin"`
+}
+
+func (h *AdminHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	user, err := h.authSvc.CreateAccount(r.Context(), req.Username, req.Email, req.Password, req.IsAdmin)
+	if errors.Is(err, application.ErrVerificationNotSent) {
+		h.logger.Warn("failed to send verification email", zap.String("id", user.ID), zap.Error(err))
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(err, application.ErrEmailTaken) {
+			writeJSON(w, http.StatusConflict, errorResponse{"email already taken", "EMAIL_TAKEN"})
+			return
+		}
+		h.logger.Error("failed to create account", zap.String("email", req.Email), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("account created by admin", zap.String("id", user.ID), zap.String("username", user.Username))
+	writeJSON(w, http.StatusCreated, UserToResponse(user))
+}
+
+func (h *AdminHandler) GetPendingRegistrations(w http.ResponseWriter, r *http.Request) {
+	users, err := h.userSvc.GetPending(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get pending registrations", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UsersToResponse(users))
+}
+
+func (h *AdminHandler) ApproveRegistration(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	user, err := h.userSvc.Approve(r.Context(), id)
+	if err != nil {
+		h.writePendingError(w, id, err)
+		return
+	}
+
+	h.logger.Info("registration approved", zap.String("id", id))
+	writeJSON(w, http.StatusOK, UserToResponse(user))
+}
+
+func (h *AdminHandler) RejectRegistration(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.userSvc.Reject(r.Context(), id); err != nil {
+		h.writePendingError(w, id, err)
+		return
+	}
+
+	h.logger.Info("registration rejected", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	user, err := h.userSvc.Disable(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, "failed to disable user", id, err)
+		return
+	}
+
+	h.logger.Info("user disabled", zap.String("id", id))
+	writeJSON(w, http.StatusOK, UserToResponse(user))
+}
+
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	user, err := h.userSvc.Enable(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, "failed to enable user", id, err)
+		return
+	}
+
+	h.logger.Info("user enabled", zap.String("id", id))
+	writeJSON(w, http.StatusOK, UserToResponse(user))
+}
+
+// ForceLogout signs the user out of all their sessions.
+func (h *AdminHandler) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.userSvc.ForceLogout(r.Context(), id); err != nil {
+		h.writeUserError(w, "failed to force logout", id, err)
+		return
+	}
+
+	h.logger.Info("user logged out by admin", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unlock lifts the lockout and backoff caused by failed logins to the
+// user's account.
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.lockoutSvc.Unlock(r.Context(), id); err != nil {
+		h.writeUserError(w, "failed to unlock user", id, err)
+		return
+	}
+
+	h.logger.Info("user unlocked by admin", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) writeUserError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrUserDisabled):
+		writeJSON(w, http.StatusConflict, errorResponse{"user is already disabled", "USER_DISABLED"})
+	case errors.Is(err, application.ErrUserNotDisabled):
+		writeJSON(w, http.StatusConflict, errorResponse{"user is not disabled", "USER_NOT_DISABLED"})
+	case errors.Is(err, application.ErrAccountNotLocked):
+		writeJSON(w, http.StatusConflict, errorResponse{"user has no failed login attempts", "NOT_LOCKED"})
+	case errors.Is(err, application.ErrCannotDisableSelf):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"you cannot disable your own account", "CANNOT_DISABLE_SELF"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
+
+func (h *AdminHandler) writePendingError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrUserNotPending):
+		writeJSON(w, http.StatusConflict, errorResponse{"user is not awaiting approval", "NOT_PENDING"})
+	default:
+		h.logger.Error("failed to process registration", zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
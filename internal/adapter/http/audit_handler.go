@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type AuditHandler struct {
+	svc    *application.AuditService
+	logger *zap.Logger
+}
+
+func NewAuditHandler(svc *application.AuditService, logger *zap.Logger) *AuditHandler {
+	return &AuditHandler{svc: svc, logger: logger}
+}
+
+func (h *AuditHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := domain.AuditLogFilter{
+		ActorID:    q.Get("actorId"),
+		Action:     domain.AuditAction(q.Get("action")),
+		TargetType: domain.AuditTargetType(q.Get("targetType")),
+		TargetID:   q.Get("targetId"),
+		Before:     q.Get("before"),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"limit must be a positive integer", "VALIDATION_ERROR"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.svc.Find(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("failed to get audit log", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AuditLogEntriesToResponse(entries))
+}
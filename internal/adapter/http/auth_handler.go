@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
 	"github.com/tartine-studio/harmony-server/internal/application"
 )
 
@@ -34,16 +37,44 @@ func NewAuthHandler(svc *application.AuthService, logger *zap.Logger) *AuthHandl
 }
 
 type registerRequest struct {
-	Username string `json:"username" validate:"required,min=2,max=32"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=128"`
+	Username   string `json:"username" validate:"required,min=2,max=32"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=8,max=128"`
+	InviteCode string `json:"inviteCode" validate:"omitempty,max=32"`
 }
 
+// loginRequest carries the email of a local account or, with a directory
+// configured, any login its user filter accepts.
 type loginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email" validate:"required,max=254"`
 	Password string `json:"password" validate:"required"`
 }
 
+// loginMFARequest completes a login with either a code or the response of a
+// passkey assertion started through BeginLoginMFAPasskey.
+type loginMFARequest struct {
+	MFAToken string          `json:"mfaToken" validate:"required,max=128"`
+	Code     string          `json:"code" validate:"required_without=Passkey,max=32"`
+	Passkey  json.RawMessage `json:"passkey" validate:"required_without=Code"`
+}
+
+type mfaTokenRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required,max=128"`
+}
+
+// mfaChallengeResponse is returned by Login instead of a token pair when the
+// user has two-factor authentication enabled.
+type mfaChallengeResponse struct {
+	MFARequired bool     `json:"mfaRequired"`
+	MFAToken    string   `json:"mfaToken"`
+	Methods     []string `json:"methods"`
+	ExpiresAt   string   `json:"expiresAt"`
+}
+
+type passkeyOptionsResponse struct {
+	Options json.RawMessage `json:"options"`
+}
+
 type refreshRequest struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
@@ -60,13 +91,30 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.svc.Register(r.Context(), req.Username, req.Email, req.Password)
+	user, err := h.svc.Register(r.Context(), req.Username, req.Email, req.Password, req.InviteCode)
+	if errors.Is(err, application.ErrVerificationNotSent) {
+		// The account exists; the user can ask for a new link.
+		h.logger.Warn("failed to send verification email", zap.String("id", user.ID), zap.Error(err))
+		err = nil
+	}
 	if err != nil {
 		h.logger.Error("failed to register new user", zap.String("email", req.Email), zap.Error(err))
 		if errors.Is(err, ErrEmailTaken) {
 			writeJSON(w, http.StatusBadRequest, errorResponse{"registration failed", "REGISTRATION_FAILED"})
 			return
 		}
+		if errors.Is(err, application.ErrInviteNotFound) || errors.Is(err, application.ErrInviteInvalid) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"invalid or expired invite", "INVALID_INVITE"})
+			return
+		}
+		if errors.Is(err, application.ErrRegistrationClosed) {
+			writeJSON(w, http.StatusForbidden, errorResponse{"registration is closed", "REGISTRATION_CLOSED"})
+			return
+		}
+		if errors.Is(err, application.ErrInviteRequired) {
+			writeJSON(w, http.StatusForbidden, errorResponse{"an invite is required to register", "INVITE_REQUIRED"})
+			return
+		}
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
 		return
 	}
@@ -87,21 +135,95 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pair, err := h.svc.Login(r.Context(), req.Email, req.Password)
+	result, err := h.svc.Login(r.Context(), req.Email, req.Password, clientInfo(r))
 	if err != nil {
 		h.logger.Warn("failed to login", zap.String("email", req.Email), zap.Error(err))
 		if errors.Is(err, ErrInvalidCredentials) {
 			writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid email or password", "INVALID_CREDENTIALS"})
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		writeLoginError(w, err)
+		return
+	}
+
+	if result.Tokens == nil {
+		h.logger.Info("user passed password check, awaiting second factor", zap.String("email", req.Email))
+	} else {
+		h.logger.Info("user logged in", zap.String("email", req.Email))
+	}
+	writeLoginResult(w, result)
+}
+
+// LoginMFA completes a login for a user with two-factor authentication, using
+// the token returned by Login and a code from their authenticator app, a
+// recovery code or a passkey.
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req loginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	pair, err := h.svc.LoginMFA(r.Context(), req.MFAToken, req.Code, req.Passkey, clientInfo(r))
+	if err != nil {
+		h.logger.Warn("failed to complete two-factor login", zap.Error(err))
+		if errors.Is(err, application.ErrInvalidMFAToken) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid or expired two-factor authentication token", "INVALID_MFA_TOKEN"})
+			return
+		}
+		if errors.Is(err, application.ErrInvalidMFACode) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid two-factor authentication code", "INVALID_MFA_CODE"})
+			return
+		}
+		if errors.Is(err, application.ErrInvalidPasskey) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"passkey verification failed", "INVALID_PASSKEY"})
+			return
+		}
+		writeLoginError(w, err)
 		return
 	}
 
-	h.logger.Info("user logged in", zap.String("email", req.Email))
+	h.logger.Info("user logged in with two-factor authentication")
 	writeJSON(w, http.StatusOK, pair)
 }
 
+// BeginLoginMFAPasskey returns the options for a passkey assertion that can
+// then be passed to LoginMFA.
+func (h *AuthHandler) BeginLoginMFAPasskey(w http.ResponseWriter, r *http.Request) {
+	var req mfaTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	options, err := h.svc.BeginLoginMFAPasskey(r.Context(), req.MFAToken)
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidMFAToken) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid or expired two-factor authentication token", "INVALID_MFA_TOKEN"})
+			return
+		}
+		if errors.Is(err, application.ErrPasskeyNotFound) {
+			writeJSON(w, http.StatusConflict, errorResponse{"no passkey is registered", "NO_PASSKEY"})
+			return
+		}
+		h.logger.Error("failed to begin passkey assertion", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyOptionsResponse{Options: options})
+}
+
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	var req refreshRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -114,7 +236,7 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pair, err := h.svc.Refresh(r.Context(), req.RefreshToken)
+	pair, err := h.svc.Refresh(r.Context(), req.RefreshToken, clientInfo(r))
 	if err != nil {
 		h.logger.Warn("failed to refresh token", zap.Error(err))
 		if errors.Is(err, ErrInvalidToken) {
@@ -129,6 +251,62 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, pair)
 }
 
+// Logout revokes the session the access token was issued for, so its refresh
+// token can no longer be used.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	if err := h.svc.Logout(r.Context(), uc.SessionID); err != nil {
+		h.logger.Error("failed to logout", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("user logged out", zap.String("userId", uc.UserID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeLoginResult responds with the token pair of a completed login, or with
+// the challenge to complete when the user has a second factor.
+func writeLoginResult(w http.ResponseWriter, result *application.LoginResult) {
+	if result.Tokens == nil {
+		writeJSON(w, http.StatusOK, mfaChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAToken,
+			Methods:     result.MFAMethods,
+			ExpiresAt:   result.MFAExpiresAt.Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, result.Tokens)
+}
+
+// writeLoginError reports why a login failed, other than because of wrong
+// credentials.
+func writeLoginError(w http.ResponseWriter, err error) {
+	var tooMany *application.TooManyAttemptsError
+	switch {
+	case errors.As(err, &tooMany):
+		writeRetryAfter(w, "too many failed login attempts", "TOO_MANY_ATTEMPTS", tooMany.RetryAfter)
+	case errors.Is(err, application.ErrAccountPending):
+		writeJSON(w, http.StatusForbidden, errorResponse{"account is awaiting approval by an administrator", "ACCOUNT_PENDING"})
+	case errors.Is(err, application.ErrAccountDisabled):
+		writeJSON(w, http.StatusForbidden, errorResponse{"account has been disabled by an administrator", "ACCOUNT_DISABLED"})
+	case errors.Is(err, application.ErrDirectoryLinkRefused):
+		writeJSON(w, http.StatusConflict, errorResponse{"an account with an unverified email already uses this address", "DIRECTORY_LINK_REFUSED"})
+	case errors.Is(err, application.ErrDirectoryEntryNoEmail):
+		writeJSON(w, http.StatusForbidden, errorResponse{"directory entry has no email address", "DIRECTORY_ENTRY_NO_EMAIL"})
+	case errors.Is(err, application.ErrDirectoryUnavailable):
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{"directory is unavailable", "DIRECTORY_UNAVAILABLE"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
+
 func formatValidationError(err error) string {
 	var ve validator.ValidationErrors
 	if errors.As(err, &ve) && len(ve) > 0 {
@@ -138,9 +316,19 @@ func formatValidationError(err error) string {
 			return fe.Field() + " is required"
 		case "email":
 			return "invalid email format"
+		case "http_url":
+			return fe.Field() + " must be an http or https URL"
+		case "required_without":
+			return fe.Field() + " or " + fe.Param() + " is required"
 		case "min":
+			if fe.Kind() != reflect.String {
+				return fe.Field() + " must be at least " + fe.Param()
+			}
 			return fe.Field() + " must be at least " + fe.Param() + " characters"
 		case "max":
+			if fe.Kind() != reflect.String {
+				return fe.Field() + " must be at most " + fe.Param()
+			}
 			return fe.Field() + " must be at most " + fe.Param() + " characters"
 		}
 	}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+func TestWriteLoginError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{"too many attempts", &application.TooManyAttemptsError{RetryAfter: 1500 * time.Millisecond}, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "2"},
+		{"disabled account", application.ErrAccountDisabled, http.StatusForbidden, "ACCOUNT_DISABLED", ""},
+		{"entry without email", fmt.Errorf("verify credentials: %w", application.ErrDirectoryEntryNoEmail), http.StatusForbidden, "DIRECTORY_ENTRY_NO_EMAIL", ""},
+		{"directory down", fmt.Errorf("verify credentials: %w", application.ErrDirectoryUnavailable), http.StatusServiceUnavailable, "DIRECTORY_UNAVAILABLE", ""},
+		{"unexpected error", fmt.Errorf("get user: database is locked"), http.StatusInternalServerError, "INTERNAL_ERROR", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeLoginError(rec, tt.err)
+
+			var body errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if rec.Code != tt.status || body.Code != tt.code {
+				t.Errorf("response = %d %s, want %d %s", rec.Code, body.Code, tt.status, tt.code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
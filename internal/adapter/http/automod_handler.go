@@ -0,0 +1,172 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type AutoModHandler struct {
+	svc    *application.AutoModService
+	logger *zap.Logger
+}
+
+func NewAutoModHandler(svc *application.AutoModService, logger *zap.Logger) *AutoModHandler {
+	return &AutoModHandler{svc: svc, logger: logger}
+}
+
+type autoModActionRequest struct {
+	Type      string `json:"type" validate:"required,oneof=block flag timeout alert"`
+	Duration  int    `json:"duration" validate:"min=0"`
+	ChannelID string `json:"channelId" validate:"omitempty,max=64"`
+}
+
+type createAutoModRuleRequest struct {
+	Name           string                 `json:"name" validate:"required,min=1,max=100"`
+	Trigger        string                 `json:"trigger" validate:"required,oneof=keyword regex mention_spam link repeated_message invite_link"`
+	Config         domain.AutoModConfig   `json:"config"`
+	Actions        []autoModActionRequest `json:"actions" validate:"required,min=1,max=5,dive"`
+	ExemptChannels []string               `json:"exemptChannels" validate:"max=50"`
+	ExemptAdmins   bool                   `json:"exemptAdmins"`
+	Enabled        *bool                  `json:"enabled"`
+}
+
+type updateAutoModRuleRequest struct {
+	Name           *string                `json:"name" validate:"omitempty,min=1,max=100"`
+	Config         *domain.AutoModConfig  `json:"config"`
+	Actions        []autoModActionRequest `json:"actions" validate:"omitempty,min=1,max=5,dive"`
+	ExemptChannels []string               `json:"exemptChannels" validate:"omitempty,max=50"`
+	ExemptAdmins   *bool                  `json:"exemptAdmins"`
+	Enabled        *bool                  `json:"enabled"`
+}
+
+func (h *AutoModHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createAutoModRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	rule, err := h.svc.CreateRule(r.Context(), uc.UserID, application.AutoModRuleInput{
+		Name:           req.Name,
+		Trigger:        domain.AutoModTrigger(req.Trigger),
+		Config:         req.Config,
+		Actions:        toAutoModActions(req.Actions),
+		ExemptChannels: req.ExemptChannels,
+		ExemptAdmins:   req.ExemptAdmins,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	})
+	if err != nil {
+		h.writeError(w, "failed to create automod rule", "", err)
+		return
+	}
+
+	h.logger.Info("automod rule created", zap.String("id", rule.ID), zap.String("trigger", string(rule.Trigger)))
+	writeJSON(w, http.StatusCreated, AutoModRuleToResponse(rule))
+}
+
+func (h *AutoModHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.svc.GetRules(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get automod rules", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+	writeJSON(w, http.StatusOK, AutoModRulesToResponse(rules))
+}
+
+func (h *AutoModHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req updateAutoModRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	rule, err := h.svc.UpdateRule(r.Context(), id, application.AutoModRuleUpdate{
+		Name:           req.Name,
+		Config:         req.Config,
+		Actions:        toAutoModActions(req.Actions),
+		ExemptChannels: req.ExemptChannels,
+		ExemptAdmins:   req.ExemptAdmins,
+		Enabled:        req.Enabled,
+	})
+	if err != nil {
+		h.writeError(w, "failed to update automod rule", id, err)
+		return
+	}
+
+	h.logger.Info("automod rule updated", zap.String("id", id))
+	writeJSON(w, http.StatusOK, AutoModRuleToResponse(rule))
+}
+
+func (h *AutoModHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.DeleteRule(r.Context(), id); err != nil {
+		h.writeError(w, "failed to delete automod rule", id, err)
+		return
+	}
+
+	h.logger.Info("automod rule deleted", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AutoModHandler) GetFlags(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.svc.GetFlags(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get automod flags", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+	writeJSON(w, http.StatusOK, AutoModFlagsToResponse(flags))
+}
+
+func (h *AutoModHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrAutoModRuleNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"automod rule not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrInvalidAutoModRule):
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error(), "VALIDATION_ERROR"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
+
+func toAutoModActions(req []autoModActionRequest) []domain.AutoModAction {
+	if req == nil {
+		return nil
+	}
+	actions := make([]domain.AutoModAction, len(req))
+	for i, a := range req {
+		actions[i] = domain.AutoModAction{
+			Type:      domain.AutoModActionType(a.Type),
+			Duration:  a.Duration,
+			ChannelID: a.ChannelID,
+		}
+	}
+	return actions
+}
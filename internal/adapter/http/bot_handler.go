@@ -0,0 +1,188 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type BotHandler struct {
+	svc    *application.BotService
+	logger *zap.Logger
+}
+
+func NewBotHandler(svc *application.BotService, logger *zap.Logger) *BotHandler {
+	return &BotHandler{svc: svc, logger: logger}
+}
+
+type createBotRequest struct {
+	Username string `json:"username" validate:"required,min=2,max=32"`
+}
+
+type authorizeBotRequest struct {
+	Permissions []string `json:"permissions" validate:"required,min=1,max=8,dive,required,max=32"`
+}
+
+// GetAll returns the bots owned by the user.
+func (h *BotHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	bots, err := h.svc.GetAll(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error("failed to get bots", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BotsToResponse(bots))
+}
+
+// GetByID returns any bot, so that administrators can review the bot they
+// are asked to authorize.
+func (h *BotHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	bot, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		h.writeError(w, "failed to get bot", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BotToResponse(bot))
+}
+
+// Create returns the new bot along with its token, which cannot be retrieved
+// again.
+func (h *BotHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	bot, secret, err := h.svc.Create(r.Context(), uc.UserID, req.Username)
+	if err != nil {
+		h.writeError(w, "failed to create bot", err)
+		return
+	}
+
+	h.logger.Info("bot created", zap.String("ownerId", uc.UserID), zap.String("id", bot.User.ID))
+	writeJSON(w, http.StatusCreated, CreatedBotResponse{
+		BotResponse: BotToResponse(bot),
+		Token:       secret,
+	})
+}
+
+func (h *BotHandler) ResetToken(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	secret, err := h.svc.ResetToken(r.Context(), uc.UserID, id)
+	if err != nil {
+		h.writeError(w, "failed to reset bot token", err)
+		return
+	}
+
+	h.logger.Info("bot token reset", zap.String("ownerId", uc.UserID), zap.String("id", id))
+	writeJSON(w, http.StatusOK, BotTokenResponse{Token: secret})
+}
+
+func (h *BotHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Delete(r.Context(), uc.UserID, id); err != nil {
+		h.writeError(w, "failed to delete bot", err)
+		return
+	}
+
+	h.logger.Info("bot deleted", zap.String("ownerId", uc.UserID), zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Authorize adds the bot to the community with the permissions the
+// administrator chose.
+func (h *BotHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req authorizeBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	permissions := make([]domain.TokenScope, len(req.Permissions))
+	for i, p := range req.Permissions {
+		permissions[i] = domain.TokenScope(p)
+	}
+
+	id := chi.URLParam(r, "id")
+
+	bot, err := h.svc.Authorize(r.Context(), uc.UserID, id, permissions)
+	if err != nil {
+		h.writeError(w, "failed to authorize bot", err)
+		return
+	}
+
+	h.logger.Info("bot authorized", zap.String("adminId", uc.UserID), zap.String("id", id))
+	writeJSON(w, http.StatusOK, BotToResponse(bot))
+}
+
+func (h *BotHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrBotNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"bot not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrInvalidTokenScope):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid bot permission", "INVALID_SCOPE"})
+	case errors.Is(err, application.ErrForbidden):
+		writeJSON(w, http.StatusForbidden, errorResponse{"bots cannot own bots", "FORBIDDEN"})
+	case errors.Is(err, application.ErrTooManyBots):
+		writeJSON(w, http.StatusConflict, errorResponse{"too many bots", "TOO_MANY_BOTS"})
+	case errors.Is(err, application.ErrBanned):
+		writeJSON(w, http.StatusForbidden, errorResponse{"bot is banned from this community", "BANNED"})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
@@ -22,12 +22,14 @@ func NewChannelHandler(svc *application.ChannelService, logger *zap.Logger) *Cha
 }
 
 type createChannelRequest struct {
-	Name string `json:"name" validate:"required,min=1,max=100"`
-	Type string `json:"type" validate:"required,oneof=text voice"`
+	Name     string `json:"name" validate:"required,min=1,max=100"`
+	Type     string `json:"type" validate:"required,oneof=text voice"`
+	Slowmode int    `json:"slowmode" validate:"min=0,max=21600"`
 }
 
 type updateChannelRequest struct {
-	Name string `json:"name" validate:"required,min=1,max=100"`
+	Name     string `json:"name" validate:"omitempty,min=1,max=100"`
+	Slowmode *int   `json:"slowmode" validate:"omitempty,min=0,max=21600"`
 }
 
 func (h *ChannelHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -41,7 +43,7 @@ func (h *ChannelHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	channel, err := h.svc.Create(r.Context(), req.Name, domain.ChannelType(req.Type))
+	channel, err := h.svc.Create(r.Context(), req.Name, domain.ChannelType(req.Type), req.Slowmode)
 	if err != nil {
 		h.logger.Error("failed to create channel", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
@@ -93,7 +95,7 @@ func (h *ChannelHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	channel, err := h.svc.Update(r.Context(), id, req.Name)
+	channel, err := h.svc.Update(r.Context(), id, req.Name, req.Slowmode)
 	if err != nil {
 		if errors.Is(err, application.ErrChannelNotFound) {
 			writeJSON(w, http.StatusNotFound, errorResponse{"channel not found", "NOT_FOUND"})
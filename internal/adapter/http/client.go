@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const maxDeviceLength = 128
+
+// clientInfo describes the client that sent the request. The router runs
+// middleware.RealIP, so RemoteAddr holds the address reported by the reverse
+// proxy when the request came through one of the configured trusted proxies,
+// and the peer's own address otherwise.
+func clientInfo(r *http.Request) domain.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return domain.ClientInfo{
+		Device:    deviceName(r.UserAgent()),
+		IPAddress: ip,
+	}
+}
+
+// userAgentBrowsers and userAgentPlatforms are matched in order, so tokens
+// that other user agents also advertise (Chrome claims to be Safari, Edge
+// claims to be Chrome) come after the more specific ones.
+var userAgentBrowsers = []struct{ token, name string }{
+	{"Harmony", "Harmony"},
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+var userAgentPlatforms = []struct{ token, name string }{
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// deviceName turns a User-Agent header into a short label such as
+// "Firefox on Linux". Unknown agents are kept verbatim so that users can
+// still tell them apart.
+func deviceName(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	var browser, platform string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range userAgentPlatforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	if len(userAgent) > maxDeviceLength {
+		return userAgent[:maxDeviceLength]
+	}
+	return userAgent
+}
@@ -0,0 +1,341 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type DMHandler struct {
+	svc    *application.DMService
+	logger *zap.Logger
+}
+
+func NewDMHandler(svc *application.DMService, logger *zap.Logger) *DMHandler {
+	return &DMHandler{svc: svc, logger: logger}
+}
+
+type openDMRequest struct {
+	RecipientID string `json:"recipientId" validate:"required,max=64"`
+}
+
+type createGroupDMRequest struct {
+	RecipientIDs []string `json:"recipientIds" validate:"required,min=1,dive,required,max=64"`
+	Name         string   `json:"name" validate:"max=100"`
+}
+
+type updateGroupDMRequest struct {
+	Name *string `json:"name" validate:"omitempty,max=100"`
+	Icon *string `json:"icon" validate:"omitempty,max=2048"`
+}
+
+func (h *DMHandler) Open(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req openDMRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	channel, err := h.svc.Open(r.Context(), uc.UserID, req.RecipientID)
+	if err != nil {
+		h.writeError(w, "failed to open dm channel", req.RecipientID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DMChannelToResponse(channel))
+}
+
+func (h *DMHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createGroupDMRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	channel, err := h.svc.CreateGroup(r.Context(), uc.UserID, req.RecipientIDs, req.Name)
+	if err != nil {
+		h.writeError(w, "failed to create group dm", "", err)
+		return
+	}
+
+	h.logger.Info("group dm created", zap.String("id", channel.ID), zap.String("owner", uc.UserID))
+	writeJSON(w, http.StatusCreated, DMChannelToResponse(channel))
+}
+
+func (h *DMHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var req updateGroupDMRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	channel, err := h.svc.UpdateGroup(r.Context(), id, uc.UserID, req.Name, req.Icon)
+	if err != nil {
+		h.writeError(w, "failed to update group dm", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DMChannelToResponse(channel))
+}
+
+func (h *DMHandler) AddRecipient(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	recipientID := chi.URLParam(r, "userId")
+
+	channel, err := h.svc.AddRecipient(r.Context(), id, uc.UserID, recipientID)
+	if err != nil {
+		h.writeError(w, "failed to add group dm recipient", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DMChannelToResponse(channel))
+}
+
+func (h *DMHandler) RemoveRecipient(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	recipientID := chi.URLParam(r, "userId")
+
+	if err := h.svc.RemoveRecipient(r.Context(), id, uc.UserID, recipientID); err != nil {
+		h.writeError(w, "failed to remove group dm recipient", id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DMHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Leave(r.Context(), id, uc.UserID); err != nil {
+		h.writeError(w, "failed to leave group dm", id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DMHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channels, err := h.svc.GetAll(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error("failed to get dm channels", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DMChannelsToResponse(channels))
+}
+
+func (h *DMHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	channel, err := h.svc.GetByID(r.Context(), id, uc.UserID)
+	if err != nil {
+		h.writeError(w, "failed to get dm channel", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DMChannelToResponse(channel))
+}
+
+func (h *DMHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	message, err := h.svc.CreateMessage(r.Context(), channelID, uc.UserID, req.Content)
+	if err != nil {
+		h.writeError(w, "failed to create dm message", channelID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, MessageToResponse(message))
+}
+
+func (h *DMHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	q := r.URL.Query()
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"limit must be a positive integer", "VALIDATION_ERROR"})
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.svc.GetMessages(r.Context(), channelID, uc.UserID, q.Get("before"), limit)
+	if err != nil {
+		h.writeError(w, "failed to get dm messages", channelID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessagesToResponse(messages))
+}
+
+func (h *DMHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageId")
+
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	message, err := h.svc.UpdateMessage(r.Context(), channelID, messageID, uc.UserID, req.Content)
+	if err != nil {
+		h.writeError(w, "failed to update dm message", messageID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageToResponse(message))
+}
+
+func (h *DMHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageId")
+
+	if err := h.svc.DeleteMessage(r.Context(), channelID, messageID, uc.UserID); err != nil {
+		h.writeError(w, "failed to delete dm message", messageID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DMHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrDMChannelNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"channel not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrMessageNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"message not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrCannotDMSelf):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"you cannot message yourself", "CANNOT_DM_SELF"})
+	case errors.Is(err, application.ErrDMNotAllowed):
+		writeJSON(w, http.StatusForbidden, errorResponse{"this user does not accept direct messages from you", "DM_NOT_ALLOWED"})
+	case errors.Is(err, application.ErrEmailNotVerified):
+		writeJSON(w, http.StatusForbidden, errorResponse{"verify your email address before posting", "EMAIL_NOT_VERIFIED"})
+	case errors.Is(err, application.ErrNotGroupDM):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"channel is not a group dm", "NOT_GROUP_DM"})
+	case errors.Is(err, application.ErrGroupDMFull):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"group dm has reached its recipient limit", "GROUP_DM_FULL"})
+	case errors.Is(err, application.ErrAlreadyRecipient):
+		writeJSON(w, http.StatusConflict, errorResponse{"user is already in this channel", "ALREADY_RECIPIENT"})
+	case errors.Is(err, application.ErrNotRecipient):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user is not in this channel", "NOT_RECIPIENT"})
+	case errors.Is(err, application.ErrForbidden):
+		writeJSON(w, http.StatusForbidden, errorResponse{"forbidden", "FORBIDDEN"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
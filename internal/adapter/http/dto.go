@@ -3,6 +3,7 @@ package http
 import (
 	"time"
 
+	"github.com/tartine-studio/harmony-server/internal/application"
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
@@ -10,17 +11,36 @@ type UserResponse struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
 	Email     string `json:"email"`
+	IsAdmin   bool   `json:"isAdmin"`
+	Status    string `json:"status"`
+	DMPolicy  string `json:"dmPolicy"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
+
+	EmailVerified bool   `json:"emailVerified"`
+	PendingEmail  string `json:"pendingEmail,omitempty"`
+	Bot           bool   `json:"bot"`
 }
 
 func UserToResponse(u *domain.User) UserResponse {
+	email := u.Email
+	if u.IsBot {
+		// Bots only have a placeholder address.
+		email = ""
+	}
 	return UserResponse{
 		ID:        u.ID,
 		Username:  u.Username,
-		Email:     u.Email,
+		Email:     email,
+		IsAdmin:   u.IsAdmin,
+		Status:    string(u.Status),
+		DMPolicy:  string(u.DMPolicy),
 		CreatedAt: u.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: u.UpdatedAt.Format(time.RFC3339),
+
+		EmailVerified: u.EmailVerified,
+		PendingEmail:  u.PendingEmail,
+		Bot:           u.IsBot,
 	}
 }
 
@@ -36,6 +56,7 @@ type ChannelResponse struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
 	Type      string `json:"type"`
+	Slowmode  int    `json:"slowmode"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
 }
@@ -45,6 +66,7 @@ func ChannelToResponse(ch *domain.Channel) ChannelResponse {
 		ID:        ch.ID,
 		Name:      ch.Name,
 		Type:      string(ch.Type),
+		Slowmode:  ch.Slowmode,
 		CreatedAt: ch.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: ch.UpdatedAt.Format(time.RFC3339),
 	}
@@ -57,3 +79,573 @@ func ChannelsToResponse(channels []domain.Channel) []ChannelResponse {
 	}
 	return res
 }
+
+type InviteResponse struct {
+	Code      string  `json:"code"`
+	CreatorID string  `json:"creatorId"`
+	ChannelID string  `json:"channelId,omitempty"`
+	MaxUses   int     `json:"maxUses"`
+	Uses      int     `json:"uses"`
+	Temporary bool    `json:"temporary"`
+	ExpiresAt *string `json:"expiresAt"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+func InviteToResponse(inv *domain.Invite) InviteResponse {
+	return InviteResponse{
+		Code:      inv.Code,
+		CreatorID: inv.CreatorID,
+		ChannelID: inv.ChannelID,
+		MaxUses:   inv.MaxUses,
+		Uses:      inv.Uses,
+		Temporary: inv.Temporary,
+		ExpiresAt: formatOptionalTime(inv.ExpiresAt),
+		CreatedAt: inv.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func InvitesToResponse(invites []domain.Invite) []InviteResponse {
+	res := make([]InviteResponse, len(invites))
+	for i := range invites {
+		res[i] = InviteToResponse(&invites[i])
+	}
+	return res
+}
+
+type InvitePreviewResponse struct {
+	Code      string           `json:"code"`
+	Channel   *ChannelResponse `json:"channel"`
+	Temporary bool             `json:"temporary"`
+	ExpiresAt *string          `json:"expiresAt"`
+}
+
+func InviteToPreviewResponse(inv *domain.Invite, ch *domain.Channel) InvitePreviewResponse {
+	res := InvitePreviewResponse{
+		Code:      inv.Code,
+		Temporary: inv.Temporary,
+		ExpiresAt: formatOptionalTime(inv.ExpiresAt),
+	}
+	if ch != nil {
+		channel := ChannelToResponse(ch)
+		res.Channel = &channel
+	}
+	return res
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+type BanResponse struct {
+	UserID      string  `json:"userId"`
+	ModeratorID string  `json:"moderatorId"`
+	Reason      string  `json:"reason"`
+	ExpiresAt   *string `json:"expiresAt"`
+	CreatedAt   string  `json:"createdAt"`
+}
+
+func BanToResponse(b *domain.Ban) BanResponse {
+	return BanResponse{
+		UserID:      b.UserID,
+		ModeratorID: b.ModeratorID,
+		Reason:      b.Reason,
+		ExpiresAt:   formatOptionalTime(b.ExpiresAt),
+		CreatedAt:   b.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func BansToResponse(bans []domain.Ban) []BanResponse {
+	res := make([]BanResponse, len(bans))
+	for i := range bans {
+		res[i] = BanToResponse(&bans[i])
+	}
+	return res
+}
+
+type MemberResponse struct {
+	UserID        string  `json:"userId"`
+	Temporary     bool    `json:"temporary"`
+	TimedOutUntil *string `json:"timedOutUntil"`
+	JoinedAt      string  `json:"joinedAt"`
+}
+
+func MemberToResponse(m *domain.Member) MemberResponse {
+	return MemberResponse{
+		UserID:        m.UserID,
+		Temporary:     m.Temporary,
+		TimedOutUntil: formatOptionalTime(m.TimedOutUntil),
+		JoinedAt:      m.JoinedAt.Format(time.RFC3339),
+	}
+}
+
+func MembersToResponse(members []domain.Member) []MemberResponse {
+	res := make([]MemberResponse, len(members))
+	for i := range members {
+		res[i] = MemberToResponse(&members[i])
+	}
+	return res
+}
+
+type AuditLogEntryResponse struct {
+	ID         string               `json:"id"`
+	ActorID    string               `json:"actorId"`
+	Action     string               `json:"action"`
+	TargetType string               `json:"targetType"`
+	TargetID   string               `json:"targetId"`
+	Changes    []domain.AuditChange `json:"changes"`
+	Reason     string               `json:"reason"`
+	CreatedAt  string               `json:"createdAt"`
+}
+
+func AuditLogEntryToResponse(e *domain.AuditLogEntry) AuditLogEntryResponse {
+	return AuditLogEntryResponse{
+		ID:         e.ID,
+		ActorID:    e.ActorID,
+		Action:     string(e.Action),
+		TargetType: string(e.TargetType),
+		TargetID:   e.TargetID,
+		Changes:    e.Changes,
+		Reason:     e.Reason,
+		CreatedAt:  e.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func AuditLogEntriesToResponse(entries []domain.AuditLogEntry) []AuditLogEntryResponse {
+	res := make([]AuditLogEntryResponse, len(entries))
+	for i := range entries {
+		res[i] = AuditLogEntryToResponse(&entries[i])
+	}
+	return res
+}
+
+type MessageResponse struct {
+	ID        string         `json:"id"`
+	ChannelID string         `json:"channelId"`
+	AuthorID  *string        `json:"authorId"`
+	Type      string         `json:"type"`
+	Content   string         `json:"content"`
+	Embeds    []domain.Embed `json:"embeds,omitempty"`
+	// Webhook is set on messages posted through an incoming webhook, which
+	// have no author.
+	Webhook   *MessageWebhookResponse `json:"webhook,omitempty"`
+	EditedAt  *string                 `json:"editedAt"`
+	CreatedAt string                  `json:"createdAt"`
+	Blocked   bool                    `json:"blocked"`
+}
+
+type MessageWebhookResponse struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+}
+
+func MessageToResponse(m *domain.Message) MessageResponse {
+	res := MessageResponse{
+		ID:        m.ID,
+		ChannelID: m.ChannelID,
+		Type:      string(m.Type),
+		Content:   m.Content,
+		Embeds:    m.Embeds,
+		EditedAt:  formatOptionalTime(m.EditedAt),
+		CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		Blocked:   m.Blocked,
+	}
+	if m.AuthorID != "" {
+		res.AuthorID = &m.AuthorID
+	}
+	if m.Webhook != nil {
+		res.Webhook = &MessageWebhookResponse{
+			ID:        m.Webhook.ID,
+			Username:  m.Webhook.Username,
+			AvatarURL: m.Webhook.AvatarURL,
+		}
+	}
+	return res
+}
+
+func MessagesToResponse(messages []domain.Message) []MessageResponse {
+	res := make([]MessageResponse, len(messages))
+	for i := range messages {
+		res[i] = MessageToResponse(&messages[i])
+	}
+	return res
+}
+
+type DMChannelResponse struct {
+	ID             string   `json:"id"`
+	Type           string   `json:"type"`
+	Name           string   `json:"name,omitempty"`
+	Icon           string   `json:"icon,omitempty"`
+	OwnerID        string   `json:"ownerId,omitempty"`
+	RecipientIDs   []string `json:"recipientIds"`
+	LastActivityAt string   `json:"lastActivityAt"`
+	CreatedAt      string   `json:"createdAt"`
+}
+
+func DMChannelToResponse(ch *domain.DMChannel) DMChannelResponse {
+	return DMChannelResponse{
+		ID:             ch.ID,
+		Type:           string(ch.Type),
+		Name:           ch.Name,
+		Icon:           ch.Icon,
+		OwnerID:        ch.OwnerID,
+		RecipientIDs:   ch.RecipientIDs,
+		LastActivityAt: ch.LastActivityAt.Format(time.RFC3339),
+		CreatedAt:      ch.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func DMChannelsToResponse(channels []domain.DMChannel) []DMChannelResponse {
+	res := make([]DMChannelResponse, len(channels))
+	for i := range channels {
+		res[i] = DMChannelToResponse(&channels[i])
+	}
+	return res
+}
+
+type MessageDeleteResponse struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channelId"`
+}
+
+type AutoModRuleResponse struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Trigger        string                 `json:"trigger"`
+	Config         domain.AutoModConfig   `json:"config"`
+	Actions        []domain.AutoModAction `json:"actions"`
+	ExemptChannels []string               `json:"exemptChannels"`
+	ExemptAdmins   bool                   `json:"exemptAdmins"`
+	Enabled        bool                   `json:"enabled"`
+	CreatorID      string                 `json:"creatorId"`
+	CreatedAt      string                 `json:"createdAt"`
+	UpdatedAt      string                 `json:"updatedAt"`
+}
+
+func AutoModRuleToResponse(rule *domain.AutoModRule) AutoModRuleResponse {
+	return AutoModRuleResponse{
+		ID:             rule.ID,
+		Name:           rule.Name,
+		Trigger:        string(rule.Trigger),
+		Config:         rule.Config,
+		Actions:        rule.Actions,
+		ExemptChannels: rule.ExemptChannels,
+		ExemptAdmins:   rule.ExemptAdmins,
+		Enabled:        rule.Enabled,
+		CreatorID:      rule.CreatorID,
+		CreatedAt:      rule.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      rule.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func AutoModRulesToResponse(rules []domain.AutoModRule) []AutoModRuleResponse {
+	res := make([]AutoModRuleResponse, len(rules))
+	for i := range rules {
+		res[i] = AutoModRuleToResponse(&rules[i])
+	}
+	return res
+}
+
+type AutoModFlagResponse struct {
+	ID        string `json:"id"`
+	RuleID    string `json:"ruleId"`
+	UserID    string `json:"userId"`
+	ChannelID string `json:"channelId"`
+	Content   string `json:"content"`
+	Blocked   bool   `json:"blocked"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func AutoModFlagsToResponse(flags []domain.AutoModFlag) []AutoModFlagResponse {
+	res := make([]AutoModFlagResponse, len(flags))
+	for i, f := range flags {
+		res[i] = AutoModFlagResponse{
+			ID:        f.ID,
+			RuleID:    f.RuleID,
+			UserID:    f.UserID,
+			ChannelID: f.ChannelID,
+			Content:   f.Content,
+			Blocked:   f.Blocked,
+			CreatedAt: f.CreatedAt.Format(time.RFC3339),
+		}
+	}
+	return res
+}
+
+type ReportSnapshotResponse struct {
+	AuthorID  string `json:"authorId"`
+	WebhookID string `json:"webhookId,omitempty"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type ReportResponse struct {
+	ID           string                 `json:"id"`
+	ReporterID   string                 `json:"reporterId"`
+	TargetType   string                 `json:"targetType"`
+	TargetUserID string                 `json:"targetUserId"`
+	MessageID    string                 `json:"messageId,omitempty"`
+	ChannelID    string                 `json:"channelId,omitempty"`
+	Category     string                 `json:"category"`
+	Reason       string                 `json:"reason"`
+	Snapshot     ReportSnapshotResponse `json:"snapshot"`
+	Status       string                 `json:"status"`
+	ModeratorID  string                 `json:"moderatorId,omitempty"`
+	Note         string                 `json:"note"`
+	CreatedAt    string                 `json:"createdAt"`
+	UpdatedAt    string                 `json:"updatedAt"`
+}
+
+func ReportToResponse(r *domain.Report) ReportResponse {
+	return ReportResponse{
+		ID:           r.ID,
+		ReporterID:   r.ReporterID,
+		TargetType:   string(r.TargetType),
+		TargetUserID: r.TargetUserID,
+		MessageID:    r.MessageID,
+		ChannelID:    r.ChannelID,
+		Category:     string(r.Category),
+		Reason:       r.Reason,
+		Snapshot: ReportSnapshotResponse{
+			AuthorID:  r.Snapshot.AuthorID,
+			WebhookID: r.Snapshot.WebhookID,
+			Username:  r.Snapshot.Username,
+			Content:   r.Snapshot.Content,
+			CreatedAt: r.Snapshot.CreatedAt.Format(time.RFC3339),
+		},
+		Status:      string(r.Status),
+		ModeratorID: r.ModeratorID,
+		Note:        r.Note,
+		CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   r.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func ReportsToResponse(reports []domain.Report) []ReportResponse {
+	res := make([]ReportResponse, len(reports))
+	for i := range reports {
+		res[i] = ReportToResponse(&reports[i])
+	}
+	return res
+}
+
+type RelationshipResponse struct {
+	UserID    string `json:"userId"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func RelationshipToResponse(rel *domain.Relationship) RelationshipResponse {
+	return RelationshipResponse{
+		UserID:    rel.TargetID,
+		Type:      string(rel.Type),
+		CreatedAt: rel.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func RelationshipsToResponse(relationships []domain.Relationship) []RelationshipResponse {
+	res := make([]RelationshipResponse, len(relationships))
+	for i := range relationships {
+		res[i] = RelationshipToResponse(&relationships[i])
+	}
+	return res
+}
+
+type SessionResponse struct {
+	ID         string `json:"id"`
+	Device     string `json:"device"`
+	IPAddress  string `json:"ipAddress"`
+	Current    bool   `json:"current"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	ExpiresAt  string `json:"expiresAt"`
+}
+
+// SessionsToResponse flags the session the request was made from as current.
+func SessionsToResponse(sessions []domain.Session, currentID string) []SessionResponse {
+	res := make([]SessionResponse, len(sessions))
+	for i, s := range sessions {
+		res[i] = SessionResponse{
+			ID:         s.ID,
+			Device:     s.Device,
+			IPAddress:  s.IPAddress,
+			Current:    s.ID == currentID,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			LastUsedAt: s.LastUsedAt.Format(time.RFC3339),
+			ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+		}
+	}
+	return res
+}
+
+type MFAStatusResponse struct {
+	TOTPEnabled            bool `json:"totpEnabled"`
+	RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+	Passkeys               int  `json:"passkeys"`
+	Required               bool `json:"required"`
+}
+
+func MFAStatusToResponse(s *application.MFAStatus) MFAStatusResponse {
+	return MFAStatusResponse{
+		TOTPEnabled:            s.TOTPEnabled,
+		RecoveryCodesRemaining: s.RecoveryCodesRemaining,
+		Passkeys:               s.Passkeys,
+		Required:               s.Required,
+	}
+}
+
+type PasskeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports"`
+	Synced     bool     `json:"synced"`
+	LastUsedAt *string  `json:"lastUsedAt"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+func PasskeyToResponse(p *domain.Passkey) PasskeyResponse {
+	res := PasskeyResponse{
+		ID:         p.ID,
+		Name:       p.Name,
+		Transports: p.Transports,
+		Synced:     p.BackupState,
+		LastUsedAt: formatOptionalTime(p.LastUsedAt),
+		CreatedAt:  p.CreatedAt.Format(time.RFC3339),
+	}
+	if res.Transports == nil {
+		res.Transports = []string{}
+	}
+	return res
+}
+
+func PasskeysToResponse(passkeys []domain.Passkey) []PasskeyResponse {
+	res := make([]PasskeyResponse, len(passkeys))
+	for i := range passkeys {
+		res[i] = PasskeyToResponse(&passkeys[i])
+	}
+	return res
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expiresAt"`
+	LastUsedAt *string  `json:"lastUsedAt"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+// CreatedPersonalAccessTokenResponse carries the secret of a new token, which
+// is only ever returned once.
+type CreatedPersonalAccessTokenResponse struct {
+	PersonalAccessTokenResponse
+	Token string `json:"token"`
+}
+
+func PersonalAccessTokenToResponse(t *domain.PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     scopesToResponse(t.Scopes),
+		ExpiresAt:  formatOptionalTime(t.ExpiresAt),
+		LastUsedAt: formatOptionalTime(t.LastUsedAt),
+		CreatedAt:  t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func PersonalAccessTokensToResponse(tokens []domain.PersonalAccessToken) []PersonalAccessTokenResponse {
+	res := make([]PersonalAccessTokenResponse, len(tokens))
+	for i := range tokens {
+		res[i] = PersonalAccessTokenToResponse(&tokens[i])
+	}
+	return res
+}
+
+func scopesToResponse(scopes []domain.TokenScope) []string {
+	res := make([]string, len(scopes))
+	for i, s := range scopes {
+		res[i] = string(s)
+	}
+	return res
+}
+
+type BotResponse struct {
+	User         UserResponse `json:"user"`
+	OwnerID      string       `json:"ownerId"`
+	Permissions  []string     `json:"permissions"`
+	AuthorizedAt *string      `json:"authorizedAt"`
+	CreatedAt    string       `json:"createdAt"`
+}
+
+// CreatedBotResponse carries the token of a new bot, which is only ever
+// returned once.
+type CreatedBotResponse struct {
+	BotResponse
+	Token string `json:"token"`
+}
+
+type BotTokenResponse struct {
+	Token string `json:"token"`
+}
+
+func BotToResponse(b *application.BotAccount) BotResponse {
+	return BotResponse{
+		User:         UserToResponse(b.User),
+		OwnerID:      b.Bot.OwnerID,
+		Permissions:  scopesToResponse(b.Bot.Permissions),
+		AuthorizedAt: formatOptionalTime(b.Bot.AuthorizedAt),
+		CreatedAt:    b.Bot.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func BotsToResponse(bots []application.BotAccount) []BotResponse {
+	res := make([]BotResponse, len(bots))
+	for i := range bots {
+		res[i] = BotToResponse(&bots[i])
+	}
+	return res
+}
+
+type WebhookResponse struct {
+	ID        string  `json:"id"`
+	ChannelID string  `json:"channelId"`
+	Name      string  `json:"name"`
+	AvatarURL string  `json:"avatarUrl,omitempty"`
+	CreatorID *string `json:"creatorId"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// WebhookTokenResponse carries the token of a webhook and the URL it is
+// executed at, which are only ever returned when the token is generated.
+type WebhookTokenResponse struct {
+	WebhookResponse
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+func WebhookToResponse(w *domain.Webhook) WebhookResponse {
+	res := WebhookResponse{
+		ID:        w.ID,
+		ChannelID: w.ChannelID,
+		Name:      w.Name,
+		AvatarURL: w.AvatarURL,
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+	}
+	if w.CreatorID != "" {
+		res.CreatorID = &w.CreatorID
+	}
+	return res
+}
+
+func WebhooksToResponse(webhooks []domain.Webhook) []WebhookResponse {
+	res := make([]WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		res[i] = WebhookToResponse(&webhooks[i])
+	}
+	return res
+}
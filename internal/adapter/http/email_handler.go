@@ -0,0 +1,81 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type EmailHandler struct {
+	svc    *application.EmailService
+	logger *zap.Logger
+}
+
+func NewEmailHandler(svc *application.EmailService, logger *zap.Logger) *EmailHandler {
+	return &EmailHandler{svc: svc, logger: logger}
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+// Verify is public so that the link works even when opened in a browser
+// where the user is not logged in.
+func (h *EmailHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	user, err := h.svc.Verify(r.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidVerificationToken) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"invalid or expired email verification token", "INVALID_VERIFICATION_TOKEN"})
+			return
+		}
+		if errors.Is(err, application.ErrEmailTaken) {
+			writeJSON(w, http.StatusConflict, errorResponse{"email already taken", "EMAIL_TAKEN"})
+			return
+		}
+		h.logger.Error("failed to verify email", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("email verified", zap.String("userId", user.ID))
+	writeJSON(w, http.StatusOK, UserToResponse(user))
+}
+
+func (h *EmailHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	if err := h.svc.Resend(r.Context(), uc.UserID); err != nil {
+		if errors.Is(err, application.ErrEmailAlreadyVerified) {
+			writeJSON(w, http.StatusConflict, errorResponse{"email is already verified", "EMAIL_ALREADY_VERIFIED"})
+			return
+		}
+		if errors.Is(err, application.ErrUserNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+			return
+		}
+		h.logger.Error("failed to resend email verification", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
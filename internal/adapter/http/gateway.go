@@ -0,0 +1,382 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const (
+	gatewayWriteWait  = 10 * time.Second
+	gatewayPongWait   = 60 * time.Second
+	gatewayPingPeriod = gatewayPongWait * 9 / 10
+	gatewayReadLimit  = 4096
+	gatewaySendBuffer = 64
+)
+
+// Gateway pushes real-time events to connected clients over WebSocket. It
+// implements domain.EventPublisher, domain.SessionTerminator,
+// domain.ConnectionTerminator and domain.PresenceTracker.
+type Gateway struct {
+	tokens   domain.TokenProvider
+	logins   domain.SessionRepository
+	bots     middleware.BotAuthenticator
+	origins  []string
+	upgrader websocket.Upgrader
+	logger   *zap.Logger
+	mu       sync.RWMutex
+	sessions map[string]map[*gatewaySession]struct{}
+}
+
+// NewGateway creates a gateway accepting connections from pages of the given
+// origins.
+func NewGateway(tokens domain.TokenProvider, logins domain.SessionRepository, bots middleware.BotAuthenticator, origins []string, logger *zap.Logger) *Gateway {
+	g := &Gateway{
+		tokens:   tokens,
+		logins:   logins,
+		bots:     bots,
+		logger:   logger,
+		sessions: make(map[string]map[*gatewaySession]struct{}),
+	}
+	for _, origin := range origins {
+		g.origins = append(g.origins, strings.TrimSuffix(origin, "/"))
+	}
+	g.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     g.checkOrigin,
+	}
+	return g
+}
+
+// checkOrigin rejects connections opened by pages of other origins. The
+// access token may be passed in the query string, which such a page could
+// have obtained, so the bearer token alone is not enough. Clients outside of
+// browsers send no origin.
+func (g *Gateway) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return slices.ContainsFunc(g.origins, func(allowed string) bool {
+		return strings.EqualFold(allowed, origin)
+	})
+}
+
+type gatewaySession struct {
+	userID    string
+	sessionID string
+	// intents holds the intents a bot declared, or nil for a user, who
+	// receives every event.
+	intents map[domain.GatewayIntent]bool
+	conn    *websocket.Conn
+	send    chan []byte
+}
+
+// subscribed reports whether the session receives events of the given type.
+func (s *gatewaySession) subscribed(eventType domain.EventType) bool {
+	intent := eventType.Intent()
+	return s.intents == nil || intent == "" || s.intents[intent]
+}
+
+type gatewayEvent struct {
+	Type domain.EventType `json:"type"`
+	Data any              `json:"data"`
+}
+
+type readyResponse struct {
+	UserID  string   `json:"userId"`
+	Intents []string `json:"intents,omitempty"`
+}
+
+// ServeHTTP upgrades the request to a WebSocket session. Browsers cannot set
+// headers on WebSocket requests, so the access token may also be passed in
+// the token query parameter. Bots connect with their bot token and declare
+// the intents they subscribe to in the comma-separated intents query
+// parameter.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tokenString := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		tokenString = strings.TrimPrefix(header, "Bearer ")
+	}
+	if tokenString == "" {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"missing access token", "UNAUTHORIZED"})
+		return
+	}
+
+	var session *gatewaySession
+	if strings.HasPrefix(tokenString, domain.BotTokenPrefix) {
+		session = g.authenticateBot(w, r, tokenString)
+	} else {
+		session = g.authenticateUser(w, r, tokenString)
+	}
+	if session == nil {
+		// The error response has already been written.
+		return
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response.
+		g.logger.Debug("gateway upgrade failed", zap.Error(err))
+		return
+	}
+	session.conn = conn
+	session.send = make(chan []byte, gatewaySendBuffer)
+
+	g.register(session)
+	g.logger.Info("gateway session opened", zap.String("userId", session.userID))
+
+	go g.writePump(session)
+	// READY is meant for this connection only, not the user's other devices.
+	ready := readyResponse{UserID: session.userID}
+	for intent := range session.intents {
+		ready.Intents = append(ready.Intents, string(intent))
+	}
+	slices.Sort(ready.Intents)
+	if payload, err := json.Marshal(gatewayEvent{Type: domain.EventReady, Data: ready}); err == nil {
+		session.send <- payload
+	}
+	g.readPump(session)
+}
+
+// authenticateUser checks the access token of a user, or writes an error
+// response and returns nil.
+func (g *Gateway) authenticateUser(w http.ResponseWriter, r *http.Request, tokenString string) *gatewaySession {
+	claims, err := g.tokens.ValidateToken(tokenString)
+	if err != nil || claims.Type != domain.AccessToken {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid or expired token", "UNAUTHORIZED"})
+		return nil
+	}
+
+	// Access tokens outlive the session they were issued for, so check that
+	// it has not been revoked since.
+	login, err := g.logins.GetByID(r.Context(), claims.SessionID)
+	if err != nil {
+		g.logger.Error("failed to get session", zap.String("sessionId", claims.SessionID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return nil
+	}
+	if login == nil || login.UserID != claims.UserID || !login.Active(time.Now().UTC()) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"session has been revoked", "UNAUTHORIZED"})
+		return nil
+	}
+	return &gatewaySession{userID: claims.UserID, sessionID: claims.SessionID}
+}
+
+// intentPermissions are the permissions bots need to declare an intent, so
+// that the gateway does not hand them events the API would refuse them.
+var intentPermissions = map[domain.GatewayIntent]domain.TokenScope{
+	domain.IntentMessages: domain.ScopeMessagesRead,
+}
+
+// authenticateBot checks the token, the authorization and the declared
+// intents of a bot, or writes an error response and returns nil.
+func (g *Gateway) authenticateBot(w http.ResponseWriter, r *http.Request, tokenString string) *gatewaySession {
+	bot, err := g.bots.Authenticate(r.Context(), tokenString)
+	if err != nil {
+		g.logger.Error("failed to authenticate bot", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return nil
+	}
+	if bot == nil {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid bot token", "UNAUTHORIZED"})
+		return nil
+	}
+	if !bot.Authorized() {
+		writeJSON(w, http.StatusForbidden, errorResponse{"bot has not been authorized", "BOT_NOT_AUTHORIZED"})
+		return nil
+	}
+
+	intents := make(map[domain.GatewayIntent]bool)
+	for _, name := range strings.Split(r.URL.Query().Get("intents"), ",") {
+		intent := domain.GatewayIntent(strings.TrimSpace(name))
+		if intent == "" {
+			continue
+		}
+		if !intent.Valid() {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"unknown intent " + string(intent), "INVALID_INTENTS"})
+			return nil
+		}
+		if scope, ok := intentPermissions[intent]; ok && !bot.HasPermission(scope) {
+			writeJSON(w, http.StatusForbidden, errorResponse{"bot lacks the " + string(scope) + " permission", "MISSING_SCOPE"})
+			return nil
+		}
+		intents[intent] = true
+	}
+	if len(intents) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"bots must declare their intents", "INVALID_INTENTS"})
+		return nil
+	}
+	return &gatewaySession{userID: bot.UserID, intents: intents}
+}
+
+// Publish sends the event to every session of the given users. Sessions that
+// cannot keep up are disconnected rather than allowed to block the caller.
+func (g *Gateway) Publish(userIDs []string, event domain.Event) {
+	payload, err := json.Marshal(gatewayEvent{Type: event.Type, Data: eventPayload(event.Data)})
+	if err != nil {
+		g.logger.Error("failed to encode gateway event", zap.String("type", string(event.Type)), zap.Error(err))
+		return
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, userID := range userIDs {
+		for session := range g.sessions[userID] {
+			if !session.subscribed(event.Type) {
+				continue
+			}
+			select {
+			case session.send <- payload:
+			default:
+				g.logger.Warn("gateway session too slow, disconnecting", zap.String("userId", userID))
+				session.conn.Close()
+			}
+		}
+	}
+}
+
+// TerminateSession closes every connection opened with an access token of the
+// given login session.
+func (g *Gateway) TerminateSession(sessionID string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for _, sessions := range g.sessions {
+		for session := range sessions {
+			if session.sessionID != sessionID {
+				continue
+			}
+			// WriteControl and Close are safe to call alongside the write pump.
+			session.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(gatewayWriteWait))
+			session.conn.Close()
+			g.logger.Info("gateway session terminated", zap.String("userId", session.userID), zap.String("sessionId", sessionID))
+		}
+	}
+}
+
+// TerminateUser closes every connection of the given user.
+func (g *Gateway) TerminateUser(userID string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "credentials revoked")
+	for session := range g.sessions[userID] {
+		session.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(gatewayWriteWait))
+		session.conn.Close()
+	}
+	if len(g.sessions[userID]) > 0 {
+		g.logger.Info("gateway sessions terminated", zap.String("userId", userID))
+	}
+}
+
+// Online reports whether the user has at least one open connection.
+func (g *Gateway) Online(userID string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.sessions[userID]) > 0
+}
+
+// eventPayload converts domain objects to the same representation the REST
+// API uses.
+func eventPayload(data any) any {
+	switch v := data.(type) {
+	case *domain.Message:
+		return MessageToResponse(v)
+	case *domain.DMChannel:
+		return DMChannelToResponse(v)
+	case *domain.MessageDeleteEvent:
+		return MessageDeleteResponse{ID: v.ID, ChannelID: v.ChannelID}
+	case *domain.Relationship:
+		return RelationshipToResponse(v)
+	case *domain.Member:
+		return MemberToResponse(v)
+	}
+	return data
+}
+
+func (g *Gateway) register(session *gatewaySession) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.sessions[session.userID] == nil {
+		g.sessions[session.userID] = make(map[*gatewaySession]struct{})
+	}
+	g.sessions[session.userID][session] = struct{}{}
+}
+
+func (g *Gateway) unregister(session *gatewaySession) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.sessions[session.userID][session]; !ok {
+		return
+	}
+	delete(g.sessions[session.userID], session)
+	if len(g.sessions[session.userID]) == 0 {
+		delete(g.sessions, session.userID)
+	}
+	close(session.send)
+}
+
+// readPump discards client messages and keeps the connection alive until the
+// client disconnects or stops answering pings.
+func (g *Gateway) readPump(session *gatewaySession) {
+	defer func() {
+		g.unregister(session)
+		session.conn.Close()
+		g.logger.Info("gateway session closed", zap.String("userId", session.userID))
+	}()
+
+	session.conn.SetReadLimit(gatewayReadLimit)
+	session.conn.SetReadDeadline(time.Now().Add(gatewayPongWait))
+	session.conn.SetPongHandler(func(string) error {
+		return session.conn.SetReadDeadline(time.Now().Add(gatewayPongWait))
+	})
+
+	for {
+		if _, _, err := session.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (g *Gateway) writePump(session *gatewaySession) {
+	ticker := time.NewTicker(gatewayPingPeriod)
+	defer func() {
+		ticker.Stop()
+		session.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-session.send:
+			session.conn.SetWriteDeadline(time.Now().Add(gatewayWriteWait))
+			if !ok {
+				session.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := session.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			session.conn.SetWriteDeadline(time.Now().Add(gatewayWriteWait))
+			if err := session.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
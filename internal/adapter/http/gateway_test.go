@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// staticBots authenticates a single bot, whatever its token.
+type staticBots struct {
+	bot *domain.Bot
+}
+
+func (b staticBots) Authenticate(context.Context, string) (*domain.Bot, error) {
+	return b.bot, nil
+}
+
+func TestGatewayRefusesIntentsBeyondBotPermissions(t *testing.T) {
+	authorizedAt := time.Now()
+	bot := &domain.Bot{
+		UserID:       "bot",
+		Permissions:  []domain.TokenScope{domain.ScopeMessagesWrite},
+		AuthorizedAt: &authorizedAt,
+	}
+	g := NewGateway(nil, nil, staticBots{bot}, nil, zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/gateway?intents=channels,messages", nil)
+	req.Header.Set("Authorization", "Bearer "+domain.BotTokenPrefix+"secret")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if rec.Code != http.StatusForbidden || body.Code != "MISSING_SCOPE" {
+		t.Errorf("connect with the messages intent = %d %s, want %d MISSING_SCOPE", rec.Code, body.Code, http.StatusForbidden)
+	}
+}
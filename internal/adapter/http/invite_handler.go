@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type InviteHandler struct {
+	svc    *application.InviteService
+	logger *zap.Logger
+}
+
+func NewInviteHandler(svc *application.InviteService, logger *zap.Logger) *InviteHandler {
+	return &InviteHandler{svc: svc, logger: logger}
+}
+
+type createInviteRequest struct {
+	ChannelID string `json:"channelId" validate:"omitempty,max=64"`
+	MaxUses   int    `json:"maxUses" validate:"min=0,max=1000"`
+	MaxAge    int    `json:"maxAge" validate:"min=0,max=2592000"`
+	Temporary bool   `json:"temporary"`
+}
+
+func (h *InviteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	invite, err := h.svc.Create(r.Context(), uc.UserID, application.CreateInviteInput{
+		ChannelID: req.ChannelID,
+		MaxUses:   req.MaxUses,
+		MaxAge:    time.Duration(req.MaxAge) * time.Second,
+		Temporary: req.Temporary,
+	})
+	if err != nil {
+		if errors.Is(err, application.ErrChannelNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{"channel not found", "NOT_FOUND"})
+			return
+		}
+		h.logger.Error("failed to create invite", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("invite created", zap.String("code", invite.Code), zap.String("creator", uc.UserID))
+	writeJSON(w, http.StatusCreated, InviteToResponse(invite))
+}
+
+func (h *InviteHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.svc.GetAll(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get all invites", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InvitesToResponse(invites))
+}
+
+func (h *InviteHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	invite, channel, err := h.svc.Preview(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, application.ErrInviteNotFound) || errors.Is(err, application.ErrInviteInvalid) {
+			writeJSON(w, http.StatusNotFound, errorResponse{"invalid or expired invite", "INVALID_INVITE"})
+			return
+		}
+		h.logger.Error("failed to preview invite", zap.String("code", code), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InviteToPreviewResponse(invite, channel))
+}
+
+func (h *InviteHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+
+	invite, err := h.svc.Accept(r.Context(), code, uc.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrInviteNotFound), errors.Is(err, application.ErrInviteInvalid):
+			writeJSON(w, http.StatusNotFound, errorResponse{"invalid or expired invite", "INVALID_INVITE"})
+		case errors.Is(err, application.ErrAlreadyMember):
+			writeJSON(w, http.StatusConflict, errorResponse{"already a member", "ALREADY_MEMBER"})
+		case errors.Is(err, application.ErrBanned):
+			writeJSON(w, http.StatusForbidden, errorResponse{"you are banned from this community", "BANNED"})
+		default:
+			h.logger.Error("failed to accept invite", zap.String("code", code), zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		}
+		return
+	}
+
+	h.logger.Info("invite accepted", zap.String("code", code), zap.String("user", uc.UserID))
+	writeJSON(w, http.StatusOK, InviteToResponse(invite))
+}
+
+func (h *InviteHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+
+	if err := h.svc.Revoke(r.Context(), code, uc.UserID); err != nil {
+		switch {
+		case errors.Is(err, application.ErrInviteNotFound):
+			writeJSON(w, http.StatusNotFound, errorResponse{"invite not found", "NOT_FOUND"})
+		case errors.Is(err, application.ErrForbidden):
+			writeJSON(w, http.StatusForbidden, errorResponse{"only the creator can revoke this invite", "FORBIDDEN"})
+		default:
+			h.logger.Error("failed to revoke invite", zap.String("code", code), zap.Error(err))
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		}
+		return
+	}
+
+	h.logger.Info("invite revoked", zap.String("code", code))
+	w.WriteHeader(http.StatusNoContent)
+}
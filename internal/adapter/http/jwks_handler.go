@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-jose/go-jose/v4"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type JWKSHandler struct {
+	keys   domain.KeySet
+	logger *zap.Logger
+}
+
+func NewJWKSHandler(keys domain.KeySet, logger *zap.Logger) *JWKSHandler {
+	return &JWKSHandler{keys: keys, logger: logger}
+}
+
+// Get serves the public keys tokens may be signed with as a JSON Web Key Set,
+// so that bots and companion services can verify tokens without calling the
+// API. Keys are rotated without notice: clients seeing an unknown kid should
+// fetch the set again.
+func (h *JWKSHandler) Get(w http.ResponseWriter, r *http.Request) {
+	keys := h.keys.PublicKeys()
+	set := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, len(keys))}
+	for i, k := range keys {
+		set.Keys[i] = jose.JSONWebKey{
+			Key:       k.Key,
+			KeyID:     k.ID,
+			Algorithm: k.Algorithm,
+			Use:       "sig",
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, set)
+}
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type MessageHandler struct {
+	svc    *application.MessageService
+	logger *zap.Logger
+}
+
+func NewMessageHandler(svc *application.MessageService, logger *zap.Logger) *MessageHandler {
+	return &MessageHandler{svc: svc, logger: logger}
+}
+
+type messageRequest struct {
+	Content string `json:"content" validate:"required,min=1,max=4000"`
+}
+
+// retryErrorResponse is returned when the client must wait before retrying.
+// RetryAfter is expressed in seconds.
+type retryErrorResponse struct {
+	Error      string  `json:"error"`
+	Code       string  `json:"code"`
+	RetryAfter float64 `json:"retryAfter"`
+}
+
+func (h *MessageHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	message, err := h.svc.Create(r.Context(), channelID, uc.UserID, req.Content)
+	if err != nil {
+		h.writeError(w, "failed to create message", channelID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, MessageToResponse(message))
+}
+
+func (h *MessageHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	q := r.URL.Query()
+
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"limit must be a positive integer", "VALIDATION_ERROR"})
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.svc.GetByChannel(r.Context(), channelID, uc.UserID, q.Get("before"), limit)
+	if err != nil {
+		h.writeError(w, "failed to get messages", channelID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessagesToResponse(messages))
+}
+
+func (h *MessageHandler) Update(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageId")
+
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	message, err := h.svc.Update(r.Context(), channelID, messageID, uc.UserID, req.Content)
+	if err != nil {
+		h.writeError(w, "failed to update message", messageID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageToResponse(message))
+}
+
+func (h *MessageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageId")
+
+	if err := h.svc.Delete(r.Context(), channelID, messageID, uc.UserID); err != nil {
+		h.writeError(w, "failed to delete message", messageID, err)
+		return
+	}
+
+	h.logger.Info("message deleted", zap.String("id", messageID), zap.String("by", uc.UserID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MessageHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	var slowmode *application.SlowmodeError
+	var automod *application.AutoModError
+	switch {
+	case errors.As(err, &slowmode):
+		writeRetryAfter(w, "you are sending messages too quickly", "SLOWMODE", slowmode.RetryAfter)
+	case errors.As(err, &automod):
+		writeJSON(w, http.StatusBadRequest, errorResponse{automod.Error(), "AUTOMOD_" + strings.ToUpper(string(automod.Trigger))})
+	case errors.Is(err, application.ErrChannelNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"channel not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrMessageNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"message not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrNotTextChannel):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"channel does not accept messages", "NOT_TEXT_CHANNEL"})
+	case errors.Is(err, application.ErrTimedOut):
+		writeJSON(w, http.StatusForbidden, errorResponse{"you are timed out", "TIMED_OUT"})
+	case errors.Is(err, application.ErrEmailNotVerified):
+		writeJSON(w, http.StatusForbidden, errorResponse{"verify your email address before posting", "EMAIL_NOT_VERIFIED"})
+	case errors.Is(err, application.ErrMemberNotFound):
+		writeJSON(w, http.StatusForbidden, errorResponse{"you are not a member of this community", "NOT_MEMBER"})
+	case errors.Is(err, application.ErrForbidden):
+		writeJSON(w, http.StatusForbidden, errorResponse{"forbidden", "FORBIDDEN"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
+
+// writeRetryAfter writes a 429 response carrying the delay both in the
+// Retry-After header, rounded up to whole seconds, and in the body.
+func writeRetryAfter(w http.ResponseWriter, msg, code string, retryAfter time.Duration) {
+	seconds := retryAfter.Seconds()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(seconds))))
+	writeJSON(w, http.StatusTooManyRequests, retryErrorResponse{msg, code, math.Round(seconds*1000) / 1000})
+}
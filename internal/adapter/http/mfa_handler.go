@@ -0,0 +1,182 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type MFAHandler struct {
+	svc    *application.MFAService
+	logger *zap.Logger
+}
+
+func NewMFAHandler(svc *application.MFAService, logger *zap.Logger) *MFAHandler {
+	return &MFAHandler{svc: svc, logger: logger}
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	status, err := h.svc.Status(r.Context(), uc.UserID)
+	if err != nil {
+		h.writeError(w, "failed to get mfa status", uc.UserID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MFAStatusToResponse(status))
+}
+
+// BeginTOTP generates a new authenticator secret. It only takes effect once
+// confirmed with a code through ConfirmTOTP.
+func (h *MFAHandler) BeginTOTP(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	secret, uri, err := h.svc.BeginTOTPEnrollment(r.Context(), uc.UserID)
+	if err != nil {
+		h.writeError(w, "failed to begin totp enrollment", uc.UserID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, totpEnrollmentResponse{Secret: secret, URI: uri})
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery
+// codes. They cannot be retrieved again afterwards.
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	req, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.svc.ConfirmTOTP(r.Context(), uc.UserID, req.Code)
+	if err != nil {
+		h.writeError(w, "failed to confirm totp enrollment", uc.UserID, err)
+		return
+	}
+
+	h.logger.Info("two-factor authentication enabled", zap.String("userId", uc.UserID))
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	req, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DisableTOTP(r.Context(), uc.UserID, req.Code); err != nil {
+		h.writeError(w, "failed to disable totp", uc.UserID, err)
+		return
+	}
+
+	h.logger.Info("two-factor authentication disabled", zap.String("userId", uc.UserID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	req, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(r.Context(), uc.UserID, req.Code)
+	if err != nil {
+		h.writeError(w, "failed to regenerate recovery codes", uc.UserID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Reset lets an administrator remove the second factors of a user who lost
+// access to them.
+func (h *MFAHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Reset(r.Context(), id); err != nil {
+		h.writeError(w, "failed to reset mfa", id, err)
+		return
+	}
+
+	h.logger.Info("two-factor authentication reset by admin", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MFAHandler) writeError(w http.ResponseWriter, msg, userID string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrMFAAlreadyEnabled):
+		writeJSON(w, http.StatusConflict, errorResponse{"two-factor authentication is already enabled", "MFA_ALREADY_ENABLED"})
+	case errors.Is(err, application.ErrMFANotEnabled):
+		writeJSON(w, http.StatusConflict, errorResponse{"two-factor authentication is not enabled", "MFA_NOT_ENABLED"})
+	case errors.Is(err, application.ErrMFANotEnrolled):
+		writeJSON(w, http.StatusConflict, errorResponse{"two-factor authentication enrollment has not been started", "MFA_NOT_ENROLLED"})
+	case errors.Is(err, application.ErrMFARequired):
+		writeJSON(w, http.StatusForbidden, errorResponse{"two-factor authentication is required for administrators", "MFA_REQUIRED"})
+	case errors.Is(err, application.ErrInvalidMFACode):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid two-factor authentication code", "INVALID_MFA_CODE"})
+	default:
+		h.logger.Error(msg, zap.String("userId", userID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
+
+func decodeMFACode(w http.ResponseWriter, r *http.Request) (mfaCodeRequest, bool) {
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return req, false
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return req, false
+	}
+	return req, true
+}
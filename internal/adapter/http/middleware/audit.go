@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// AuditReasonHeader carries an optional, URL-encoded justification that is
+// stored alongside audit log entries created by the request.
+const AuditReasonHeader = "X-Audit-Log-Reason"
+
+const maxAuditReasonLength = 512
+
+// WithActor attributes the request to the authenticated user for the audit
+// log. It must be mounted after IsAuthenticated.
+func WithActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := requestUserID(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reason := r.Header.Get(AuditReasonHeader)
+		if unescaped, err := url.PathUnescape(reason); err == nil {
+			reason = unescaped
+		}
+		if runes := []rune(reason); len(runes) > maxAuditReasonLength {
+			reason = string(runes[:maxAuditReasonLength])
+		}
+
+		ctx := domain.ContextWithActor(r.Context(), domain.Actor{UserID: userID, Reason: reason})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
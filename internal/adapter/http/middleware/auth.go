@@ -1,14 +1,35 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
-func IsAuthenticated(tokenProvider domain.TokenProvider) func(http.Handler) http.Handler {
+// AccessTokenAuthenticator checks personal access tokens.
+type AccessTokenAuthenticator interface {
+	// Authenticate returns the token matching secret, or nil when it is not
+	// valid.
+	Authenticate(ctx context.Context, secret string) (*domain.PersonalAccessToken, error)
+}
+
+// BotAuthenticator checks bot tokens.
+type BotAuthenticator interface {
+	// Authenticate returns the bot whose token is secret, or nil when it is
+	// not valid.
+	Authenticate(ctx context.Context, secret string) (*domain.Bot, error)
+}
+
+// IsAuthenticated requires a valid access token whose version matches the
+// user's current token version, so that revoked tokens are rejected before
+// they expire, a valid personal access token or a valid bot token. Requests
+// made with a personal access token or a bot token are only let through by
+// RequireScope.
+func IsAuthenticated(tokenProvider domain.TokenProvider, versions domain.TokenVersionStore, accessTokens AccessTokenAuthenticator, bots BotAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
@@ -18,6 +39,33 @@ func IsAuthenticated(tokenProvider domain.TokenProvider) func(http.Handler) http
 			}
 
 			tokenString := strings.TrimPrefix(header, "Bearer ")
+			if strings.HasPrefix(tokenString, domain.PersonalAccessTokenPrefix) {
+				token, err := accessTokens.Authenticate(r.Context(), tokenString)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+					return
+				}
+				if token == nil {
+					writeError(w, http.StatusUnauthorized, "invalid or expired token", "UNAUTHORIZED")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessTokenContextKey, token)))
+				return
+			}
+			if strings.HasPrefix(tokenString, domain.BotTokenPrefix) {
+				bot, err := bots.Authenticate(r.Context(), tokenString)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+					return
+				}
+				if bot == nil {
+					writeError(w, http.StatusUnauthorized, "invalid bot token", "UNAUTHORIZED")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), botContextKey, bot)))
+				return
+			}
+
 			claims, err := tokenProvider.ValidateToken(tokenString)
 			if err != nil {
 				writeError(w, http.StatusUnauthorized, "invalid or expired token", "UNAUTHORIZED")
@@ -29,12 +77,132 @@ func IsAuthenticated(tokenProvider domain.TokenProvider) func(http.Handler) http
 				return
 			}
 
-			ctx := NewUserContext(r.Context(), claims.UserID)
+			version, exists, err := versions.Get(r.Context(), claims.UserID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+				return
+			}
+			if !exists || version != claims.Version {
+				writeError(w, http.StatusUnauthorized, "token has been revoked", "TOKEN_REVOKED")
+				return
+			}
+
+			ctx := NewUserContext(r.Context(), claims.UserID, claims.SessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireScope lets requests made with a personal access token through when
+// the token was granted scope, and attributes them to the token's owner.
+// Requests made by a bot are let through when the community granted it scope.
+// Requests made with a session are always let through. Handlers only see the
+// user of requests made with a personal access token or a bot token once it
+// ran, so routes it does not guard cannot be used with one. It must be
+// mounted after IsAuthenticated.
+func RequireScope(scope domain.TokenScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := UserFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if bot, ok := r.Context().Value(botContextKey).(*domain.Bot); ok {
+				if !bot.HasPermission(scope) {
+					writeError(w, http.StatusForbidden, fmt.Sprintf("bot lacks the %s permission", scope), "MISSING_SCOPE")
+					return
+				}
+				uc := UserContext{UserID: bot.UserID, Bot: bot}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, uc)))
+				return
+			}
+
+			token, ok := r.Context().Value(accessTokenContextKey).(*domain.PersonalAccessToken)
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+				return
+			}
+			if !token.HasScope(scope) {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("personal access token lacks the %s scope", scope), "MISSING_SCOPE")
+				return
+			}
+
+			uc := UserContext{UserID: token.UserID, AccessToken: token}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, uc)))
+		})
+	}
+}
+
+// MFAPolicy decides whether a user meets the instance's two-factor
+// authentication requirement.
+type MFAPolicy interface {
+	Satisfied(ctx context.Context, user *domain.User) (bool, error)
+}
+
+// IsAdmin rejects requests from users that are not administrators, or that
+// have yet to enable two-factor authentication when the instance requires it.
+// It must be mounted after IsAuthenticated.
+func IsAdmin(users domain.UserRepository, mfa MFAPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := requestUserID(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+				return
+			}
+
+			user, err := users.GetByID(r.Context(), userID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+				return
+			}
+			if user == nil || !user.IsAdmin {
+				writeError(w, http.StatusForbidden, "administrator access required", "FORBIDDEN")
+				return
+			}
+
+			satisfied, err := mfa.Satisfied(r.Context(), user)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+				return
+			}
+			if !satisfied {
+				writeError(w, http.StatusForbidden, "two-factor authentication is required for administrators", "MFA_REQUIRED")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsMember rejects requests from users that are not members of the community,
+// such as kicked or banned users. It must be mounted after IsAuthenticated.
+func IsMember(members domain.MemberRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := requestUserID(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+				return
+			}
+
+			member, err := members.GetByUserID(r.Context(), userID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+				return
+			}
+			if member == nil {
+				writeError(w, http.StatusForbidden, "you are not a member of this community", "NOT_MEMBER")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func writeError(w http.ResponseWriter, status int, message, code string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,132 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/adapter/repository"
+	"github.com/tartine-studio/harmony-server/internal/adapter/token"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// okHandler answers 204 to every request it is let through.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+// serve sends a request authorized with the bearer token through h and
+// returns the status and error code of the response.
+func serve(h http.Handler, bearer string) (int, string) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	return rec.Code, body.Code
+}
+
+func TestIsAuthenticatedRejectsStaleTokenVersion(t *testing.T) {
+	ctx := context.Background()
+	db, err := repository.Open(filepath.Join(t.TempDir(), "harmony.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	keyring := token.NewKeyring(repository.NewSigningKeyRepository(db), token.AlgorithmEdDSA, time.Hour)
+	if err := keyring.Load(ctx); err != nil {
+		t.Fatalf("load signing keys: %v", err)
+	}
+	tokens := token.NewJwtService(keyring, "", 15*time.Minute, time.Hour)
+
+	now := time.Now().UTC()
+	user := &domain.User{
+		ID:        "user",
+		Username:  "alice",
+		Email:     "alice@harmony.test",
+		Status:    domain.UserStatusActive,
+		DMPolicy:  domain.DMPolicyEveryone,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repository.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// The cache outlives the test, so only the bump can make the version
+	// change.
+	versions := repository.NewTokenVersionStore(db, time.Hour)
+	h := middleware.IsAuthenticated(tokens, versions, nil, nil)(okHandler)
+
+	before, err := tokens.GenerateTokenPair(user.ID, "session", 0)
+	if err != nil {
+		t.Fatalf("generate tokens: %v", err)
+	}
+	if status, code := serve(h, before.AccessToken); status != http.StatusNoContent {
+		t.Fatalf("request before the bump = %d %s, want %d", status, code, http.StatusNoContent)
+	}
+
+	if err := versions.Bump(ctx, user.ID); err != nil {
+		t.Fatalf("bump token version: %v", err)
+	}
+	if status, code := serve(h, before.AccessToken); status != http.StatusUnauthorized || code != "TOKEN_REVOKED" {
+		t.Errorf("request with the old version = %d %s, want %d TOKEN_REVOKED", status, code, http.StatusUnauthorized)
+	}
+
+	after, err := tokens.GenerateTokenPair(user.ID, "session", 1)
+	if err != nil {
+		t.Fatalf("generate tokens: %v", err)
+	}
+	if status, code := serve(h, after.AccessToken); status != http.StatusNoContent {
+		t.Errorf("request with the new version = %d %s, want %d", status, code, http.StatusNoContent)
+	}
+}
+
+// accessTokens authenticates one personal access token.
+type accessTokens struct {
+	secret string
+	token  *domain.PersonalAccessToken
+}
+
+func (a accessTokens) Authenticate(_ context.Context, secret string) (*domain.PersonalAccessToken, error) {
+	if secret != a.secret {
+		return nil, nil
+	}
+	return a.token, nil
+}
+
+func TestRequireScopeRejectsMissingScope(t *testing.T) {
+	const secret = domain.PersonalAccessTokenPrefix + "secret"
+	tokens := accessTokens{secret, &domain.PersonalAccessToken{
+		ID:     "token",
+		UserID: "user",
+		Scopes: []domain.TokenScope{domain.ScopeMessagesRead},
+	}}
+	authenticate := middleware.IsAuthenticated(nil, nil, tokens, nil)
+
+	tests := []struct {
+		scope  domain.TokenScope
+		status int
+		code   string
+	}{
+		{domain.ScopeMessagesRead, http.StatusNoContent, ""},
+		{domain.ScopeMessagesWrite, http.StatusForbidden, "MISSING_SCOPE"},
+		{domain.ScopeAdmin, http.StatusForbidden, "MISSING_SCOPE"},
+	}
+	for _, tt := range tests {
+		h := authenticate(middleware.RequireScope(tt.scope)(okHandler))
+		if status, code := serve(h, secret); status != tt.status || code != tt.code {
+			t.Errorf("request to a %s route = %d %s, want %d %s", tt.scope, status, code, tt.status, tt.code)
+		}
+	}
+}
@@ -1,20 +1,54 @@
 package middleware
 
-import "context"
+import (
+	"context"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
 
 type contextKey int
 
-const userContextKey contextKey = iota
+const (
+	userContextKey contextKey = iota
+	accessTokenContextKey
+	botContextKey
+)
 
 type UserContext struct {
-	UserID string
+	UserID    string
+	SessionID string
+	// AccessToken is the personal access token the request authenticated
+	// with, or nil for a session.
+	AccessToken *domain.PersonalAccessToken
+	// Bot is the bot the request authenticated as, or nil for a user.
+	Bot *domain.Bot
 }
 
-func NewUserContext(ctx context.Context, userID string) context.Context {
-	return context.WithValue(ctx, userContextKey, UserContext{UserID: userID})
+func NewUserContext(ctx context.Context, userID, sessionID string) context.Context {
+	return context.WithValue(ctx, userContextKey, UserContext{UserID: userID, SessionID: sessionID})
 }
 
+// UserFromContext returns the user the request is made on behalf of. Requests
+// made with a personal access token or a bot token only have one once
+// RequireScope let them through.
 func UserFromContext(ctx context.Context) (UserContext, bool) {
 	uc, ok := ctx.Value(userContextKey).(UserContext)
 	return uc, ok
 }
+
+// requestUserID returns the user who authenticated the request, whether with
+// a session or with a personal access token or bot token that has yet to be
+// checked against the route's scope. Middleware that only identifies the user, such
+// as rate limiting, uses it.
+func requestUserID(ctx context.Context) (string, bool) {
+	if uc, ok := UserFromContext(ctx); ok {
+		return uc.UserID, true
+	}
+	if token, ok := ctx.Value(accessTokenContextKey).(*domain.PersonalAccessToken); ok {
+		return token.UserID, true
+	}
+	if bot, ok := ctx.Value(botContextKey).(*domain.Bot); ok {
+		return bot.UserID, true
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// RateLimiter limits how often clients call the API. Requests are counted
+// against the authenticated user, or against the IP address they come from
+// when there is none.
+type RateLimiter struct {
+	store  domain.RateLimitStore
+	public domain.RateLimit
+	user   domain.RateLimit
+	routes map[string]domain.RateLimit
+}
+
+// NewRateLimiter creates a rate limiter. public limits all requests made from
+// an address without authentication, user all requests of a user, and routes
+// the buckets mounted with Route.
+func NewRateLimiter(store domain.RateLimitStore, public, user domain.RateLimit, routes map[string]domain.RateLimit) *RateLimiter {
+	return &RateLimiter{store: store, public: public, user: user, routes: routes}
+}
+
+// Global counts every request of the client in a single bucket. It must be
+// mounted after IsAuthenticated for requests to be counted against the user.
+func (l *RateLimiter) Global(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requestUserID(r.Context()); ok {
+			l.serve(w, r, next, "global", clientKey(r), l.user)
+		} else {
+			l.serve(w, r, next, "public", clientKey(r), l.public)
+		}
+	})
+}
+
+// Route counts requests to the routes it is mounted on in the named bucket,
+// on top of the global one. Routes mounted with the same name share their
+// buckets. Names without a configured limit are not limited.
+func (l *RateLimiter) Route(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l.serve(w, r, next, name, clientKey(r), l.routes[name])
+		})
+	}
+}
+
+// RouteByParam counts requests to the routes it is mounted on in the named
+// bucket per value of the URL parameter instead of per client, for routes
+// such as webhooks whose callers have no identity of their own. Values are
+// hashed, so that secrets such as webhook tokens can key buckets without
+// being kept in the store.
+func (l *RateLimiter) RouteByParam(name, param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sum := sha256.Sum256([]byte(chi.URLParam(r, param)))
+			l.serve(w, r, next, name, param+":"+hex.EncodeToString(sum[:]), l.routes[name])
+		})
+	}
+}
+
+func (l *RateLimiter) serve(w http.ResponseWriter, r *http.Request, next http.Handler, bucket, key string, limit domain.RateLimit) {
+	if limit.Unlimited() {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	res, err := l.store.Take(r.Context(), bucket+":"+key, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal server error", "INTERNAL_ERROR")
+		return
+	}
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	h.Set("X-RateLimit-Bucket", bucket)
+	if !res.Allowed {
+		retryAfter := res.RetryAfter.Seconds()
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error":      "you are being rate limited",
+			"code":       "RATE_LIMITED",
+			"retryAfter": math.Round(retryAfter*1000) / 1000,
+		})
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// clientKey identifies who the request is counted against. The router runs
+// RealIP, so RemoteAddr only holds an address reported by a reverse proxy
+// when the request came through one of the configured trusted proxies.
+func clientKey(r *http.Request) string {
+	if userID, ok := requestUserID(r.Context()); ok {
+		return "user:" + userID
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
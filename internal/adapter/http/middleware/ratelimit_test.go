@@ -0,0 +1,113 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/adapter/ratelimit"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// newPublicLimiter serves okHandler behind RealIP, trusting proxies in
+// 10.0.0.0/8, and a limit of two requests a minute per address.
+func newPublicLimiter() http.Handler {
+	limiter := middleware.NewRateLimiter(ratelimit.NewMemoryStore(), domain.RateLimit{Burst: 2, Period: time.Minute}, domain.RateLimit{}, nil)
+	realIP := middleware.RealIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	return realIP(limiter.Global(okHandler))
+}
+
+// request sends a request from peer, forwarded for the addresses in
+// forwardedFor if any.
+func request(h http.Handler, peer, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = peer + ":41000"
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiterSetsRetryAfter(t *testing.T) {
+	h := newPublicLimiter()
+
+	for i := range 2 {
+		if rec := request(h, "203.0.113.5", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d = %d, want %d", i, rec.Code, http.StatusNoContent)
+		}
+	}
+
+	rec := request(h, "203.0.113.5", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	// One request comes back every 30 seconds.
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want %q", got, "30")
+	}
+	if got := rec.Header().Get("X-RateLimit-Bucket"); got != "public" {
+		t.Errorf("X-RateLimit-Bucket = %q, want %q", got, "public")
+	}
+
+	if rec := request(h, "203.0.113.6", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("request from another address = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	h := newPublicLimiter()
+
+	// A client talking to the server directly cannot get a fresh bucket by
+	// claiming to be forwarded for someone else.
+	for i, spoofed := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		rec := request(h, "203.0.113.5", spoofed)
+		if want := i < 2; (rec.Code == http.StatusNoContent) != want {
+			t.Errorf("request %d forwarded for %s = %d, want allowed %v", i, spoofed, rec.Code, want)
+		}
+	}
+
+	// Behind a trusted proxy, each client has their own bucket, whatever
+	// addresses they prepend themselves.
+	for i, forwardedFor := range []string{"198.51.100.1", "192.0.2.9, 198.51.100.1", "198.51.100.1"} {
+		rec := request(h, "10.0.0.1", forwardedFor)
+		if want := i < 2; (rec.Code == http.StatusNoContent) != want {
+			t.Errorf("proxied request %d forwarded for %s = %d, want allowed %v", i, forwardedFor, rec.Code, want)
+		}
+	}
+	if rec := request(h, "10.0.0.1", "198.51.100.2"); rec.Code != http.StatusNoContent {
+		t.Errorf("proxied request for another client = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestRouteByParamKeepsWrongTokensOutOfBucket(t *testing.T) {
+	limiter := middleware.NewRateLimiter(ratelimit.NewMemoryStore(), domain.RateLimit{}, domain.RateLimit{}, map[string]domain.RateLimit{
+		"webhooks": {Burst: 2, Period: time.Minute},
+	})
+	r := chi.NewRouter()
+	r.With(limiter.RouteByParam("webhooks", "token")).Post("/webhooks/{id}/{token}", okHandler)
+
+	execute := func(token string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks/hook/"+token, nil))
+		return rec.Code
+	}
+
+	for range 3 {
+		execute("guessed")
+	}
+	if status := execute("guessed"); status != http.StatusTooManyRequests {
+		t.Errorf("request with a guessed token over the limit = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	for i := range 2 {
+		if status := execute("valid"); status != http.StatusNoContent {
+			t.Errorf("request %d with the valid token = %d, want %d", i, status, http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP replaces the request's RemoteAddr with the client address reported
+// by a reverse proxy, but only when the request comes from one of the trusted
+// proxies. X-Forwarded-For is read from the right, skipping the trusted
+// proxies the request went through, so that addresses the client prepended
+// are ignored. X-Real-IP is used when it is absent. Headers from any other
+// peer are ignored, since anyone can send them.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip, ok := forwardedFor(r, trusted); ok {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forwardedFor(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	peer, ok := parseAddr(r.RemoteAddr)
+	if !ok || !isTrusted(peer, trusted) {
+		return netip.Addr{}, false
+	}
+
+	if header := r.Header.Values("X-Forwarded-For"); len(header) > 0 {
+		hops := strings.Split(strings.Join(header, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip, ok := parseAddr(strings.TrimSpace(hops[i]))
+			if !ok {
+				// A malformed hop means nothing further left can be trusted.
+				return netip.Addr{}, false
+			}
+			if !isTrusted(ip, trusted) || i == 0 {
+				return ip, true
+			}
+		}
+	}
+	return parseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
+
+// parseAddr accepts a bare address or one with a port, as found in
+// RemoteAddr.
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
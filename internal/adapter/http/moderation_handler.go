@@ -0,0 +1,173 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type ModerationHandler struct {
+	svc    *application.ModerationService
+	logger *zap.Logger
+}
+
+func NewModerationHandler(svc *application.ModerationService, logger *zap.Logger) *ModerationHandler {
+	return &ModerationHandler{svc: svc, logger: logger}
+}
+
+type banRequest struct {
+	Reason   string `json:"reason" validate:"max=512"`
+	Duration int    `json:"duration" validate:"min=0"`
+	// DeleteMessageSeconds deletes the messages the user posted in that many
+	// past seconds, up to seven days.
+	DeleteMessageSeconds int `json:"deleteMessageSeconds" validate:"min=0,max=604800"`
+}
+
+type timeoutRequest struct {
+	Duration int `json:"duration" validate:"required,min=1,max=2419200"`
+}
+
+func (h *ModerationHandler) Kick(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Kick(r.Context(), uc.UserID, id); err != nil {
+		h.writeError(w, "failed to kick member", id, err)
+		return
+	}
+
+	h.logger.Info("member kicked", zap.String("id", id), zap.String("moderator", uc.UserID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ModerationHandler) Ban(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var req banRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	ban, err := h.svc.Ban(r.Context(), uc.UserID, id, req.Reason, time.Duration(req.Duration)*time.Second, time.Duration(req.DeleteMessageSeconds)*time.Second)
+	if err != nil {
+		h.writeError(w, "failed to ban user", id, err)
+		return
+	}
+
+	h.logger.Info("user banned", zap.String("id", id), zap.String("moderator", uc.UserID))
+	writeJSON(w, http.StatusOK, BanToResponse(ban))
+}
+
+func (h *ModerationHandler) Unban(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Unban(r.Context(), id); err != nil {
+		h.writeError(w, "failed to unban user", id, err)
+		return
+	}
+
+	h.logger.Info("user unbanned", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ModerationHandler) GetBans(w http.ResponseWriter, r *http.Request) {
+	bans, err := h.svc.GetBans(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get bans", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BansToResponse(bans))
+}
+
+func (h *ModerationHandler) Timeout(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var req timeoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	member, err := h.svc.Timeout(r.Context(), uc.UserID, id, time.Duration(req.Duration)*time.Second)
+	if err != nil {
+		h.writeError(w, "failed to time out member", id, err)
+		return
+	}
+
+	h.logger.Info("member timed out", zap.String("id", id), zap.String("moderator", uc.UserID))
+	writeJSON(w, http.StatusOK, MemberToResponse(member))
+}
+
+func (h *ModerationHandler) RemoveTimeout(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.RemoveTimeout(r.Context(), id); err != nil {
+		h.writeError(w, "failed to remove timeout", id, err)
+		return
+	}
+
+	h.logger.Info("member timeout removed", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ModerationHandler) GetTimeouts(w http.ResponseWriter, r *http.Request) {
+	members, err := h.svc.GetTimeouts(r.Context())
+	if err != nil {
+		h.logger.Error("failed to get timeouts", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MembersToResponse(members))
+}
+
+func (h *ModerationHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrMemberNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"member not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrBanNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"ban not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrCannotModerate):
+		writeJSON(w, http.StatusForbidden, errorResponse{"cannot moderate this user", "CANNOT_MODERATE"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
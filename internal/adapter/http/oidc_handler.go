@@ -0,0 +1,160 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type OIDCHandler struct {
+	svc    *application.OIDCService
+	logger *zap.Logger
+}
+
+func NewOIDCHandler(svc *application.OIDCService, logger *zap.Logger) *OIDCHandler {
+	return &OIDCHandler{svc: svc, logger: logger}
+}
+
+type oidcProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type oidcAuthorizeResponse struct {
+	URL string `json:"url"`
+}
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=128"`
+}
+
+// GetProviders lists the providers the login page can offer.
+func (h *OIDCHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	providers := h.svc.Providers()
+	resp := make([]oidcProviderResponse, len(providers))
+	for i, p := range providers {
+		resp[i] = oidcProviderResponse{Name: p.Name, DisplayName: p.DisplayName}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Authorize returns the URL of the provider's login page. The client sends the
+// user there and posts the code and state it gets back to Callback.
+func (h *OIDCHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	url, err := h.svc.Authorize(r.Context(), name)
+	if err != nil {
+		h.writeError(w, "failed to start oidc login", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oidcAuthorizeResponse{URL: url})
+}
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	var req oidcCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	result, err := h.svc.Callback(r.Context(), name, req.Code, req.State, clientInfo(r))
+	if err != nil {
+		h.writeError(w, "failed to complete oidc login", err)
+		return
+	}
+
+	if result.Tokens == nil {
+		h.logger.Info("user signed in with oidc, awaiting second factor", zap.String("provider", name))
+	} else {
+		h.logger.Info("user logged in with oidc", zap.String("provider", name))
+	}
+	writeLoginResult(w, result)
+}
+
+// AuthorizeLink is Authorize for linking the provider to the current user's
+// account. The client posts the code and state it gets back to Link.
+func (h *OIDCHandler) AuthorizeLink(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	name := chi.URLParam(r, "provider")
+
+	url, err := h.svc.AuthorizeLink(r.Context(), uc.UserID, name)
+	if err != nil {
+		h.writeError(w, "failed to start oidc link", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oidcAuthorizeResponse{URL: url})
+}
+
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	name := chi.URLParam(r, "provider")
+
+	var req oidcCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	if err := h.svc.Link(r.Context(), uc.UserID, name, req.Code, req.State); err != nil {
+		h.writeError(w, "failed to link oidc identity", err)
+		return
+	}
+
+	h.logger.Info("user linked an oidc identity", zap.String("id", uc.UserID), zap.String("provider", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OIDCHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, application.ErrOIDCProviderNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"identity provider not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrInvalidOIDCState):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid or expired sign-in state", "INVALID_OIDC_STATE"})
+	case errors.Is(err, application.ErrOIDCLoginFailed):
+		h.logger.Warn(msg, zap.Error(err))
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"identity provider rejected the sign-in", "OIDC_LOGIN_FAILED"})
+	case errors.Is(err, application.ErrOIDCEmailNotVerified):
+		writeJSON(w, http.StatusForbidden, errorResponse{"identity provider did not verify the email address", "OIDC_EMAIL_NOT_VERIFIED"})
+	case errors.Is(err, application.ErrOIDCAccountNotFound):
+		writeJSON(w, http.StatusForbidden, errorResponse{"no account is linked to this identity", "OIDC_ACCOUNT_NOT_FOUND"})
+	case errors.Is(err, application.ErrOIDCLinkRequired):
+		writeJSON(w, http.StatusConflict, errorResponse{"an account with this email exists, sign in to link the identity to it", "OIDC_LINK_REQUIRED"})
+	case errors.Is(err, application.ErrOIDCIdentityTaken):
+		writeJSON(w, http.StatusConflict, errorResponse{"identity is already linked to another account", "OIDC_IDENTITY_TAKEN"})
+	case errors.Is(err, application.ErrAccountPending), errors.Is(err, application.ErrAccountDisabled):
+		writeLoginError(w, err)
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type PasskeyHandler struct {
+	svc    *application.PasskeyService
+	auth   *application.AuthService
+	logger *zap.Logger
+}
+
+func NewPasskeyHandler(svc *application.PasskeyService, auth *application.AuthService, logger *zap.Logger) *PasskeyHandler {
+	return &PasskeyHandler{svc: svc, auth: auth, logger: logger}
+}
+
+// passkeyCeremonyResponse carries the options to hand to the WebAuthn browser
+// API and the token to send back along with its result.
+type passkeyCeremonyResponse struct {
+	CeremonyToken string          `json:"ceremonyToken"`
+	Options       json.RawMessage `json:"options"`
+}
+
+type registerPasskeyRequest struct {
+	CeremonyToken string          `json:"ceremonyToken" validate:"required,max=128"`
+	Name          string          `json:"name" validate:"required,min=1,max=64"`
+	Credential    json.RawMessage `json:"credential" validate:"required"`
+}
+
+type passkeyLoginRequest struct {
+	CeremonyToken string          `json:"ceremonyToken" validate:"required,max=128"`
+	Credential    json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (h *PasskeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	passkeys, err := h.svc.GetAll(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error("failed to get passkeys", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PasskeysToResponse(passkeys))
+}
+
+func (h *PasskeyHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	token, options, err := h.svc.BeginRegistration(r.Context(), uc.UserID)
+	if err != nil {
+		h.writeError(w, "failed to begin passkey registration", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyCeremonyResponse{CeremonyToken: token, Options: options})
+}
+
+func (h *PasskeyHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req registerPasskeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	passkey, err := h.svc.FinishRegistration(r.Context(), uc.UserID, req.CeremonyToken, req.Name, req.Credential)
+	if err != nil {
+		h.writeError(w, "failed to register passkey", err)
+		return
+	}
+
+	h.logger.Info("passkey registered", zap.String("userId", uc.UserID), zap.String("id", passkey.ID))
+	writeJSON(w, http.StatusCreated, PasskeyToResponse(passkey))
+}
+
+func (h *PasskeyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Delete(r.Context(), uc.UserID, id); err != nil {
+		h.writeError(w, "failed to delete passkey", err)
+		return
+	}
+
+	h.logger.Info("passkey deleted", zap.String("userId", uc.UserID), zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginLogin starts a passwordless login.
+func (h *PasskeyHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	token, options, err := h.svc.BeginLogin(r.Context())
+	if err != nil {
+		h.writeError(w, "failed to begin passkey login", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyCeremonyResponse{CeremonyToken: token, Options: options})
+}
+
+func (h *PasskeyHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req passkeyLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	pair, err := h.auth.LoginPasskey(r.Context(), req.CeremonyToken, req.Credential, clientInfo(r))
+	if err != nil {
+		h.logger.Warn("failed to login with passkey", zap.Error(err))
+		if errors.Is(err, application.ErrInvalidPasskey) || errors.Is(err, application.ErrInvalidPasskeyCeremony) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{"passkey verification failed", "INVALID_PASSKEY"})
+			return
+		}
+		writeLoginError(w, err)
+		return
+	}
+
+	h.logger.Info("user logged in with passkey")
+	writeJSON(w, http.StatusOK, pair)
+}
+
+func (h *PasskeyHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrPasskeyNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"passkey not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrInvalidPasskeyCeremony):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid or expired passkey ceremony", "INVALID_PASSKEY_CEREMONY"})
+	case errors.Is(err, application.ErrInvalidPasskey):
+		h.logger.Warn(msg, zap.Error(err))
+		writeJSON(w, http.StatusBadRequest, errorResponse{"passkey verification failed", "INVALID_PASSKEY"})
+	case errors.Is(err, application.ErrMFARequired):
+		writeJSON(w, http.StatusForbidden, errorResponse{"two-factor authentication is required for administrators", "MFA_REQUIRED"})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
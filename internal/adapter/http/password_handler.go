@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type PasswordHandler struct {
+	svc    *application.PasswordService
+	logger *zap.Logger
+}
+
+func NewPasswordHandler(svc *application.PasswordService, logger *zap.Logger) *PasswordHandler {
+	return &PasswordHandler{svc: svc, logger: logger}
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=128"`
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type confirmPasswordResetRequest struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required,min=8,max=128"`
+}
+
+// Change sets a new password. All sessions are signed out, including the
+// current one, so the response carries tokens for a new session.
+func (h *PasswordHandler) Change(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	pair, err := h.svc.Change(r.Context(), uc.UserID, req.CurrentPassword, req.NewPassword, clientInfo(r))
+	if err != nil {
+		if errors.Is(err, application.ErrIncorrectPassword) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"current password is incorrect", "INCORRECT_PASSWORD"})
+			return
+		}
+		if errors.Is(err, application.ErrUserNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+			return
+		}
+		h.logger.Error("failed to change password", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("password changed", zap.String("userId", uc.UserID))
+	writeJSON(w, http.StatusOK, pair)
+}
+
+// RequestReset always answers 202 so that it cannot be used to find out which
+// emails have an account.
+func (h *PasswordHandler) RequestReset(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	if err := h.svc.RequestReset(r.Context(), req.Email); err != nil {
+		h.logger.Error("failed to request password reset", zap.String("email", req.Email), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var req confirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	if err := h.svc.Reset(r.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, application.ErrInvalidResetToken) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{"invalid or expired password reset token", "INVALID_RESET_TOKEN"})
+			return
+		}
+		h.logger.Error("failed to reset password", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("password reset")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type PersonalAccessTokenHandler struct {
+	svc    *application.PersonalAccessTokenService
+	logger *zap.Logger
+}
+
+func NewPersonalAccessTokenHandler(svc *application.PersonalAccessTokenService, logger *zap.Logger) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{svc: svc, logger: logger}
+}
+
+type createPersonalAccessTokenRequest struct {
+	Name   string   `json:"name" validate:"required,min=1,max=64"`
+	Scopes []string `json:"scopes" validate:"required,min=1,max=8,dive,required,max=32"`
+	// ExpiresIn is in seconds, up to a year. Zero makes a token that never
+	// expires.
+	ExpiresIn int `json:"expiresIn" validate:"min=0,max=31536000"`
+}
+
+func (h *PersonalAccessTokenHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	tokens, err := h.svc.GetAll(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error("failed to get personal access tokens", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PersonalAccessTokensToResponse(tokens))
+}
+
+// Create returns the new token along with its secret, which cannot be
+// retrieved again.
+func (h *PersonalAccessTokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createPersonalAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	scopes := make([]domain.TokenScope, len(req.Scopes))
+	for i, s := range req.Scopes {
+		scopes[i] = domain.TokenScope(s)
+	}
+
+	token, secret, err := h.svc.Create(r.Context(), uc.UserID, application.CreatePersonalAccessTokenInput{
+		Name:      req.Name,
+		Scopes:    scopes,
+		ExpiresIn: time.Duration(req.ExpiresIn) * time.Second,
+	})
+	if err != nil {
+		h.writeError(w, "failed to create personal access token", err)
+		return
+	}
+
+	h.logger.Info("personal access token created", zap.String("userId", uc.UserID), zap.String("id", token.ID))
+	writeJSON(w, http.StatusCreated, CreatedPersonalAccessTokenResponse{
+		PersonalAccessTokenResponse: PersonalAccessTokenToResponse(token),
+		Token:                       secret,
+	})
+}
+
+func (h *PersonalAccessTokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Revoke(r.Context(), uc.UserID, id); err != nil {
+		h.writeError(w, "failed to revoke personal access token", err)
+		return
+	}
+
+	h.logger.Info("personal access token revoked", zap.String("userId", uc.UserID), zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PersonalAccessTokenHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	if err := h.svc.RevokeAll(r.Context(), uc.UserID); err != nil {
+		h.writeError(w, "failed to revoke personal access tokens", err)
+		return
+	}
+
+	h.logger.Info("personal access tokens revoked", zap.String("userId", uc.UserID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PersonalAccessTokenHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrPersonalAccessTokenNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"personal access token not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrInvalidTokenScope):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid token scope", "INVALID_SCOPE"})
+	case errors.Is(err, application.ErrForbidden):
+		writeJSON(w, http.StatusForbidden, errorResponse{"only administrators can grant the admin scope", "FORBIDDEN"})
+	case errors.Is(err, application.ErrTooManyPersonalAccessTokens):
+		writeJSON(w, http.StatusConflict, errorResponse{"too many personal access tokens", "TOO_MANY_TOKENS"})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
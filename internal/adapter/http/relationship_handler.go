@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type RelationshipHandler struct {
+	svc    *application.RelationshipService
+	logger *zap.Logger
+}
+
+func NewRelationshipHandler(svc *application.RelationshipService, logger *zap.Logger) *RelationshipHandler {
+	return &RelationshipHandler{svc: svc, logger: logger}
+}
+
+type friendRequestRequest struct {
+	UserID string `json:"userId" validate:"required,max=64"`
+}
+
+func (h *RelationshipHandler) GetFriends(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, "failed to get friends", h.svc.GetFriends)
+}
+
+func (h *RelationshipHandler) GetFriendRequests(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, "failed to get friend requests", h.svc.GetFriendRequests)
+}
+
+func (h *RelationshipHandler) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, "failed to get blocked users", h.svc.GetBlocks)
+}
+
+func (h *RelationshipHandler) SendFriendRequest(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req friendRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	rel, err := h.svc.SendFriendRequest(r.Context(), uc.UserID, req.UserID)
+	if err != nil {
+		h.writeError(w, "failed to send friend request", req.UserID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RelationshipToResponse(rel))
+}
+
+func (h *RelationshipHandler) AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rel, err := h.svc.AcceptFriendRequest(r.Context(), uc.UserID, id)
+	if err != nil {
+		h.writeError(w, "failed to accept friend request", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RelationshipToResponse(rel))
+}
+
+func (h *RelationshipHandler) DeclineFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "failed to decline friend request", h.svc.DeclineFriendRequest)
+}
+
+func (h *RelationshipHandler) CancelFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "failed to cancel friend request", h.svc.CancelFriendRequest)
+}
+
+func (h *RelationshipHandler) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "failed to remove friend", h.svc.RemoveFriend)
+}
+
+func (h *RelationshipHandler) Block(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rel, err := h.svc.Block(r.Context(), uc.UserID, id)
+	if err != nil {
+		h.writeError(w, "failed to block user", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RelationshipToResponse(rel))
+}
+
+func (h *RelationshipHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "failed to unblock user", h.svc.Unblock)
+}
+
+func (h *RelationshipHandler) list(w http.ResponseWriter, r *http.Request, msg string, fn func(ctx context.Context, userID string) ([]domain.Relationship, error)) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	relationships, err := fn(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error(msg, zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RelationshipsToResponse(relationships))
+}
+
+func (h *RelationshipHandler) remove(w http.ResponseWriter, r *http.Request, msg string, fn func(ctx context.Context, userID, targetID string) error) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := fn(r.Context(), uc.UserID, id); err != nil {
+		h.writeError(w, msg, id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *RelationshipHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrRelationshipSelf):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"you cannot add or block yourself", "RELATIONSHIP_SELF"})
+	case errors.Is(err, application.ErrAlreadyFriends):
+		writeJSON(w, http.StatusConflict, errorResponse{"you are already friends", "ALREADY_FRIENDS"})
+	case errors.Is(err, application.ErrFriendRequestExists):
+		writeJSON(w, http.StatusConflict, errorResponse{"friend request already sent", "FRIEND_REQUEST_EXISTS"})
+	case errors.Is(err, application.ErrFriendRequestNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"friend request not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrNotFriends):
+		writeJSON(w, http.StatusNotFound, errorResponse{"you are not friends", "NOT_FOUND"})
+	case errors.Is(err, application.ErrNotBlocked):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user is not blocked", "NOT_FOUND"})
+	case errors.Is(err, application.ErrRelationshipBlocked):
+		writeJSON(w, http.StatusForbidden, errorResponse{"you cannot send a friend request to this user", "RELATIONSHIP_BLOCKED"})
+	case errors.Is(err, application.ErrFriendRequestBot):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"bots cannot be added as friends", "FRIEND_REQUEST_BOT"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type ReportHandler struct {
+	svc    *application.ReportService
+	logger *zap.Logger
+}
+
+func NewReportHandler(svc *application.ReportService, logger *zap.Logger) *ReportHandler {
+	return &ReportHandler{svc: svc, logger: logger}
+}
+
+type createReportRequest struct {
+	TargetType string `json:"targetType" validate:"required,oneof=message user"`
+	UserID     string `json:"userId" validate:"required_if=TargetType user,max=64"`
+	ChannelID  string `json:"channelId" validate:"required_if=TargetType message,max=64"`
+	MessageID  string `json:"messageId" validate:"required_if=TargetType message,max=64"`
+	Category   string `json:"category" validate:"required,oneof=spam harassment hate nsfw violence other"`
+	Reason     string `json:"reason" validate:"max=1000"`
+}
+
+type reviewReportRequest struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+func (h *ReportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	var req createReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	report, err := h.svc.Create(r.Context(), uc.UserID, application.CreateReportInput{
+		TargetType: domain.ReportTargetType(req.TargetType),
+		UserID:     req.UserID,
+		ChannelID:  req.ChannelID,
+		MessageID:  req.MessageID,
+		Category:   domain.ReportCategory(req.Category),
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		h.writeError(w, "failed to create report", "", err)
+		return
+	}
+
+	h.logger.Info("report created", zap.String("id", report.ID), zap.String("reporter", uc.UserID))
+	writeJSON(w, http.StatusCreated, ReportToResponse(report))
+}
+
+func (h *ReportHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	status := domain.ReportStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", domain.ReportStatusOpen, domain.ReportStatusEscalated, domain.ReportStatusResolved, domain.ReportStatusDismissed:
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid status", "VALIDATION_ERROR"})
+		return
+	}
+
+	reports, err := h.svc.GetQueue(r.Context(), status)
+	if err != nil {
+		h.logger.Error("failed to get reports", zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ReportsToResponse(reports))
+}
+
+func (h *ReportHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, "report resolved", h.svc.Resolve)
+}
+
+func (h *ReportHandler) Dismiss(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, "report dismissed", h.svc.Dismiss)
+}
+
+func (h *ReportHandler) Escalate(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, "report escalated", h.svc.Escalate)
+}
+
+type reviewFunc func(ctx context.Context, id, moderatorID, note string) (*domain.Report, error)
+
+func (h *ReportHandler) review(w http.ResponseWriter, r *http.Request, msg string, fn reviewFunc) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var req reviewReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	report, err := fn(r.Context(), id, uc.UserID, req.Note)
+	if err != nil {
+		h.writeError(w, "failed to review report", id, err)
+		return
+	}
+
+	h.logger.Info(msg, zap.String("id", id), zap.String("moderator", uc.UserID))
+	writeJSON(w, http.StatusOK, ReportToResponse(report))
+}
+
+func (h *ReportHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrReportNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"report not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrMessageNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"message not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrAlreadyReported):
+		writeJSON(w, http.StatusConflict, errorResponse{"you already reported this", "ALREADY_REPORTED"})
+	case errors.Is(err, application.ErrCannotReportSelf):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"you cannot report yourself", "CANNOT_REPORT_SELF"})
+	case errors.Is(err, application.ErrReportClosed):
+		writeJSON(w, http.StatusConflict, errorResponse{"report is already closed", "REPORT_CLOSED"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
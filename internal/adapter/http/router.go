@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,46 +15,235 @@ import (
 )
 
 type Dependencies struct {
-	AuthHandler    *AuthHandler
-	UserHandler    *UserHandler
-	ChannelHandler *ChannelHandler
-	JWTService     domain.TokenProvider
-	Logger         *zap.Logger
+	AuthHandler         *AuthHandler
+	UserHandler         *UserHandler
+	ChannelHandler      *ChannelHandler
+	MessageHandler      *MessageHandler
+	InviteHandler       *InviteHandler
+	AdminHandler        *AdminHandler
+	ModerationHandler   *ModerationHandler
+	AuditHandler        *AuditHandler
+	AutoModHandler      *AutoModHandler
+	ReportHandler       *ReportHandler
+	DMHandler           *DMHandler
+	RelationshipHandler *RelationshipHandler
+	SessionHandler      *SessionHandler
+	PasswordHandler     *PasswordHandler
+	EmailHandler        *EmailHandler
+	MFAHandler          *MFAHandler
+	PasskeyHandler      *PasskeyHandler
+	AccessTokenHandler  *PersonalAccessTokenHandler
+	BotHandler          *BotHandler
+	WebhookHandler      *WebhookHandler
+	OIDCHandler         *OIDCHandler
+	JWKSHandler         *JWKSHandler
+	Gateway             *Gateway
+	JWTService          domain.TokenProvider
+	TokenVersions       domain.TokenVersionStore
+	UserRepository      domain.UserRepository
+	MemberRepository    domain.MemberRepository
+	MFAPolicy           authmw.MFAPolicy
+	AccessTokens        authmw.AccessTokenAuthenticator
+	Bots                authmw.BotAuthenticator
+	RateLimiter         *authmw.RateLimiter
+	TrustedProxies      []netip.Prefix
+	Logger              *zap.Logger
 }
 
 func NewRouter(deps Dependencies) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(chimw.RequestID)
-	r.Use(chimw.RealIP)
+	r.Use(authmw.RealIP(deps.TrustedProxies))
 	r.Use(requestLogger(deps.Logger))
 	r.Use(chimw.Recoverer)
 
+	limit := deps.RateLimiter
+	authenticated := authmw.IsAuthenticated(deps.JWTService, deps.TokenVersions, deps.AccessTokens, deps.Bots)
+
+	r.With(limit.Global).Get("/.well-known/jwks.json", deps.JWKSHandler.Get)
+
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", deps.AuthHandler.Register)
-			r.Post("/login", deps.AuthHandler.Login)
+			r.Use(limit.Global)
+
+			r.With(limit.Route("register")).Post("/register", deps.AuthHandler.Register)
+			r.With(limit.Route("login")).Post("/login", deps.AuthHandler.Login)
+			r.With(limit.Route("login")).Post("/login/mfa", deps.AuthHandler.LoginMFA)
+			r.With(limit.Route("login")).Post("/login/mfa/passkey", deps.AuthHandler.BeginLoginMFAPasskey)
+			r.With(limit.Route("login")).Post("/passkey/options", deps.PasskeyHandler.BeginLogin)
+			r.With(limit.Route("login")).Post("/passkey", deps.PasskeyHandler.Login)
+			r.Get("/oidc/providers", deps.OIDCHandler.GetProviders)
+			r.Post("/oidc/{provider}/authorize", deps.OIDCHandler.Authorize)
+			r.With(limit.Route("login")).Post("/oidc/{provider}/callback", deps.OIDCHandler.Callback)
 			r.Post("/refresh", deps.AuthHandler.Refresh)
+			r.With(limit.Route("email")).Post("/password-reset", deps.PasswordHandler.RequestReset)
+			r.With(limit.Route("login")).Post("/password-reset/confirm", deps.PasswordHandler.Reset)
+			r.With(limit.Route("login")).Post("/verify-email", deps.EmailHandler.Verify)
+			r.With(authenticated).Post("/logout", deps.AuthHandler.Logout)
 		})
 
+		r.With(limit.Global, limit.Route("invites")).Get("/invites/{code}", deps.InviteHandler.Preview)
+		r.With(limit.Global).Get("/gateway", deps.Gateway.ServeHTTP)
+		// Keyed on the token rather than the ID, so that requests with a wrong
+		// token cannot use up the webhook's bucket.
+		r.With(limit.Global, limit.RouteByParam("webhooks", "token")).Post("/webhooks/{id}/{token}", deps.WebhookHandler.Execute)
+
 		r.Group(func(r chi.Router) {
-			r.Use(authmw.IsAuthenticated(deps.JWTService))
+			r.Use(authenticated)
+			r.Use(authmw.WithActor)
+			r.Use(limit.Global)
+
+			// Routes reachable with personal access tokens and bot tokens are
+			// guarded by RequireScope; the others only accept sessions.
+			readMessages := authmw.RequireScope(domain.ScopeMessagesRead)
+			writeMessages := authmw.RequireScope(domain.ScopeMessagesWrite)
+			manageChannels := authmw.RequireScope(domain.ScopeChannelsManage)
 
 			r.Route("/users", func(r chi.Router) {
-				r.Get("/me", deps.UserHandler.Me)
+				r.With(readMessages).Get("/me", deps.UserHandler.Me)
 				r.Patch("/me", deps.UserHandler.UpdateMe)
-				r.Get("/", deps.UserHandler.GetAll)
-				r.Get("/{id}", deps.UserHandler.GetByID)
-				r.Patch("/{id}", deps.UserHandler.Update)
-				r.Delete("/{id}", deps.UserHandler.Delete)
+				r.With(limit.Route("login")).Post("/me/password", deps.PasswordHandler.Change)
+				r.With(limit.Route("email")).Post("/me/email/verification", deps.EmailHandler.Resend)
+
+				r.Get("/me/mfa", deps.MFAHandler.Status)
+				r.Post("/me/mfa/totp", deps.MFAHandler.BeginTOTP)
+				r.Post("/me/mfa/totp/confirm", deps.MFAHandler.ConfirmTOTP)
+				r.Post("/me/mfa/totp/disable", deps.MFAHandler.DisableTOTP)
+				r.Post("/me/mfa/recovery-codes", deps.MFAHandler.RegenerateRecoveryCodes)
+
+				r.Post("/me/oidc/{provider}/authorize", deps.OIDCHandler.AuthorizeLink)
+				r.With(limit.Route("login")).Post("/me/oidc/{provider}/link", deps.OIDCHandler.Link)
+
+				r.Get("/me/passkeys", deps.PasskeyHandler.GetAll)
+				r.Post("/me/passkeys/options", deps.PasskeyHandler.BeginRegistration)
+				r.Post("/me/passkeys", deps.PasskeyHandler.FinishRegistration)
+				r.Delete("/me/passkeys/{id}", deps.PasskeyHandler.Delete)
+
+				r.Get("/me/friends", deps.RelationshipHandler.GetFriends)
+				r.Delete("/me/friends/{id}", deps.RelationshipHandler.RemoveFriend)
+				r.Get("/me/friend-requests", deps.RelationshipHandler.GetFriendRequests)
+				r.Post("/me/friend-requests", deps.RelationshipHandler.SendFriendRequest)
+				r.Post("/me/friend-requests/{id}/accept", deps.RelationshipHandler.AcceptFriendRequest)
+				r.Post("/me/friend-requests/{id}/decline", deps.RelationshipHandler.DeclineFriendRequest)
+				r.Delete("/me/friend-requests/{id}", deps.RelationshipHandler.CancelFriendRequest)
+				r.Get("/me/blocks", deps.RelationshipHandler.GetBlocks)
+				r.Put("/me/blocks/{id}", deps.RelationshipHandler.Block)
+				r.Delete("/me/blocks/{id}", deps.RelationshipHandler.Unblock)
+
+				r.Get("/me/sessions", deps.SessionHandler.GetAll)
+				r.Delete("/me/sessions", deps.SessionHandler.RevokeOthers)
+				r.Delete("/me/sessions/{id}", deps.SessionHandler.Revoke)
+
+				r.Get("/me/tokens", deps.AccessTokenHandler.GetAll)
+				r.Post("/me/tokens", deps.AccessTokenHandler.Create)
+				r.Delete("/me/tokens", deps.AccessTokenHandler.RevokeAll)
+				r.Delete("/me/tokens/{id}", deps.AccessTokenHandler.Revoke)
+
+				r.With(readMessages).Get("/", deps.UserHandler.GetAll)
+				r.With(readMessages).Get("/{id}", deps.UserHandler.GetByID)
+
+				r.Group(func(r chi.Router) {
+					r.Use(authmw.RequireScope(domain.ScopeAdmin))
+					r.Use(authmw.IsAdmin(deps.UserRepository, deps.MFAPolicy))
+
+					r.Patch("/{id}", deps.UserHandler.Update)
+					r.Delete("/{id}", deps.UserHandler.Delete)
+				})
+			})
+
+			r.With(limit.Route("invites")).Post("/invites/{code}/accept", deps.InviteHandler.Accept)
+
+			r.Route("/bots", func(r chi.Router) {
+				r.Get("/", deps.BotHandler.GetAll)
+				r.Post("/", deps.BotHandler.Create)
+				r.Get("/{id}", deps.BotHandler.GetByID)
+				r.Delete("/{id}", deps.BotHandler.Delete)
+				r.Post("/{id}/token", deps.BotHandler.ResetToken)
+				r.With(
+					authmw.RequireScope(domain.ScopeAdmin),
+					authmw.IsAdmin(deps.UserRepository, deps.MFAPolicy),
+				).Put("/{id}/authorization", deps.BotHandler.Authorize)
+			})
+
+			r.Route("/dms", func(r chi.Router) {
+				r.With(readMessages).Get("/", deps.DMHandler.GetAll)
+				r.With(writeMessages).Post("/", deps.DMHandler.Open)
+				r.Post("/groups", deps.DMHandler.CreateGroup)
+				r.With(readMessages).Get("/{id}", deps.DMHandler.GetByID)
+				r.Patch("/{id}", deps.DMHandler.UpdateGroup)
+				r.Delete("/{id}", deps.DMHandler.Leave)
+				r.Put("/{id}/recipients/{userId}", deps.DMHandler.AddRecipient)
+				r.Delete("/{id}/recipients/{userId}", deps.DMHandler.RemoveRecipient)
+
+				r.With(readMessages).Get("/{id}/messages", deps.DMHandler.GetMessages)
+				r.With(writeMessages, limit.Route("messages")).Post("/{id}/messages", deps.DMHandler.CreateMessage)
+				r.With(writeMessages).Patch("/{id}/messages/{messageId}", deps.DMHandler.UpdateMessage)
+				r.With(writeMessages).Delete("/{id}/messages/{messageId}", deps.DMHandler.DeleteMessage)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(authmw.IsMember(deps.MemberRepository))
+
+				r.Route("/channels", func(r chi.Router) {
+					r.With(readMessages).Get("/", deps.ChannelHandler.GetAll)
+					r.With(manageChannels).Post("/", deps.ChannelHandler.Create)
+					r.With(readMessages).Get("/{id}", deps.ChannelHandler.GetByID)
+					r.With(manageChannels).Patch("/{id}", deps.ChannelHandler.Update)
+					r.With(manageChannels).Delete("/{id}", deps.ChannelHandler.Delete)
+
+					r.With(readMessages).Get("/{id}/messages", deps.MessageHandler.GetAll)
+					r.With(writeMessages, limit.Route("messages")).Post("/{id}/messages", deps.MessageHandler.Create)
+					r.With(writeMessages).Patch("/{id}/messages/{messageId}", deps.MessageHandler.Update)
+					r.With(writeMessages).Delete("/{id}/messages/{messageId}", deps.MessageHandler.Delete)
+
+					r.With(manageChannels).Get("/{id}/webhooks", deps.WebhookHandler.GetAll)
+					r.With(manageChannels).Post("/{id}/webhooks", deps.WebhookHandler.Create)
+					r.With(manageChannels).Post("/{id}/webhooks/{webhookId}/token", deps.WebhookHandler.RotateToken)
+					r.With(manageChannels).Delete("/{id}/webhooks/{webhookId}", deps.WebhookHandler.Delete)
+				})
+
+				r.With(manageChannels).Get("/invites", deps.InviteHandler.GetAll)
+				r.With(manageChannels).Post("/invites", deps.InviteHandler.Create)
+				r.With(manageChannels).Delete("/invites/{code}", deps.InviteHandler.Revoke)
+
+				r.With(limit.Route("reports")).Post("/reports", deps.ReportHandler.Create)
 			})
 
-			r.Route("/channels", func(r chi.Router) {
-				r.Get("/", deps.ChannelHandler.GetAll)
-				r.Post("/", deps.ChannelHandler.Create)
-				r.Get("/{id}", deps.ChannelHandler.GetByID)
-				r.Patch("/{id}", deps.ChannelHandler.Update)
-				r.Delete("/{id}", deps.ChannelHandler.Delete)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(authmw.RequireScope(domain.ScopeAdmin))
+				r.Use(authmw.IsAdmin(deps.UserRepository, deps.MFAPolicy))
+
+				r.Post("/users", deps.AdminHandler.CreateUser)
+				r.Get("/registrations", deps.AdminHandler.GetPendingRegistrations)
+				r.Post("/registrations/{id}/approve", deps.AdminHandler.ApproveRegistration)
+				r.Delete("/registrations/{id}", deps.AdminHandler.RejectRegistration)
+				r.Post("/users/{id}/disable", deps.AdminHandler.DisableUser)
+				r.Post("/users/{id}/enable", deps.AdminHandler.EnableUser)
+				r.Post("/users/{id}/logout", deps.AdminHandler.ForceLogout)
+				r.Delete("/users/{id}/mfa", deps.MFAHandler.Reset)
+				r.Delete("/users/{id}/lockout", deps.AdminHandler.Unlock)
+
+				r.Delete("/members/{id}", deps.ModerationHandler.Kick)
+				r.Get("/bans", deps.ModerationHandler.GetBans)
+				r.Put("/bans/{id}", deps.ModerationHandler.Ban)
+				r.Delete("/bans/{id}", deps.ModerationHandler.Unban)
+				r.Get("/timeouts", deps.ModerationHandler.GetTimeouts)
+				r.Put("/timeouts/{id}", deps.ModerationHandler.Timeout)
+				r.Delete("/timeouts/{id}", deps.ModerationHandler.RemoveTimeout)
+
+				r.Get("/audit-log", deps.AuditHandler.GetAll)
+
+				r.Get("/automod/rules", deps.AutoModHandler.GetRules)
+				r.Post("/automod/rules", deps.AutoModHandler.CreateRule)
+				r.Patch("/automod/rules/{id}", deps.AutoModHandler.UpdateRule)
+				r.Delete("/automod/rules/{id}", deps.AutoModHandler.DeleteRule)
+				r.Get("/automod/flags", deps.AutoModHandler.GetFlags)
+
+				r.Get("/reports", deps.ReportHandler.GetQueue)
+				r.Post("/reports/{id}/resolve", deps.ReportHandler.Resolve)
+				r.Post("/reports/{id}/dismiss", deps.ReportHandler.Dismiss)
+				r.Post("/reports/{id}/escalate", deps.ReportHandler.Escalate)
 			})
 		})
 	})
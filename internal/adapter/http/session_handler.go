@@ -0,0 +1,84 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+type SessionHandler struct {
+	svc    *application.SessionService
+	logger *zap.Logger
+}
+
+func NewSessionHandler(svc *application.SessionService, logger *zap.Logger) *SessionHandler {
+	return &SessionHandler{svc: svc, logger: logger}
+}
+
+type revokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+func (h *SessionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	sessions, err := h.svc.GetAll(r.Context(), uc.UserID)
+	if err != nil {
+		h.logger.Error("failed to get sessions", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SessionsToResponse(sessions, uc.SessionID))
+}
+
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := h.svc.Revoke(r.Context(), uc.UserID, id); err != nil {
+		if errors.Is(err, application.ErrSessionNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{"session not found", "NOT_FOUND"})
+			return
+		}
+		h.logger.Error("failed to revoke session", zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("session revoked", zap.String("userId", uc.UserID), zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOthers logs the user out everywhere except the current session.
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	n, err := h.svc.RevokeOthers(r.Context(), uc.UserID, uc.SessionID)
+	if err != nil {
+		h.logger.Error("failed to revoke sessions", zap.String("userId", uc.UserID), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+		return
+	}
+
+	h.logger.Info("other sessions revoked", zap.String("userId", uc.UserID), zap.Int("count", n))
+	writeJSON(w, http.StatusOK, revokeSessionsResponse{Revoked: n})
+}
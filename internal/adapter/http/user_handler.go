@@ -10,6 +10,7 @@ import (
 
 	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
 	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
 type UserHandler struct {
@@ -24,6 +25,15 @@ func NewHandler(svc *application.UserService, logger *zap.Logger) *UserHandler {
 type updateUserRequest struct {
 	Username string `json:"username" validate:"omitempty,min=2,max=32"`
 	Email    string `json:"email" validate:"omitempty,email"`
+	DMPolicy string `json:"dmPolicy" validate:"omitempty,oneof=everyone members friends"`
+}
+
+func (r updateUserRequest) input() application.UpdateUserInput {
+	return application.UpdateUserInput{
+		Username: r.Username,
+		Email:    r.Email,
+		DMPolicy: domain.DMPolicy(r.DMPolicy),
+	}
 }
 
 func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
@@ -60,8 +70,17 @@ func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.svc.Update(r.Context(), uc.UserID, req.Username, req.Email)
+	user, err := h.svc.Update(r.Context(), uc.UserID, req.input())
+	if errors.Is(err, application.ErrVerificationNotSent) {
+		// The change is pending; the user can ask for a new link.
+		h.logger.Warn("failed to send verification email", zap.String("id", uc.UserID), zap.Error(err))
+		err = nil
+	}
 	if err != nil {
+		if errors.Is(err, application.ErrEmailTaken) {
+			writeJSON(w, http.StatusConflict, errorResponse{"email already taken", "EMAIL_TAKEN"})
+			return
+		}
 		h.logger.Error("failed to update current user", zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
 		return
@@ -112,12 +131,16 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.svc.Update(r.Context(), id, req.Username, req.Email)
+	user, err := h.svc.Update(r.Context(), id, req.input())
 	if err != nil {
 		if errors.Is(err, application.ErrUserNotFound) {
 			writeJSON(w, http.StatusNotFound, errorResponse{"user not found", "NOT_FOUND"})
 			return
 		}
+		if errors.Is(err, application.ErrEmailTaken) {
+			writeJSON(w, http.StatusConflict, errorResponse{"email already taken", "EMAIL_TAKEN"})
+			return
+		}
 		h.logger.Error("failed to update user", zap.String("id", id), zap.Error(err))
 		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
 		return
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/http/middleware"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type WebhookHandler struct {
+	svc    *application.WebhookService
+	logger *zap.Logger
+}
+
+func NewWebhookHandler(svc *application.WebhookService, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{svc: svc, logger: logger}
+}
+
+type createWebhookRequest struct {
+	Name      string `json:"name" validate:"required,min=1,max=80"`
+	AvatarURL string `json:"avatarUrl" validate:"omitempty,http_url,max=2048"`
+}
+
+type executeWebhookRequest struct {
+	Content   string                `json:"content" validate:"max=4000"`
+	Username  string                `json:"username" validate:"max=80"`
+	AvatarURL string                `json:"avatarUrl" validate:"omitempty,http_url,max=2048"`
+	Embeds    []webhookEmbedRequest `json:"embeds" validate:"max=10,dive"`
+}
+
+type webhookEmbedRequest struct {
+	Title       string                     `json:"title" validate:"max=256"`
+	Description string                     `json:"description" validate:"max=4096"`
+	URL         string                     `json:"url" validate:"omitempty,http_url,max=2048"`
+	Color       int                        `json:"color" validate:"min=0,max=16777215"`
+	Fields      []webhookEmbedFieldRequest `json:"fields" validate:"max=25,dive"`
+	Footer      string                     `json:"footer" validate:"max=2048"`
+}
+
+type webhookEmbedFieldRequest struct {
+	Name   string `json:"name" validate:"required,max=256"`
+	Value  string `json:"value" validate:"required,max=1024"`
+	Inline bool   `json:"inline"`
+}
+
+func (h *WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	channelID := chi.URLParam(r, "id")
+
+	webhooks, err := h.svc.GetAll(r.Context(), channelID)
+	if err != nil {
+		h.writeError(w, "failed to get webhooks", channelID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, WebhooksToResponse(webhooks))
+}
+
+// Create returns the new webhook along with its token and URL, which cannot
+// be retrieved again.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uc, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"unauthorized", "UNAUTHORIZED"})
+		return
+	}
+
+	channelID := chi.URLParam(r, "id")
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	webhook, secret, err := h.svc.Create(r.Context(), channelID, uc.UserID, req.Name, req.AvatarURL)
+	if err != nil {
+		h.writeError(w, "failed to create webhook", channelID, err)
+		return
+	}
+
+	h.logger.Info("webhook created", zap.String("channelId", channelID), zap.String("id", webhook.ID))
+	writeJSON(w, http.StatusCreated, WebhookTokenResponse{
+		WebhookResponse: WebhookToResponse(webhook),
+		Token:           secret,
+		URL:             h.svc.URL(webhook, secret),
+	})
+}
+
+func (h *WebhookHandler) RotateToken(w http.ResponseWriter, r *http.Request) {
+	channelID := chi.URLParam(r, "id")
+	id := chi.URLParam(r, "webhookId")
+
+	webhook, secret, err := h.svc.RotateToken(r.Context(), channelID, id)
+	if err != nil {
+		h.writeError(w, "failed to rotate webhook token", id, err)
+		return
+	}
+
+	h.logger.Info("webhook token rotated", zap.String("channelId", channelID), zap.String("id", id))
+	writeJSON(w, http.StatusOK, WebhookTokenResponse{
+		WebhookResponse: WebhookToResponse(webhook),
+		Token:           secret,
+		URL:             h.svc.URL(webhook, secret),
+	})
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	channelID := chi.URLParam(r, "id")
+	id := chi.URLParam(r, "webhookId")
+
+	if err := h.svc.Delete(r.Context(), channelID, id); err != nil {
+		h.writeError(w, "failed to delete webhook", id, err)
+		return
+	}
+
+	h.logger.Info("webhook deleted", zap.String("channelId", channelID), zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Execute posts a message through the webhook. The token in the URL is its
+// only authentication.
+func (h *WebhookHandler) Execute(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req executeWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"invalid request body", "VALIDATION_ERROR"})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{formatValidationError(err), "VALIDATION_ERROR"})
+		return
+	}
+
+	embeds := make([]domain.Embed, len(req.Embeds))
+	for i, e := range req.Embeds {
+		embeds[i] = domain.Embed{
+			Title:       e.Title,
+			Description: e.Description,
+			URL:         e.URL,
+			Color:       e.Color,
+			Footer:      e.Footer,
+		}
+		for _, f := range e.Fields {
+			embeds[i].Fields = append(embeds[i].Fields, domain.EmbedField{Name: f.Name, Value: f.Value, Inline: f.Inline})
+		}
+	}
+
+	message, err := h.svc.Execute(r.Context(), id, chi.URLParam(r, "token"), application.ExecuteWebhookInput{
+		Content:   req.Content,
+		Username:  req.Username,
+		AvatarURL: req.AvatarURL,
+		Embeds:    embeds,
+	})
+	if err != nil {
+		h.writeError(w, "failed to execute webhook", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, MessageToResponse(message))
+}
+
+func (h *WebhookHandler) writeError(w http.ResponseWriter, msg, id string, err error) {
+	switch {
+	case errors.Is(err, application.ErrChannelNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"channel not found", "NOT_FOUND"})
+	case errors.Is(err, application.ErrNotTextChannel):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"channel does not accept messages", "NOT_TEXT_CHANNEL"})
+	case errors.Is(err, application.ErrWebhookNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{"unknown webhook", "NOT_FOUND"})
+	case errors.Is(err, application.ErrTooManyWebhooks):
+		writeJSON(w, http.StatusConflict, errorResponse{"too many webhooks in this channel", "TOO_MANY_WEBHOOKS"})
+	case errors.Is(err, application.ErrEmptyWebhookMessage):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"message needs content or embeds", "EMPTY_MESSAGE"})
+	case errors.Is(err, application.ErrEmptyEmbed):
+		writeJSON(w, http.StatusBadRequest, errorResponse{"embeds need a title, description or fields", "EMPTY_EMBED"})
+	default:
+		h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error", "INTERNAL_ERROR"})
+	}
+}
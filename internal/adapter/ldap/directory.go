@@ -0,0 +1,164 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// AttributeDN makes the distinguished name of entries their ID. It suits
+// directories without a stable ID attribute, at the cost of treating renamed
+// entries as new ones.
+const AttributeDN = "dn"
+
+const dialTimeout = 10 * time.Second
+
+type Config struct {
+	URL          string
+	StartTLS     bool
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the entry of the user signing in. Every {login} in it
+	// is replaced with the escaped login they entered.
+	UserFilter     string
+	IDAttribute    string
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+}
+
+// Directory implements domain.Directory over LDAP. It searches for entries
+// bound as a service account and checks passwords by binding as the entry.
+type Directory struct {
+	cfg Config
+}
+
+func NewDirectory(cfg Config) *Directory {
+	return &Directory{cfg: cfg}
+}
+
+func (d *Directory) Authenticate(ctx context.Context, login, password string) (*domain.DirectoryEntry, error) {
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{login}", goldap.EscapeFilter(login))
+	entry, err := d.searchOne(conn, d.cfg.BaseDN, goldap.ScopeWholeSubtree, filter)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("bind as user: %w", err)
+	}
+	return d.toDomain(entry)
+}
+
+func (d *Directory) Lookup(ctx context.Context, id string) (*domain.DirectoryEntry, error) {
+	conn, err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var entry *goldap.Entry
+	if d.cfg.IDAttribute == AttributeDN {
+		entry, err = d.searchOne(conn, id, goldap.ScopeBaseObject, "(objectClass=*)")
+	} else {
+		filter := fmt.Sprintf("(%s=%s)", d.cfg.IDAttribute, goldap.EscapeFilter(id))
+		entry, err = d.searchOne(conn, d.cfg.BaseDN, goldap.ScopeWholeSubtree, filter)
+	}
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return d.toDomain(entry)
+}
+
+// connect opens a connection bound as the service account, or anonymously
+// when none is configured.
+func (d *Directory) connect(ctx context.Context) (*goldap.Conn, error) {
+	dialer := goldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout})
+	conn, err := goldap.DialURL(d.cfg.URL, dialer)
+	if err != nil {
+		return nil, fmt.Errorf("dial ldap: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetTimeout(time.Until(deadline))
+	}
+
+	if d.cfg.StartTLS {
+		u, err := url.Parse(d.cfg.URL)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("parse ldap url: %w", err)
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+
+	if d.cfg.BindDN != "" {
+		err = conn.Bind(d.cfg.BindDN, d.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bind as service account: %w", err)
+	}
+	return conn, nil
+}
+
+// searchOne returns the single entry matching filter, or nil when none or
+// several do.
+func (d *Directory) searchOne(conn *goldap.Conn, baseDN string, scope int, filter string) (*goldap.Entry, error) {
+	attributes := []string{d.cfg.EmailAttribute, d.cfg.NameAttribute, d.cfg.GroupAttribute}
+	if d.cfg.IDAttribute != AttributeDN {
+		attributes = append(attributes, d.cfg.IDAttribute)
+	}
+
+	req := goldap.NewSearchRequest(baseDN, scope, goldap.NeverDerefAliases, 2, 0, false, filter, attributes, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		// Hitting the size limit of 2 means the filter is ambiguous.
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) || goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("search ldap: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, nil
+	}
+	return res.Entries[0], nil
+}
+
+func (d *Directory) toDomain(entry *goldap.Entry) (*domain.DirectoryEntry, error) {
+	id := entry.DN
+	if d.cfg.IDAttribute != AttributeDN {
+		id = entry.GetAttributeValue(d.cfg.IDAttribute)
+		if id == "" {
+			return nil, fmt.Errorf("entry %s has no %s attribute", entry.DN, d.cfg.IDAttribute)
+		}
+	}
+	return &domain.DirectoryEntry{
+		ID:          id,
+		Email:       entry.GetAttributeValue(d.cfg.EmailAttribute),
+		DisplayName: entry.GetAttributeValue(d.cfg.NameAttribute),
+		Groups:      entry.GetAttributeValues(d.cfg.GroupAttribute),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// OutboxMailer writes every mail to a directory as an .eml file instead of
+// sending it. It is meant for development and for instances without an SMTP
+// relay: administrators can pick the messages up by hand.
+type OutboxMailer struct {
+	dir    string
+	from   string
+	logger *zap.Logger
+}
+
+func NewOutboxMailer(dir, from string, logger *zap.Logger) (*OutboxMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox dir: %w", err)
+	}
+	return &OutboxMailer{dir: dir, from: from, logger: logger}, nil
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg domain.Mail) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405Z"), uuid.New().String())
+	path := filepath.Join(m.dir, name)
+
+	// Mails carry secrets such as reset links, keep them private.
+	if err := os.WriteFile(path, formatMessage(m.from, msg, now), 0o600); err != nil {
+		return fmt.Errorf("write mail: %w", err)
+	}
+
+	m.logger.Info("mail written to outbox", zap.String("to", msg.To), zap.String("subject", msg.Subject), zap.String("path", path))
+	return nil
+}
@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// SMTPMailer delivers mail through an SMTP relay. STARTTLS is used whenever
+// the server offers it; credentials are only sent when a username is set.
+type SMTPMailer struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	envelope string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("parse from address: %w", err)
+	}
+
+	m := &SMTPMailer{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		from:     from,
+		envelope: addr.Address,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg domain.Mail) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("parse recipient: %w", err)
+	}
+
+	// net/smtp does not take a context, so honour cancellation before
+	// starting the exchange at least.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data := formatMessage(m.from, msg, time.Now())
+	if err := smtp.SendMail(m.addr, m.auth, m.envelope, []string{to.Address}, data); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
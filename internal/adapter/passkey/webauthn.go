@@ -0,0 +1,225 @@
+package passkey
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// WebAuthnProvider implements domain.PasskeyProvider with go-webauthn.
+type WebAuthnProvider struct {
+	webauthn *webauthn.WebAuthn
+}
+
+// NewWebAuthnProvider creates a provider for the relying party rpID, which is
+// the domain passkeys are bound to, accepting responses from origins.
+func NewWebAuthnProvider(rpID, rpName string, origins []string) (*WebAuthnProvider, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configure webauthn: %w", err)
+	}
+	return &WebAuthnProvider{webauthn: w}, nil
+}
+
+func (p *WebAuthnProvider) BeginRegistration(user *domain.User, existing []domain.Passkey) (json.RawMessage, []byte, error) {
+	u := newUser(user, existing)
+	creation, session, err := p.webauthn.BeginRegistration(u,
+		webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin registration: %w", err)
+	}
+	return marshalCeremony(creation, session)
+}
+
+func (p *WebAuthnProvider) FinishRegistration(user *domain.User, state, response []byte) (*domain.Passkey, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(state, &session); err != nil {
+		return nil, fmt.Errorf("decode session: %w", err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("parse registration response: %w", describe(err))
+	}
+	credential, err := p.webauthn.CreateCredential(newUser(user, nil), session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("verify registration: %w", describe(err))
+	}
+
+	passkey := toPasskey(credential)
+	passkey.UserID = user.ID
+	return passkey, nil
+}
+
+func (p *WebAuthnProvider) BeginLogin(user *domain.User, passkeys []domain.Passkey) (json.RawMessage, []byte, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+	)
+	if user == nil {
+		assertion, session, err = p.webauthn.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	} else {
+		assertion, session, err = p.webauthn.BeginLogin(newUser(user, passkeys))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin login: %w", err)
+	}
+	return marshalCeremony(assertion, session)
+}
+
+func (p *WebAuthnProvider) FinishLogin(state, response []byte, lookup domain.PasskeyLookup) (*domain.Passkey, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(state, &session); err != nil {
+		return nil, fmt.Errorf("decode session: %w", err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("parse login response: %w", describe(err))
+	}
+
+	var passkeys []domain.Passkey
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		user, owned, err := lookup(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("unknown user handle")
+		}
+		passkeys = owned
+		return newUser(user, owned), nil
+	}
+
+	var credential *webauthn.Credential
+	if len(session.UserID) == 0 {
+		credential, err = p.webauthn.ValidateDiscoverableLogin(handler, session, parsed)
+	} else {
+		var user webauthn.User
+		if user, err = handler(nil, session.UserID); err == nil {
+			credential, err = p.webauthn.ValidateLogin(user, session, parsed)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify login: %w", describe(err))
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, errors.New("sign counter did not increase, the authenticator may have been cloned")
+	}
+
+	id := encodeID(credential.ID)
+	for _, passkey := range passkeys {
+		if passkey.ID == id {
+			passkey.SignCount = credential.Authenticator.SignCount
+			passkey.BackupState = credential.Flags.BackupState
+			return &passkey, nil
+		}
+	}
+	return nil, errors.New("verified credential is not registered")
+}
+
+// user adapts a domain user to webauthn.User. The user handle is the user ID,
+// which is random and reveals nothing about the account.
+type user struct {
+	*domain.User
+	credentials []webauthn.Credential
+}
+
+func newUser(u *domain.User, passkeys []domain.Passkey) *user {
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, p := range passkeys {
+		credentials = append(credentials, toCredential(p))
+	}
+	return &user{User: u, credentials: credentials}
+}
+
+func (u *user) WebAuthnID() []byte                         { return []byte(u.ID) }
+func (u *user) WebAuthnName() string                       { return u.Email }
+func (u *user) WebAuthnDisplayName() string                { return u.Username }
+func (u *user) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func toCredential(p domain.Passkey) webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(p.ID)
+	transports := make([]protocol.AuthenticatorTransport, len(p.Transports))
+	for i, t := range p.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: p.BackupEligible,
+			BackupState:    p.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+func toPasskey(c *webauthn.Credential) *domain.Passkey {
+	transports := make([]string, len(c.Transport))
+	for i, t := range c.Transport {
+		transports[i] = string(t)
+	}
+	return &domain.Passkey{
+		ID:              encodeID(c.ID),
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		AAGUID:          c.Authenticator.AAGUID,
+		SignCount:       c.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  c.Flags.BackupEligible,
+		BackupState:     c.Flags.BackupState,
+		CreatedAt:       time.Now().UTC(),
+	}
+}
+
+func encodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func marshalCeremony(options any, session *webauthn.SessionData) (json.RawMessage, []byte, error) {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode options: %w", err)
+	}
+	state, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode session: %w", err)
+	}
+	return opts, state, nil
+}
+
+// describe adds the debugging information go-webauthn keeps out of its error
+// messages.
+func describe(err error) error {
+	var perr *protocol.Error
+	if errors.As(err, &perr) && perr.DevInfo != "" {
+		return fmt.Errorf("%w: %s", err, perr.DevInfo)
+	}
+	return err
+}
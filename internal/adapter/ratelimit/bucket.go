@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// Buckets are tracked with the generic cell rate algorithm, which behaves like
+// a token bucket but only needs to store one value per bucket: the time at
+// which it will be full again, called the theoretical arrival time. Each
+// request pushes it back by one emission interval, Period / Burst, and is
+// denied when that would put it more than Period in the future.
+
+// take applies a request arriving at now to a bucket whose theoretical arrival
+// time is tat, and returns the new one along with the result. tat is left
+// unchanged when the request is denied.
+func take(tat, now time.Time, limit domain.RateLimit) (time.Time, domain.RateLimitResult) {
+	interval := limit.Period / time.Duration(limit.Burst)
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(interval)
+	if allowAt := next.Add(-limit.Period); now.Before(allowAt) {
+		return tat, domain.RateLimitResult{
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+	return next, result(next.Sub(now), limit)
+}
+
+// result describes an allowed request after which the bucket takes resetAfter
+// to fill up again.
+func result(resetAfter time.Duration, limit domain.RateLimit) domain.RateLimitResult {
+	interval := limit.Period / time.Duration(limit.Burst)
+	return domain.RateLimitResult{
+		Allowed:    true,
+		Remaining:  int((limit.Period - resetAfter) / interval),
+		ResetAfter: resetAfter,
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+func TestTakeRefillsEvenly(t *testing.T) {
+	limit := domain.RateLimit{Burst: 3, Period: 3 * time.Second}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{0, true, 2, 0},
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		// One request comes back every Period / Burst.
+		{time.Second, true, 0, 0},
+		{time.Second, false, 0, time.Second},
+		// An idle bucket fills up, but never past Burst.
+		{time.Minute, true, 2, 0},
+	}
+
+	var tat time.Time
+	for i, step := range steps {
+		var res domain.RateLimitResult
+		tat, res = take(tat, start.Add(step.at), limit)
+		if res.Allowed != step.allowed || res.RetryAfter != step.retryAfter || (res.Allowed && res.Remaining != step.remaining) {
+			t.Errorf("request %d at %s = %+v, want allowed %v, remaining %d, retry after %s",
+				i, step.at, res, step.allowed, step.remaining, step.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// MemoryStore keeps buckets in memory. It suits a single instance; instances
+// behind a load balancer each get their own buckets and should share a
+// RedisStore instead.
+type MemoryStore struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tat, res := take(s.tats[key], now, limit)
+	s.tats[key] = tat
+	return res, nil
+}
+
+// Prune forgets buckets that have filled up again, which behave the same as
+// buckets never used.
+func (s *MemoryStore) Prune() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, tat := range s.tats {
+		if !now.Before(tat) {
+			delete(s.tats, key)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// takeScript is take in Lua, so that the read and the write happen atomically.
+// It uses the clock of the Redis server, so that instances with skewed clocks
+// agree. Times are in microseconds.
+var takeScript = redis.NewScript(`
+local period = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000000 + tonumber(clock[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - period
+if now < allow_at then
+	return {0, tat - now, allow_at - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, new_tat - now, 0}
+`)
+
+// RedisStore keeps buckets in Redis, so that instances sharing the server
+// share their buckets. Keys expire once their bucket is full again.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a store whose keys start with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	interval := limit.Period / time.Duration(limit.Burst)
+	values, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		limit.Period.Microseconds(), interval.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("take from rate limit bucket: %w", err)
+	}
+	if len(values) != 3 {
+		return domain.RateLimitResult{}, fmt.Errorf("take from rate limit bucket: unexpected reply %v", values)
+	}
+
+	resetAfter := time.Duration(values[1]) * time.Microsecond
+	if values[0] == 1 {
+		return result(resetAfter, limit), nil
+	}
+	return domain.RateLimitResult{
+		ResetAfter: resetAfter,
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const auditLogColumns = `id, actor_id, action, target_type, target_id, changes, reason, created_at`
+
+type AuditLogRepository struct {
+	db *sql.DB
+}
+
+func NewAuditLogRepository(db *sql.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(ctx context.Context, entry *domain.AuditLogEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("encode audit changes: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO audit_log (`+auditLogColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID,
+		string(changes), entry.Reason,
+		entry.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create audit log entry: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditLogRepository) Find(ctx context.Context, filter domain.AuditLogFilter) ([]domain.AuditLogEntry, error) {
+	var where []string
+	var args []any
+
+	if filter.ActorID != "" {
+		where = append(where, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		where = append(where, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where = append(where, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.Before != "" {
+		where = append(where, "rowid < (SELECT rowid FROM audit_log WHERE id = ?)")
+		args = append(args, filter.Before)
+	}
+
+	query := `SELECT ` + auditLogColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	// rowid follows insertion order, which keeps entries recorded within the
+	// same second in a stable order.
+	query += ` ORDER BY rowid DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find audit log entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditLogEntry
+	for rows.Next() {
+		var e domain.AuditLogEntry
+		var changes, createdAt string
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID,
+			&changes, &e.Reason, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan audit log entry: %w", err)
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, fmt.Errorf("decode audit changes: %w", err)
+		}
+		e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate audit log entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (r *AuditLogRepository) DeleteOlderThan(ctx context.Context, t time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM audit_log WHERE created_at < ?`, t.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete old audit log entries: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const (
+	autoModRuleColumns = `id, name, trigger, config, actions, exempt_channels, exempt_admins, enabled, creator_id, created_at, updated_at`
+	autoModFlagColumns = `id, rule_id, user_id, channel_id, content, blocked, created_at`
+)
+
+type AutoModRepository struct {
+	db *sql.DB
+}
+
+func NewAutoModRepository(db *sql.DB) *AutoModRepository {
+	return &AutoModRepository{db: db}
+}
+
+func (r *AutoModRepository) CreateRule(ctx context.Context, rule *domain.AutoModRule) error {
+	config, actions, exempt, err := encodeAutoModRule(rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO automod_rules (`+autoModRuleColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.Name, rule.Trigger, config, actions, exempt,
+		rule.ExemptAdmins, rule.Enabled, nullString(rule.CreatorID),
+		rule.CreatedAt.UTC().Format(time.RFC3339),
+		rule.UpdatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create automod rule: %w", err)
+	}
+	return nil
+}
+
+func (r *AutoModRepository) GetRules(ctx context.Context) ([]domain.AutoModRule, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+autoModRuleColumns+` FROM automod_rules ORDER BY created_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get automod rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []domain.AutoModRule
+	for rows.Next() {
+		rule, err := scanAutoModRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate automod rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *AutoModRepository) GetRuleByID(ctx context.Context, id string) (*domain.AutoModRule, error) {
+	rule, err := scanAutoModRule(r.db.QueryRowContext(ctx,
+		`SELECT `+autoModRuleColumns+` FROM automod_rules WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rule, err
+}
+
+func (r *AutoModRepository) UpdateRule(ctx context.Context, rule *domain.AutoModRule) error {
+	config, actions, exempt, err := encodeAutoModRule(rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE automod_rules
+		 SET name = ?, config = ?, actions = ?, exempt_channels = ?, exempt_admins = ?, enabled = ?, updated_at = ?
+		 WHERE id = ?`,
+		rule.Name, config, actions, exempt, rule.ExemptAdmins, rule.Enabled,
+		rule.UpdatedAt.UTC().Format(time.RFC3339), rule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update automod rule: %w", err)
+	}
+	return nil
+}
+
+func (r *AutoModRepository) DeleteRule(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM automod_rules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete automod rule: %w", err)
+	}
+	return nil
+}
+
+func (r *AutoModRepository) CreateFlag(ctx context.Context, flag *domain.AutoModFlag) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO automod_flags (`+autoModFlagColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		flag.ID, nullString(flag.RuleID), flag.UserID, flag.ChannelID, flag.Content, flag.Blocked,
+		flag.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create automod flag: %w", err)
+	}
+	return nil
+}
+
+func (r *AutoModRepository) GetFlags(ctx context.Context, limit int) ([]domain.AutoModFlag, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+autoModFlagColumns+` FROM automod_flags ORDER BY rowid DESC LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get automod flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []domain.AutoModFlag
+	for rows.Next() {
+		var f domain.AutoModFlag
+		var ruleID sql.NullString
+		var createdAt string
+		if err := rows.Scan(&f.ID, &ruleID, &f.UserID, &f.ChannelID, &f.Content, &f.Blocked, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan automod flag: %w", err)
+		}
+		f.RuleID = ruleID.String
+		f.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		flags = append(flags, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate automod flags: %w", err)
+	}
+	return flags, nil
+}
+
+func encodeAutoModRule(rule *domain.AutoModRule) (config, actions, exempt string, err error) {
+	c, err := json.Marshal(rule.Config)
+	if err != nil {
+		return "", "", "", fmt.Errorf("encode automod config: %w", err)
+	}
+	a, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return "", "", "", fmt.Errorf("encode automod actions: %w", err)
+	}
+	e, err := json.Marshal(rule.ExemptChannels)
+	if err != nil {
+		return "", "", "", fmt.Errorf("encode automod exemptions: %w", err)
+	}
+	return string(c), string(a), string(e), nil
+}
+
+func scanAutoModRule(row rowScanner) (*domain.AutoModRule, error) {
+	var rule domain.AutoModRule
+	var config, actions, exempt, createdAt, updatedAt string
+	var creatorID sql.NullString
+
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Trigger, &config, &actions, &exempt,
+		&rule.ExemptAdmins, &rule.Enabled, &creatorID, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan automod rule: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(config), &rule.Config); err != nil {
+		return nil, fmt.Errorf("decode automod config: %w", err)
+	}
+	if err := json.Unmarshal([]byte(actions), &rule.Actions); err != nil {
+		return nil, fmt.Errorf("decode automod actions: %w", err)
+	}
+	if err := json.Unmarshal([]byte(exempt), &rule.ExemptChannels); err != nil {
+		return nil, fmt.Errorf("decode automod exemptions: %w", err)
+	}
+	rule.CreatorID = creatorID.String
+	rule.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	rule.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return &rule, nil
+}
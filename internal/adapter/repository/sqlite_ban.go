@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const banColumns = `user_id, moderator_id, reason, expires_at, created_at`
+
+type BanRepository struct {
+	db *sql.DB
+}
+
+func NewBanRepository(db *sql.DB) *BanRepository {
+	return &BanRepository{db: db}
+}
+
+// Create stores the ban, replacing any previous ban of the same user.
+func (r *BanRepository) Create(ctx context.Context, ban *domain.Ban) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO bans (`+banColumns+`)
+		 VALUES (?, ?, ?, ?, ?)`,
+		ban.UserID, nullString(ban.ModeratorID), ban.Reason,
+		nullTime(ban.ExpiresAt),
+		ban.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create ban: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepository) GetByUserID(ctx context.Context, userID string) (*domain.Ban, error) {
+	b, err := scanBan(r.db.QueryRowContext(ctx,
+		`SELECT `+banColumns+` FROM bans WHERE user_id = ?`, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (r *BanRepository) GetActive(ctx context.Context, now time.Time) ([]domain.Ban, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+banColumns+` FROM bans
+		 WHERE expires_at IS NULL OR expires_at > ?
+		 ORDER BY created_at DESC`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get active bans: %w", err)
+	}
+	defer rows.Close()
+
+	var bans []domain.Ban
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate bans: %w", err)
+	}
+	return bans, nil
+}
+
+func (r *BanRepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM bans WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete ban: %w", err)
+	}
+	return nil
+}
+
+func (r *BanRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM bans WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired bans: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanBan(row rowScanner) (*domain.Ban, error) {
+	var b domain.Ban
+	var moderatorID, expiresAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&b.UserID, &moderatorID, &b.Reason, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan ban: %w", err)
+	}
+
+	b.ModeratorID = moderatorID.String
+	b.ExpiresAt = parseNullTime(expiresAt)
+	b.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &b, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const botColumns = `user_id, owner_id, token_hash, permissions, authorized_by, authorized_at, created_at`
+
+type BotRepository struct {
+	db *sql.DB
+}
+
+func NewBotRepository(db *sql.DB) *BotRepository {
+	return &BotRepository{db: db}
+}
+
+func (r *BotRepository) Create(ctx context.Context, user *domain.User, bot *domain.Bot) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createUser(ctx, tx, user); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO bots (`+botColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		bot.UserID, bot.OwnerID, bot.TokenHash, joinScopes(bot.Permissions),
+		nullString(bot.AuthorizedBy), nullTime(bot.AuthorizedAt),
+		bot.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create bot: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit bot: %w", err)
+	}
+	return nil
+}
+
+func (r *BotRepository) GetByUserID(ctx context.Context, userID string) (*domain.Bot, error) {
+	b, err := scanBot(r.db.QueryRowContext(ctx,
+		`SELECT `+botColumns+` FROM bots WHERE user_id = ?`, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (r *BotRepository) GetByHash(ctx context.Context, hash string) (*domain.Bot, error) {
+	b, err := scanBot(r.db.QueryRowContext(ctx,
+		`SELECT `+botColumns+` FROM bots WHERE token_hash = ?`, hash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (r *BotRepository) GetByOwner(ctx context.Context, ownerID string) ([]domain.Bot, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+botColumns+` FROM bots WHERE owner_id = ? ORDER BY created_at`, ownerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get bots: %w", err)
+	}
+	defer rows.Close()
+
+	var bots []domain.Bot
+	for rows.Next() {
+		b, err := scanBot(rows)
+		if err != nil {
+			return nil, err
+		}
+		bots = append(bots, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate bots: %w", err)
+	}
+	return bots, nil
+}
+
+func (r *BotRepository) UpdateToken(ctx context.Context, userID, hash string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE bots SET token_hash = ? WHERE user_id = ?`, hash, userID)
+	if err != nil {
+		return fmt.Errorf("update bot token: %w", err)
+	}
+	return nil
+}
+
+func (r *BotRepository) UpdateAuthorization(ctx context.Context, bot *domain.Bot) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE bots SET permissions = ?, authorized_by = ?, authorized_at = ? WHERE user_id = ?`,
+		joinScopes(bot.Permissions), nullString(bot.AuthorizedBy), nullTime(bot.AuthorizedAt), bot.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("update bot authorization: %w", err)
+	}
+	return nil
+}
+
+func scanBot(row rowScanner) (*domain.Bot, error) {
+	var b domain.Bot
+	var permissions string
+	var authorizedBy, authorizedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&b.UserID, &b.OwnerID, &b.TokenHash, &permissions, &authorizedBy, &authorizedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan bot: %w", err)
+	}
+
+	b.Permissions = splitScopes(permissions)
+	b.AuthorizedBy = authorizedBy.String
+	b.AuthorizedAt = parseNullTime(authorizedAt)
+	b.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &b, nil
+}
+
+func joinScopes(scopes []domain.TokenScope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitScopes(s string) []domain.TokenScope {
+	if s == "" {
+		return nil
+	}
+	var scopes []domain.TokenScope
+	for _, scope := range strings.Split(s, ",") {
+		scopes = append(scopes, domain.TokenScope(scope))
+	}
+	return scopes
+}
@@ -9,6 +9,12 @@ import (
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
+const channelColumns = `id, name, type, slowmode, created_at, updated_at`
+
+// communityChannel restricts queries to the community's channels, leaving out
+// direct messages.
+const communityChannel = `type IN ('text', 'voice')`
+
 type ChannelRepository struct {
 	db *sql.DB
 }
@@ -19,9 +25,9 @@ func NewChannelRepository(db *sql.DB) *ChannelRepository {
 
 func (r *ChannelRepository) Create(ctx context.Context, channel *domain.Channel) error {
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO channels (id, name, type, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?)`,
-		channel.ID, channel.Name, channel.Type,
+		`INSERT INTO channels (`+channelColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		channel.ID, channel.Name, channel.Type, channel.Slowmode,
 		channel.CreatedAt.UTC().Format(time.RFC3339),
 		channel.UpdatedAt.UTC().Format(time.RFC3339),
 	)
@@ -33,13 +39,13 @@ func (r *ChannelRepository) Create(ctx context.Context, channel *domain.Channel)
 
 func (r *ChannelRepository) GetByID(ctx context.Context, id string) (*domain.Channel, error) {
 	return r.scanChannel(r.db.QueryRowContext(ctx,
-		`SELECT id, name, type, created_at, updated_at FROM channels WHERE id = ?`, id,
+		`SELECT `+channelColumns+` FROM channels WHERE id = ? AND `+communityChannel, id,
 	))
 }
 
 func (r *ChannelRepository) GetAll(ctx context.Context) ([]domain.Channel, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, type, created_at, updated_at FROM channels`,
+		`SELECT `+channelColumns+` FROM channels WHERE `+communityChannel,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get all channels: %w", err)
@@ -48,14 +54,11 @@ func (r *ChannelRepository) GetAll(ctx context.Context) ([]domain.Channel, error
 
 	var channels []domain.Channel
 	for rows.Next() {
-		var ch domain.Channel
-		var createdAt, updatedAt string
-		if err := rows.Scan(&ch.ID, &ch.Name, &ch.Type, &createdAt, &updatedAt); err != nil {
-			return nil, fmt.Errorf("scan channel: %w", err)
+		ch, err := r.scanChannel(rows)
+		if err != nil {
+			return nil, err
 		}
-		ch.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		ch.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-		channels = append(channels, ch)
+		channels = append(channels, *ch)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("iterate channels: %w", err)
@@ -65,8 +68,8 @@ func (r *ChannelRepository) GetAll(ctx context.Context) ([]domain.Channel, error
 
 func (r *ChannelRepository) Update(ctx context.Context, channel *domain.Channel) error {
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE channels SET name = ?, updated_at = ? WHERE id = ?`,
-		channel.Name, channel.UpdatedAt.UTC().Format(time.RFC3339), channel.ID,
+		`UPDATE channels SET name = ?, slowmode = ?, updated_at = ? WHERE id = ? AND `+communityChannel,
+		channel.Name, channel.Slowmode, channel.UpdatedAt.UTC().Format(time.RFC3339), channel.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("update channel: %w", err)
@@ -75,18 +78,18 @@ func (r *ChannelRepository) Update(ctx context.Context, channel *domain.Channel)
 }
 
 func (r *ChannelRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM channels WHERE id = ?`, id)
+	_, err := r.db.ExecContext(ctx, `DELETE FROM channels WHERE id = ? AND `+communityChannel, id)
 	if err != nil {
 		return fmt.Errorf("delete channel: %w", err)
 	}
 	return nil
 }
 
-func (r *ChannelRepository) scanChannel(row *sql.Row) (*domain.Channel, error) {
+func (r *ChannelRepository) scanChannel(row rowScanner) (*domain.Channel, error) {
 	var ch domain.Channel
 	var createdAt, updatedAt string
 
-	err := row.Scan(&ch.ID, &ch.Name, &ch.Type, &createdAt, &updatedAt)
+	err := row.Scan(&ch.ID, &ch.Name, &ch.Type, &ch.Slowmode, &createdAt, &updatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const dmChannelColumns = `c.id, c.name, c.type, c.slowmode, c.owner_id, c.icon, c.created_at, c.updated_at,
+	(SELECT GROUP_CONCAT(user_id) FROM (SELECT user_id FROM channel_recipients WHERE channel_id = c.id ORDER BY rowid)),
+	COALESCE((SELECT MAX(created_at) FROM messages WHERE channel_id = c.id), c.created_at) AS last_activity_at`
+
+type DMChannelRepository struct {
+	db *sql.DB
+}
+
+func NewDMChannelRepository(db *sql.DB) *DMChannelRepository {
+	return &DMChannelRepository{db: db}
+}
+
+func (r *DMChannelRepository) Create(ctx context.Context, channel *domain.DMChannel) error {
+	_, err := r.create(ctx, channel, "")
+	return err
+}
+
+func (r *DMChannelRepository) CreateDM(ctx context.Context, channel *domain.DMChannel) (*domain.DMChannel, bool, error) {
+	key := dmKey(channel.RecipientIDs[0], channel.RecipientIDs[1])
+	created, err := r.create(ctx, channel, key)
+	if err != nil || created {
+		return channel, created, err
+	}
+
+	existing, err := r.getByKey(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, fmt.Errorf("get dm channel: conflicting channel not found")
+	}
+	return existing, false, nil
+}
+
+// create inserts the channel along with its recipients. It returns false
+// when a one-to-one channel with the same key already exists.
+func (r *DMChannelRepository) create(ctx context.Context, channel *domain.DMChannel, key string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO channels (id, name, type, slowmode, owner_id, icon, dm_key, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (dm_key) DO NOTHING`,
+		channel.ID, channel.Name, channel.Type, channel.Slowmode, nullString(channel.OwnerID), channel.Icon,
+		nullString(key),
+		channel.CreatedAt.UTC().Format(time.RFC3339),
+		channel.UpdatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, fmt.Errorf("create dm channel: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("create dm channel: %w", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	for _, userID := range channel.RecipientIDs {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO channel_recipients (channel_id, user_id) VALUES (?, ?)`,
+			channel.ID, userID,
+		)
+		if err != nil {
+			return false, fmt.Errorf("add dm recipient: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit dm channel: %w", err)
+	}
+	return true, nil
+}
+
+func (r *DMChannelRepository) GetByID(ctx context.Context, id string) (*domain.DMChannel, error) {
+	return r.scanDMChannel(r.db.QueryRowContext(ctx,
+		`SELECT `+dmChannelColumns+` FROM channels c WHERE c.id = ? AND c.type IN (?, ?)`,
+		id, domain.ChannelTypeDM, domain.ChannelTypeGroupDM,
+	))
+}
+
+func (r *DMChannelRepository) GetByRecipients(ctx context.Context, userID, otherID string) (*domain.DMChannel, error) {
+	return r.getByKey(ctx, dmKey(userID, otherID))
+}
+
+func (r *DMChannelRepository) getByKey(ctx context.Context, key string) (*domain.DMChannel, error) {
+	return r.scanDMChannel(r.db.QueryRowContext(ctx,
+		`SELECT `+dmChannelColumns+` FROM channels c WHERE c.dm_key = ?`, key,
+	))
+}
+
+// dmKey identifies the one-to-one channel between two users whichever of
+// them opened it.
+func dmKey(userID, otherID string) string {
+	ids := []string{userID, otherID}
+	slices.Sort(ids)
+	return strings.Join(ids, ":")
+}
+
+func (r *DMChannelRepository) GetByUser(ctx context.Context, userID string) ([]domain.DMChannel, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+dmChannelColumns+` FROM channels c
+		 JOIN channel_recipients cr ON cr.channel_id = c.id
+		 WHERE cr.user_id = ? AND c.type IN (?, ?)
+		 ORDER BY last_activity_at DESC, c.rowid DESC`,
+		userID, domain.ChannelTypeDM, domain.ChannelTypeGroupDM,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get dm channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []domain.DMChannel
+	for rows.Next() {
+		ch, err := r.scanDMChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate dm channels: %w", err)
+	}
+	return channels, nil
+}
+
+func (r *DMChannelRepository) Update(ctx context.Context, channel *domain.DMChannel) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE channels SET name = ?, icon = ?, owner_id = ?, updated_at = ? WHERE id = ? AND type = ?`,
+		channel.Name, channel.Icon, nullString(channel.OwnerID), channel.UpdatedAt.UTC().Format(time.RFC3339),
+		channel.ID, domain.ChannelTypeGroupDM,
+	)
+	if err != nil {
+		return fmt.Errorf("update dm channel: %w", err)
+	}
+	return nil
+}
+
+func (r *DMChannelRepository) AddRecipient(ctx context.Context, channelID, userID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO channel_recipients (channel_id, user_id) VALUES (?, ?)`,
+		channelID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("add dm recipient: %w", err)
+	}
+	return nil
+}
+
+func (r *DMChannelRepository) RemoveRecipient(ctx context.Context, channelID, userID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM channel_recipients WHERE channel_id = ? AND user_id = ?`,
+		channelID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("remove dm recipient: %w", err)
+	}
+	return nil
+}
+
+func (r *DMChannelRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM channels WHERE id = ? AND type IN (?, ?)`,
+		id, domain.ChannelTypeDM, domain.ChannelTypeGroupDM,
+	)
+	if err != nil {
+		return fmt.Errorf("delete dm channel: %w", err)
+	}
+	return nil
+}
+
+func (r *DMChannelRepository) scanDMChannel(row rowScanner) (*domain.DMChannel, error) {
+	var ch domain.DMChannel
+	var createdAt, updatedAt, lastActivityAt string
+	var ownerID, recipients sql.NullString
+
+	err := row.Scan(&ch.ID, &ch.Name, &ch.Type, &ch.Slowmode, &ownerID, &ch.Icon, &createdAt, &updatedAt, &recipients, &lastActivityAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan dm channel: %w", err)
+	}
+
+	ch.OwnerID = ownerID.String
+	if recipients.Valid {
+		ch.RecipientIDs = strings.Split(recipients.String, ",")
+	}
+	ch.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	ch.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	ch.LastActivityAt, _ = time.Parse(time.RFC3339, lastActivityAt)
+	return &ch, nil
+}
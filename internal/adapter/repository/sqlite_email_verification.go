@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const emailVerificationColumns = `token_hash, user_id, email, expires_at, created_at`
+
+type EmailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+func (r *EmailVerificationRepository) Create(ctx context.Context, v *domain.EmailVerification) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO email_verifications (`+emailVerificationColumns+`)
+		 VALUES (?, ?, ?, ?, ?)`,
+		v.TokenHash, v.UserID, v.Email,
+		v.ExpiresAt.UTC().Format(time.RFC3339),
+		v.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create email verification: %w", err)
+	}
+	return nil
+}
+
+func (r *EmailVerificationRepository) GetByHash(ctx context.Context, hash string) (*domain.EmailVerification, error) {
+	var v domain.EmailVerification
+	var expiresAt, createdAt string
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT `+emailVerificationColumns+` FROM email_verifications WHERE token_hash = ?`, hash,
+	).Scan(&v.TokenHash, &v.UserID, &v.Email, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan email verification: %w", err)
+	}
+
+	v.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	v.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &v, nil
+}
+
+func (r *EmailVerificationRepository) Delete(ctx context.Context, hash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM email_verifications WHERE token_hash = ?`, hash)
+	if err != nil {
+		return false, fmt.Errorf("delete email verification: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete email verification: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *EmailVerificationRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM email_verifications WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete email verifications: %w", err)
+	}
+	return nil
+}
+
+func (r *EmailVerificationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM email_verifications WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired email verifications: %w", err)
+	}
+	return res.RowsAffected()
+}
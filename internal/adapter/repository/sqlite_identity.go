@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const identityColumns = `provider, subject, user_id, email, created_at`
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	identity, err := scanIdentity(r.db.QueryRowContext(ctx,
+		`SELECT `+identityColumns+` FROM user_identities WHERE provider = ? AND subject = ?`,
+		provider, subject,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return identity, err
+}
+
+func (r *IdentityRepository) GetIdentitiesByProvider(ctx context.Context, provider string) ([]domain.UserIdentity, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+identityColumns+` FROM user_identities WHERE provider = ? ORDER BY created_at`,
+		provider,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get user identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []domain.UserIdentity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate user identities: %w", err)
+	}
+	return identities, nil
+}
+
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, i *domain.UserIdentity) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_identities (`+identityColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		i.Provider, i.Subject, i.UserID, i.Email, i.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create user identity: %w", err)
+	}
+	return nil
+}
+
+func scanIdentity(row rowScanner) (*domain.UserIdentity, error) {
+	var i domain.UserIdentity
+	var createdAt string
+
+	err := row.Scan(&i.Provider, &i.Subject, &i.UserID, &i.Email, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan user identity: %w", err)
+	}
+
+	i.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &i, nil
+}
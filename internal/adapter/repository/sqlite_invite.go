@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const inviteColumns = `code, creator_id, channel_id, max_uses, uses, temporary, expires_at, created_at`
+
+type InviteRepository struct {
+	db *sql.DB
+}
+
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+func (r *InviteRepository) Create(ctx context.Context, invite *domain.Invite) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO invites (`+inviteColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		invite.Code, invite.CreatorID, nullString(invite.ChannelID),
+		invite.MaxUses, invite.Uses, invite.Temporary,
+		nullTime(invite.ExpiresAt),
+		invite.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create invite: %w", err)
+	}
+	return nil
+}
+
+func (r *InviteRepository) GetAll(ctx context.Context) ([]domain.Invite, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+inviteColumns+` FROM invites ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get all invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []domain.Invite
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate invites: %w", err)
+	}
+	return invites, nil
+}
+
+func (r *InviteRepository) GetByCode(ctx context.Context, code string) (*domain.Invite, error) {
+	inv, err := scanInvite(r.db.QueryRowContext(ctx,
+		`SELECT `+inviteColumns+` FROM invites WHERE code = ?`, code,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+func (r *InviteRepository) Use(ctx context.Context, code string, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE invites SET uses = uses + 1
+		 WHERE code = ?
+		   AND (max_uses = 0 OR uses < max_uses)
+		   AND (expires_at IS NULL OR expires_at > ?)`,
+		code, now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, fmt.Errorf("use invite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use invite: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *InviteRepository) Delete(ctx context.Context, code string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM invites WHERE code = ?`, code)
+	if err != nil {
+		return fmt.Errorf("delete invite: %w", err)
+	}
+	return nil
+}
+
+func (r *InviteRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM invites WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired invites: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanInvite(row rowScanner) (*domain.Invite, error) {
+	var inv domain.Invite
+	var channelID, expiresAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&inv.Code, &inv.CreatorID, &channelID, &inv.MaxUses, &inv.Uses,
+		&inv.Temporary, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan invite: %w", err)
+	}
+
+	inv.ChannelID = channelID.String
+	inv.ExpiresAt = parseNullTime(expiresAt)
+	inv.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &inv, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const loginAttemptColumns = `key, failures, last_failure_at, locked_until`
+
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts, err := scanLoginAttempts(r.db.QueryRowContext(ctx,
+		`SELECT `+loginAttemptColumns+` FROM login_attempts WHERE key = ?`, key,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return attempts, err
+}
+
+// Reserve only writes when the row is exactly as seen, so that parallel
+// logins that all passed the same check cannot all be counted from it.
+func (r *LoginAttemptRepository) Reserve(ctx context.Context, key string, seen *domain.LoginAttempts, now, resetBefore time.Time) (bool, error) {
+	nowStr := now.UTC().Format(time.RFC3339)
+	resetStr := resetBefore.UTC().Format(time.RFC3339)
+
+	var res sql.Result
+	var err error
+	if seen == nil {
+		res, err = r.db.ExecContext(ctx,
+			`INSERT INTO login_attempts (key, failures, last_failure_at) VALUES (?, 1, ?)
+			 ON CONFLICT (key) DO NOTHING`,
+			key, nowStr,
+		)
+	} else {
+		res, err = r.db.ExecContext(ctx,
+			`UPDATE login_attempts SET
+			     failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+			     locked_until = CASE WHEN last_failure_at < ? THEN NULL ELSE locked_until END,
+			     last_failure_at = ?
+			 WHERE key = ? AND failures = ? AND last_failure_at = ? AND locked_until IS ?`,
+			resetStr, resetStr, nowStr,
+			key, seen.Failures, seen.LastFailureAt.UTC().Format(time.RFC3339), nullTime(seen.LockedUntil),
+		)
+	}
+	if err != nil {
+		return false, fmt.Errorf("reserve login attempt: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("reserve login attempt: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *LoginAttemptRepository) Release(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE login_attempts SET failures = failures - 1 WHERE key = ? AND failures > 0`, key,
+	)
+	if err != nil {
+		return fmt.Errorf("release login attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE login_attempts SET locked_until = ? WHERE key = ?`,
+		until.UTC().Format(time.RFC3339), key,
+	)
+	if err != nil {
+		return fmt.Errorf("lock login: %w", err)
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	args := make([]any, len(keys))
+	for i, k := range keys {
+		args[i] = k
+	}
+
+	res, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete login attempts: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM login_attempts
+		 WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until <= ?)`,
+		before.UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete stale login attempts: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanLoginAttempts(row rowScanner) (*domain.LoginAttempts, error) {
+	var a domain.LoginAttempts
+	var lastFailureAt string
+	var lockedUntil sql.NullString
+
+	err := row.Scan(&a.Key, &a.Failures, &lastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan login attempts: %w", err)
+	}
+
+	a.LastFailureAt, _ = time.Parse(time.RFC3339, lastFailureAt)
+	a.LockedUntil = parseNullTime(lockedUntil)
+	return &a, nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const memberColumns = `user_id, invite_code, temporary, timed_out_until, joined_at`
+
+type MemberRepository struct {
+	db *sql.DB
+}
+
+func NewMemberRepository(db *sql.DB) *MemberRepository {
+	return &MemberRepository{db: db}
+}
+
+func (r *MemberRepository) Create(ctx context.Context, member *domain.Member) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO members (`+memberColumns+`)
+		 VALUES (?, ?, ?, ?, ?)`,
+		member.UserID, nullString(member.InviteCode), member.Temporary,
+		nullTime(member.TimedOutUntil),
+		member.JoinedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create member: %w", err)
+	}
+	return nil
+}
+
+func (r *MemberRepository) GetByUserID(ctx context.Context, userID string) (*domain.Member, error) {
+	m, err := scanMember(r.db.QueryRowContext(ctx,
+		`SELECT `+memberColumns+` FROM members WHERE user_id = ?`, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (r *MemberRepository) GetTimedOut(ctx context.Context, now time.Time) ([]domain.Member, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+memberColumns+` FROM members
+		 WHERE timed_out_until IS NOT NULL AND timed_out_until > ?
+		 ORDER BY timed_out_until`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get timed out members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []domain.Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *MemberRepository) GetTemporary(ctx context.Context, joinedBefore time.Time) ([]domain.Member, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+memberColumns+` FROM members
+		 WHERE temporary = 1 AND joined_at < ?
+		 ORDER BY joined_at`,
+		joinedBefore.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get temporary members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []domain.Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *MemberRepository) SetTimeout(ctx context.Context, userID string, until *time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE members SET timed_out_until = ? WHERE user_id = ?`,
+		nullTime(until), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("set member timeout: %w", err)
+	}
+	return nil
+}
+
+func (r *MemberRepository) ClearExpiredTimeouts(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE members SET timed_out_until = NULL
+		 WHERE timed_out_until IS NOT NULL AND timed_out_until <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("clear expired timeouts: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *MemberRepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM members WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+	return nil
+}
+
+func scanMember(row rowScanner) (*domain.Member, error) {
+	var m domain.Member
+	var inviteCode, timedOutUntil sql.NullString
+	var joinedAt string
+
+	err := row.Scan(&m.UserID, &inviteCode, &m.Temporary, &timedOutUntil, &joinedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan member: %w", err)
+	}
+
+	m.InviteCode = inviteCode.String
+	m.TimedOutUntil = parseNullTime(timedOutUntil)
+	m.JoinedAt, _ = time.Parse(time.RFC3339, joinedAt)
+	return &m, nil
+}
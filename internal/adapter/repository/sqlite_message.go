@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const messageColumns = `id, channel_id, author_id, type, content, embeds, webhook_id, webhook_username, webhook_avatar_url, edited_at, created_at`
+
+type MessageRepository struct {
+	db *sql.DB
+}
+
+func NewMessageRepository(db *sql.DB) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+func (r *MessageRepository) Create(ctx context.Context, message *domain.Message) error {
+	var embeds sql.NullString
+	if len(message.Embeds) > 0 {
+		b, err := json.Marshal(message.Embeds)
+		if err != nil {
+			return fmt.Errorf("encode embeds: %w", err)
+		}
+		embeds = sql.NullString{String: string(b), Valid: true}
+	}
+	var webhook domain.MessageWebhook
+	if message.Webhook != nil {
+		webhook = *message.Webhook
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO messages (`+messageColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		message.ID, message.ChannelID, nullString(message.AuthorID), message.Type, message.Content, embeds,
+		nullString(webhook.ID), nullString(webhook.Username), nullString(webhook.AvatarURL),
+		nullTime(message.EditedAt),
+		message.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create message: %w", err)
+	}
+	return nil
+}
+
+// Find returns the channel's messages newest first. rowid follows insertion
+// order, which keeps messages posted within the same second in order.
+func (r *MessageRepository) Find(ctx context.Context, query domain.MessageQuery) ([]domain.Message, error) {
+	q := `SELECT ` + messageColumns + ` FROM messages WHERE channel_id = ?`
+	args := []any{query.ChannelID}
+	if query.Before != "" {
+		q += ` AND rowid < (SELECT rowid FROM messages WHERE id = ?)`
+		args = append(args, query.Before)
+	}
+	q += ` ORDER BY rowid DESC LIMIT ?`
+	args = append(args, query.Limit)
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []domain.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *MessageRepository) GetByID(ctx context.Context, id string) (*domain.Message, error) {
+	m, err := scanMessage(r.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (r *MessageRepository) CountByAuthorSince(ctx context.Context, authorID, content string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM messages
+		 WHERE author_id = ? AND content = ? AND created_at >= ?`,
+		authorID, content, since.UTC().Format(time.RFC3339),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count messages: %w", err)
+	}
+	return n, nil
+}
+
+func (r *MessageRepository) DeleteByAuthorSince(ctx context.Context, authorID string, since time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM messages
+		 WHERE author_id = ? AND created_at >= ?
+		   AND channel_id IN (SELECT id FROM channels WHERE type IN (?, ?))`,
+		authorID, since.UTC().Format(time.RFC3339), domain.ChannelTypeText, domain.ChannelTypeVoice,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete messages: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *MessageRepository) Update(ctx context.Context, message *domain.Message) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE messages SET content = ?, edited_at = ? WHERE id = ?`,
+		message.Content, nullTime(message.EditedAt), message.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update message: %w", err)
+	}
+	return nil
+}
+
+func (r *MessageRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+	return nil
+}
+
+func scanMessage(row rowScanner) (*domain.Message, error) {
+	var m domain.Message
+	var authorID, embeds, webhookID, webhookUsername, webhookAvatarURL, editedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&m.ID, &m.ChannelID, &authorID, &m.Type, &m.Content, &embeds,
+		&webhookID, &webhookUsername, &webhookAvatarURL, &editedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan message: %w", err)
+	}
+
+	m.AuthorID = authorID.String
+	if embeds.Valid {
+		if err := json.Unmarshal([]byte(embeds.String), &m.Embeds); err != nil {
+			return nil, fmt.Errorf("decode embeds: %w", err)
+		}
+	}
+	if webhookID.Valid {
+		m.Webhook = &domain.MessageWebhook{
+			ID:        webhookID.String,
+			Username:  webhookUsername.String,
+			AvatarURL: webhookAvatarURL.String,
+		}
+	}
+	m.EditedAt = parseNullTime(editedAt)
+	m.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &m, nil
+}
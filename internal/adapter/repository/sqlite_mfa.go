@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) GetTOTP(ctx context.Context, userID string) (*domain.TOTPCredential, error) {
+	var c domain.TOTPCredential
+	var createdAt string
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, enabled, last_used_step, created_at FROM totp_credentials WHERE user_id = ?`, userID,
+	).Scan(&c.UserID, &c.Secret, &c.Enabled, &c.LastUsedStep, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan totp credential: %w", err)
+	}
+
+	c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &c, nil
+}
+
+func (r *MFARepository) PutTOTP(ctx context.Context, c *domain.TOTPCredential) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO totp_credentials (user_id, secret, enabled, last_used_step, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		c.UserID, c.Secret, c.Enabled, c.LastUsedStep,
+		c.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("put totp credential: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE totp_credentials SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`,
+		step, userID, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use totp step: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *MFARepository) DeleteTOTP(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete totp credential: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	for _, hash := range hashes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (code_hash, user_id, created_at) VALUES (?, ?, ?)`,
+			hash, userID, now.UTC().Format(time.RFC3339),
+		)
+		if err != nil {
+			return fmt.Errorf("create recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, hash string, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = ? WHERE code_hash = ? AND user_id = ? AND used_at IS NULL`,
+		now.UTC().Format(time.RFC3339), hash, userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+func (r *MFARepository) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) CreateChallenge(ctx context.Context, ch *domain.MFAChallenge) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO mfa_challenges (token_hash, user_id, attempts, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		ch.TokenHash, ch.UserID, ch.Attempts,
+		ch.ExpiresAt.UTC().Format(time.RFC3339),
+		ch.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create mfa challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) GetChallenge(ctx context.Context, hash string) (*domain.MFAChallenge, error) {
+	var ch domain.MFAChallenge
+	var expiresAt, createdAt string
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT token_hash, user_id, attempts, passkey_state, expires_at, created_at FROM mfa_challenges WHERE token_hash = ?`, hash,
+	).Scan(&ch.TokenHash, &ch.UserID, &ch.Attempts, &ch.PasskeyState, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan mfa challenge: %w", err)
+	}
+
+	ch.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	ch.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &ch, nil
+}
+
+func (r *MFARepository) UseChallengeAttempt(ctx context.Context, hash string, max int) (bool, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE mfa_challenges SET attempts = attempts + 1
+		 WHERE token_hash = ? AND attempts < ?
+		 RETURNING attempts`,
+		hash, max,
+	).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("use mfa challenge attempt: %w", err)
+	}
+	return true, nil
+}
+
+func (r *MFARepository) SetChallengePasskeyState(ctx context.Context, hash string, state []byte) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE mfa_challenges SET passkey_state = ? WHERE token_hash = ?`, state, hash)
+	if err != nil {
+		return fmt.Errorf("set mfa challenge passkey state: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) DeleteChallenge(ctx context.Context, hash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE token_hash = ?`, hash)
+	if err != nil {
+		return false, fmt.Errorf("delete mfa challenge: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete mfa challenge: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *MFARepository) DeleteExpiredChallenges(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM mfa_challenges WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired mfa challenges: %w", err)
+	}
+	return res.RowsAffected()
+}
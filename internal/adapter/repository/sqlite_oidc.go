@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type OIDCRepository struct {
+	db *sql.DB
+}
+
+func NewOIDCRepository(db *sql.DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+func (r *OIDCRepository) CreateState(ctx context.Context, s *domain.OIDCState) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO oidc_states (state_hash, provider, code_verifier, nonce, user_id, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.StateHash, s.Provider, s.CodeVerifier, s.Nonce, nullString(s.UserID),
+		s.ExpiresAt.UTC().Format(time.RFC3339),
+		s.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create oidc state: %w", err)
+	}
+	return nil
+}
+
+func (r *OIDCRepository) TakeState(ctx context.Context, hash string) (*domain.OIDCState, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var s domain.OIDCState
+	var userID sql.NullString
+	var expiresAt, createdAt string
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT state_hash, provider, code_verifier, nonce, user_id, expires_at, created_at FROM oidc_states WHERE state_hash = ?`, hash,
+	).Scan(&s.StateHash, &s.Provider, &s.CodeVerifier, &s.Nonce, &userID, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan oidc state: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oidc_states WHERE state_hash = ?`, hash); err != nil {
+		return nil, fmt.Errorf("delete oidc state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit oidc state: %w", err)
+	}
+
+	s.UserID = userID.String
+	s.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	s.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &s, nil
+}
+
+func (r *OIDCRepository) DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM oidc_states WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired oidc states: %w", err)
+	}
+	return res.RowsAffected()
+}
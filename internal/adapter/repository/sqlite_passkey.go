@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const passkeyColumns = `id, user_id, name, public_key, attestation_type, aaguid, sign_count, transports,
+	backup_eligible, backup_state, last_used_at, created_at`
+
+type PasskeyRepository struct {
+	db *sql.DB
+}
+
+func NewPasskeyRepository(db *sql.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+func (r *PasskeyRepository) Create(ctx context.Context, p *domain.Passkey) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO passkeys (`+passkeyColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.UserID, p.Name, p.PublicKey, p.AttestationType, p.AAGUID, p.SignCount,
+		strings.Join(p.Transports, ","), p.BackupEligible, p.BackupState,
+		nullTime(p.LastUsedAt),
+		p.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create passkey: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) GetByID(ctx context.Context, id string) (*domain.Passkey, error) {
+	p, err := scanPasskey(r.db.QueryRowContext(ctx,
+		`SELECT `+passkeyColumns+` FROM passkeys WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+func (r *PasskeyRepository) GetByUser(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+passkeyColumns+` FROM passkeys WHERE user_id = ? ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var passkeys []domain.Passkey
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate passkeys: %w", err)
+	}
+	return passkeys, nil
+}
+
+func (r *PasskeyRepository) UpdateUsage(ctx context.Context, id string, signCount uint32, backupState bool, now time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE passkeys SET sign_count = ?, backup_state = ?, last_used_at = ? WHERE id = ?`,
+		signCount, backupState, now.UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("update passkey usage: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM passkeys WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete passkey: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM passkeys WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete passkeys: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) CreateCeremony(ctx context.Context, c *domain.PasskeyCeremony) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO passkey_ceremonies (token_hash, type, user_id, state, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		c.TokenHash, c.Type, nullString(c.UserID), c.State,
+		c.ExpiresAt.UTC().Format(time.RFC3339),
+		c.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create passkey ceremony: %w", err)
+	}
+	return nil
+}
+
+func (r *PasskeyRepository) TakeCeremony(ctx context.Context, hash string) (*domain.PasskeyCeremony, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var c domain.PasskeyCeremony
+	var userID sql.NullString
+	var expiresAt, createdAt string
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT token_hash, type, user_id, state, expires_at, created_at FROM passkey_ceremonies WHERE token_hash = ?`, hash,
+	).Scan(&c.TokenHash, &c.Type, &userID, &c.State, &expiresAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan passkey ceremony: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM passkey_ceremonies WHERE token_hash = ?`, hash); err != nil {
+		return nil, fmt.Errorf("delete passkey ceremony: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit passkey ceremony: %w", err)
+	}
+
+	c.UserID = userID.String
+	c.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	c.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &c, nil
+}
+
+func (r *PasskeyRepository) DeleteExpiredCeremonies(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM passkey_ceremonies WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired passkey ceremonies: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanPasskey(row rowScanner) (*domain.Passkey, error) {
+	var p domain.Passkey
+	var transports string
+	var lastUsedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.PublicKey, &p.AttestationType, &p.AAGUID, &p.SignCount,
+		&transports, &p.BackupEligible, &p.BackupState, &lastUsedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan passkey: %w", err)
+	}
+
+	if transports != "" {
+		p.Transports = strings.Split(transports, ",")
+	}
+	p.LastUsedAt = parseNullTime(lastUsedAt)
+	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &p, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const passwordResetColumns = `token_hash, user_id, expires_at, used_at, created_at`
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+func (r *PasswordResetRepository) Create(ctx context.Context, reset *domain.PasswordReset) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO password_resets (`+passwordResetColumns+`)
+		 VALUES (?, ?, ?, ?, ?)`,
+		reset.TokenHash, reset.UserID,
+		reset.ExpiresAt.UTC().Format(time.RFC3339),
+		nullTime(reset.UsedAt),
+		reset.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create password reset: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) GetByHash(ctx context.Context, hash string) (*domain.PasswordReset, error) {
+	var p domain.PasswordReset
+	var expiresAt, createdAt string
+	var usedAt sql.NullString
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT `+passwordResetColumns+` FROM password_resets WHERE token_hash = ?`, hash,
+	).Scan(&p.TokenHash, &p.UserID, &expiresAt, &usedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan password reset: %w", err)
+	}
+
+	p.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	p.UsedAt = parseNullTime(usedAt)
+	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &p, nil
+}
+
+func (r *PasswordResetRepository) Use(ctx context.Context, hash string, now time.Time) (bool, error) {
+	ts := now.UTC().Format(time.RFC3339)
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = ?
+		 WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		ts, hash, ts,
+	)
+	if err != nil {
+		return false, fmt.Errorf("use password reset: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use password reset: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *PasswordResetRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete password resets: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM password_resets WHERE expires_at <= ? OR used_at IS NOT NULL`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired password resets: %w", err)
+	}
+	return res.RowsAffected()
+}
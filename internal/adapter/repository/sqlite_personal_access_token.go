@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const personalAccessTokenColumns = `id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at`
+
+type PersonalAccessTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, t *domain.PersonalAccessToken) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO personal_access_tokens (`+personalAccessTokenColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.UserID, t.Name, t.TokenHash, joinScopes(t.Scopes),
+		nullTime(t.ExpiresAt), nullTime(t.LastUsedAt),
+		t.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create personal access token: %w", err)
+	}
+	return nil
+}
+
+func (r *PersonalAccessTokenRepository) GetByID(ctx context.Context, id string) (*domain.PersonalAccessToken, error) {
+	t, err := scanPersonalAccessToken(r.db.QueryRowContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (r *PersonalAccessTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.PersonalAccessToken, error) {
+	t, err := scanPersonalAccessToken(r.db.QueryRowContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE token_hash = ?`, hash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (r *PersonalAccessTokenRepository) GetByUser(ctx context.Context, userID string) ([]domain.PersonalAccessToken, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []domain.PersonalAccessToken
+	for rows.Next() {
+		t, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate personal access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (r *PersonalAccessTokenRepository) UpdateLastUsed(ctx context.Context, id string, now time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`,
+		now.UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("update personal access token usage: %w", err)
+	}
+	return nil
+}
+
+func (r *PersonalAccessTokenRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete personal access token: %w", err)
+	}
+	return nil
+}
+
+func (r *PersonalAccessTokenRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("delete personal access tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *PersonalAccessTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM personal_access_tokens WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired personal access tokens: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanPersonalAccessToken(row rowScanner) (*domain.PersonalAccessToken, error) {
+	var t domain.PersonalAccessToken
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullString
+	var createdAt string
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &scopes, &expiresAt, &lastUsedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan personal access token: %w", err)
+	}
+
+	t.Scopes = splitScopes(scopes)
+	t.ExpiresAt = parseNullTime(expiresAt)
+	t.LastUsedAt = parseNullTime(lastUsedAt)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &t, nil
+}
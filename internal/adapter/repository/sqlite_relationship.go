@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const relationshipColumns = `user_id, target_id, type, created_at`
+
+type RelationshipRepository struct {
+	db *sql.DB
+}
+
+func NewRelationshipRepository(db *sql.DB) *RelationshipRepository {
+	return &RelationshipRepository{db: db}
+}
+
+func (r *RelationshipRepository) Put(ctx context.Context, relationship *domain.Relationship) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO relationships (`+relationshipColumns+`)
+		 VALUES (?, ?, ?, ?)`,
+		relationship.UserID, relationship.TargetID, relationship.Type,
+		relationship.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("put relationship: %w", err)
+	}
+	return nil
+}
+
+func (r *RelationshipRepository) Get(ctx context.Context, userID, targetID string) (*domain.Relationship, error) {
+	rel, err := scanRelationship(r.db.QueryRowContext(ctx,
+		`SELECT `+relationshipColumns+` FROM relationships WHERE user_id = ? AND target_id = ?`,
+		userID, targetID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rel, err
+}
+
+// GetByUser returns the user's relationships of the given types, or of every
+// type when none is given, oldest first.
+func (r *RelationshipRepository) GetByUser(ctx context.Context, userID string, types ...domain.RelationshipType) ([]domain.Relationship, error) {
+	q := `SELECT ` + relationshipColumns + ` FROM relationships WHERE user_id = ?`
+	args := []any{userID}
+	if len(types) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")
+		q += ` AND type IN (` + placeholders + `)`
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	q += ` ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get relationships: %w", err)
+	}
+	defer rows.Close()
+
+	var relationships []domain.Relationship
+	for rows.Next() {
+		rel, err := scanRelationship(rows)
+		if err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, *rel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate relationships: %w", err)
+	}
+	return relationships, nil
+}
+
+func (r *RelationshipRepository) Delete(ctx context.Context, userID, targetID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM relationships WHERE user_id = ? AND target_id = ?`,
+		userID, targetID,
+	)
+	if err != nil {
+		return fmt.Errorf("delete relationship: %w", err)
+	}
+	return nil
+}
+
+func scanRelationship(row rowScanner) (*domain.Relationship, error) {
+	var rel domain.Relationship
+	var createdAt string
+
+	err := row.Scan(&rel.UserID, &rel.TargetID, &rel.Type, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan relationship: %w", err)
+	}
+
+	rel.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &rel, nil
+}
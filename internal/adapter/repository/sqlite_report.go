@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const reportColumns = `id, reporter_id, target_type, target_user_id, message_id, channel_id, category, reason,
+	snapshot_author_id, snapshot_webhook_id, snapshot_username, snapshot_content, snapshot_created_at,
+	status, moderator_id, note, created_at, updated_at`
+
+type ReportRepository struct {
+	db *sql.DB
+}
+
+func NewReportRepository(db *sql.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+func (r *ReportRepository) Create(ctx context.Context, report *domain.Report) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO reports (`+reportColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.ID, report.ReporterID, report.TargetType, report.TargetUserID,
+		nullString(report.MessageID), nullString(report.ChannelID),
+		report.Category, report.Reason,
+		report.Snapshot.AuthorID, nullString(report.Snapshot.WebhookID), report.Snapshot.Username, report.Snapshot.Content,
+		report.Snapshot.CreatedAt.UTC().Format(time.RFC3339),
+		report.Status, nullString(report.ModeratorID), report.Note,
+		report.CreatedAt.UTC().Format(time.RFC3339),
+		report.UpdatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+	return nil
+}
+
+func (r *ReportRepository) GetByID(ctx context.Context, id string) (*domain.Report, error) {
+	report, err := scanReport(r.db.QueryRowContext(ctx,
+		`SELECT `+reportColumns+` FROM reports WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return report, err
+}
+
+func (r *ReportRepository) GetByStatus(ctx context.Context, statuses ...domain.ReportStatus) ([]domain.Report, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+	args := make([]any, len(statuses))
+	for i, s := range statuses {
+		args[i] = s
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+reportColumns+` FROM reports
+		 WHERE status IN (`+placeholders+`)
+		 ORDER BY created_at`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []domain.Report
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (r *ReportRepository) HasOpen(ctx context.Context, reporterID, targetUserID, messageID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (
+		     SELECT 1 FROM reports
+		     WHERE reporter_id = ? AND target_user_id = ? AND IFNULL(message_id, '') = ?
+		       AND status IN ('open', 'escalated')
+		 )`,
+		reporterID, targetUserID, messageID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check open report: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *ReportRepository) Update(ctx context.Context, report *domain.Report) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE reports SET status = ?, moderator_id = ?, note = ?, updated_at = ? WHERE id = ?`,
+		report.Status, nullString(report.ModeratorID), report.Note,
+		report.UpdatedAt.UTC().Format(time.RFC3339), report.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update report: %w", err)
+	}
+	return nil
+}
+
+func scanReport(row rowScanner) (*domain.Report, error) {
+	var rep domain.Report
+	var messageID, channelID, webhookID, moderatorID sql.NullString
+	var snapshotCreatedAt, createdAt, updatedAt string
+
+	err := row.Scan(&rep.ID, &rep.ReporterID, &rep.TargetType, &rep.TargetUserID,
+		&messageID, &channelID, &rep.Category, &rep.Reason,
+		&rep.Snapshot.AuthorID, &webhookID, &rep.Snapshot.Username, &rep.Snapshot.Content, &snapshotCreatedAt,
+		&rep.Status, &moderatorID, &rep.Note, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan report: %w", err)
+	}
+
+	rep.MessageID = messageID.String
+	rep.ChannelID = channelID.String
+	rep.Snapshot.WebhookID = webhookID.String
+	rep.ModeratorID = moderatorID.String
+	rep.Snapshot.CreatedAt, _ = time.Parse(time.RFC3339, snapshotCreatedAt)
+	rep.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	rep.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return &rep, nil
+}
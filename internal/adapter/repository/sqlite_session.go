@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const sessionColumns = `id, user_id, device, ip_address, expires_at, revoked_at, last_used_at, created_at`
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (`+sessionColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.Device, session.IPAddress,
+		session.ExpiresAt.UTC().Format(time.RFC3339),
+		nullTime(session.RevokedAt),
+		session.LastUsedAt.UTC().Format(time.RFC3339),
+		session.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	s, err := scanSession(r.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+func (r *SessionRepository) GetActiveByUser(ctx context.Context, userID string, now time.Time) ([]domain.Session, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		 WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		 ORDER BY last_used_at DESC, created_at DESC`,
+		userID, now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Touch(ctx context.Context, id, ipAddress string, now, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET ip_address = ?, last_used_at = ?, expires_at = ? WHERE id = ?`,
+		ipAddress, now.UTC().Format(time.RFC3339), expiresAt.UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id string, now time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		now.UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM sessions WHERE expires_at <= ? OR revoked_at IS NOT NULL`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired sessions: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *SessionRepository) CreateRefreshToken(ctx context.Context, token *domain.RefreshTokenRecord) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (token_hash, session_id, used_at, created_at)
+		 VALUES (?, ?, ?, ?)`,
+		token.Hash, token.SessionID, nullTime(token.UsedAt),
+		token.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetRefreshToken(ctx context.Context, hash string) (*domain.RefreshTokenRecord, error) {
+	var t domain.RefreshTokenRecord
+	var usedAt sql.NullString
+	var createdAt string
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT token_hash, session_id, used_at, created_at FROM refresh_tokens WHERE token_hash = ?`, hash,
+	).Scan(&t.Hash, &t.SessionID, &usedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan refresh token: %w", err)
+	}
+
+	t.UsedAt = parseNullTime(usedAt)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &t, nil
+}
+
+func (r *SessionRepository) UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`,
+		now.UTC().Format(time.RFC3339), hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("use refresh token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("use refresh token: %w", err)
+	}
+	return n > 0, nil
+}
+
+func scanSession(row rowScanner) (*domain.Session, error) {
+	var s domain.Session
+	var expiresAt, lastUsedAt, createdAt string
+	var revokedAt sql.NullString
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Device, &s.IPAddress, &expiresAt, &revokedAt, &lastUsedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan session: %w", err)
+	}
+
+	s.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
+	s.RevokedAt = parseNullTime(revokedAt)
+	s.LastUsedAt, _ = time.Parse(time.RFC3339, lastUsedAt)
+	s.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &s, nil
+}
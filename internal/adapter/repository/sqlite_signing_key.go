@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+type SigningKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+func (r *SigningKeyRepository) GetSigningKeys(ctx context.Context, now time.Time) ([]domain.SigningKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, algorithm, private_key, created_at, retired_at, expires_at
+		 FROM signing_keys
+		 WHERE expires_at IS NULL OR expires_at > ?
+		 ORDER BY created_at DESC, rowid DESC`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []domain.SigningKey
+	for rows.Next() {
+		var k domain.SigningKey
+		var createdAt string
+		var retiredAt, expiresAt sql.NullString
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &createdAt, &retiredAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("scan signing key: %w", err)
+		}
+		k.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		k.RetiredAt = parseNullTime(retiredAt)
+		k.ExpiresAt = parseNullTime(expiresAt)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *SigningKeyRepository) RotateSigningKey(ctx context.Context, key *domain.SigningKey, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE signing_keys SET retired_at = ?, expires_at = ? WHERE retired_at IS NULL`,
+		key.CreatedAt.UTC().Format(time.RFC3339), expiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("retire signing key: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO signing_keys (id, algorithm, private_key, created_at) VALUES (?, ?, ?, ?)`,
+		key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create signing key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit signing key: %w", err)
+	}
+	return nil
+}
+
+func (r *SigningKeyRepository) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM signing_keys WHERE expires_at <= ?`,
+		now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired signing keys: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SlowmodeRepository struct {
+	db *sql.DB
+}
+
+func NewSlowmodeRepository(db *sql.DB) *SlowmodeRepository {
+	return &SlowmodeRepository{db: db}
+}
+
+// Take only overwrites the time of the user's last message when it is old
+// enough, so that concurrent posts cannot both get through.
+func (r *SlowmodeRepository) Take(ctx context.Context, channelID, userID string, now time.Time, interval time.Duration) (bool, time.Time, error) {
+	nowStr := now.UTC().Format(time.RFC3339)
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO slowmode_cooldowns (channel_id, user_id, posted_at) VALUES (?, ?, ?)
+		 ON CONFLICT (channel_id, user_id) DO UPDATE SET posted_at = excluded.posted_at
+		 WHERE posted_at <= ?`,
+		channelID, userID, nowStr, now.Add(-interval).UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("take slowmode: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("take slowmode: %w", err)
+	}
+	if n > 0 {
+		return true, now, nil
+	}
+
+	var postedAt string
+	err = r.db.QueryRowContext(ctx,
+		`SELECT posted_at FROM slowmode_cooldowns WHERE channel_id = ? AND user_id = ?`,
+		channelID, userID,
+	).Scan(&postedAt)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("get slowmode: %w", err)
+	}
+	last, _ := time.Parse(time.RFC3339, postedAt)
+	return false, last, nil
+}
+
+func (r *SlowmodeRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM slowmode_cooldowns WHERE posted_at < ?`,
+		before.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete slowmode cooldowns: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenVersionStore reads user token versions through an in-memory cache so
+// that checking them does not cost a query on every request. Bumps go through
+// the store and evict the cached entry, so they take effect immediately; the
+// TTL only bounds how long an entry survives a change made elsewhere.
+type TokenVersionStore struct {
+	db  *sql.DB
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]tokenVersionEntry
+	// bumps counts calls to Bump. A lookup that raced with a bump may have
+	// read the old version and must not cache it.
+	bumps uint64
+}
+
+type tokenVersionEntry struct {
+	version   int
+	exists    bool
+	expiresAt time.Time
+}
+
+func NewTokenVersionStore(db *sql.DB, ttl time.Duration) *TokenVersionStore {
+	return &TokenVersionStore{db: db, ttl: ttl, entries: make(map[string]tokenVersionEntry)}
+}
+
+func (s *TokenVersionStore) Get(ctx context.Context, userID string) (int, bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.entries[userID]
+	bumps := s.bumps
+	s.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.version, entry.exists, nil
+	}
+
+	entry = tokenVersionEntry{exists: true, expiresAt: now.Add(s.ttl)}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT token_version FROM users WHERE id = ?`, userID,
+	).Scan(&entry.version)
+	if err == sql.ErrNoRows {
+		entry.exists = false
+	} else if err != nil {
+		return 0, false, fmt.Errorf("get token version: %w", err)
+	}
+
+	s.mu.Lock()
+	if s.bumps == bumps {
+		s.entries[userID] = entry
+	}
+	s.mu.Unlock()
+	return entry.version, entry.exists, nil
+}
+
+func (s *TokenVersionStore) Bump(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE users SET token_version = token_version + 1 WHERE id = ?`, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("bump token version: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.entries, userID)
+	s.bumps++
+	s.mu.Unlock()
+	return nil
+}
+
+// Prune evicts expired entries so that users who stop making requests do not
+// stay in memory forever.
+func (s *TokenVersionStore) Prune() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, userID)
+		}
+	}
+}
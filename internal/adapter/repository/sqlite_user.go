@@ -9,6 +9,8 @@ import (
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
+const userColumns = `id, username, email, password, is_admin, status, dm_policy, token_version, email_verified, pending_email, is_bot, created_at, updated_at`
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -18,12 +20,33 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO users (id, username, email, password, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		user.ID, user.Username, user.Email, user.Password,
-		user.CreatedAt.UTC().Format(time.RFC3339),
-		user.UpdatedAt.UTC().Format(time.RFC3339),
+	return createUser(ctx, r.db, user)
+}
+
+// CreateFirst checks that there are no users and inserts in one statement, so
+// that parallel callers cannot all find the table empty.
+func (r *UserRepository) CreateFirst(ctx context.Context, user *domain.User) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`)
+		 SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		 WHERE NOT EXISTS (SELECT 1 FROM users)`,
+		userValues(user)...,
+	)
+	if err != nil {
+		return false, fmt.Errorf("create first user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("create first user: %w", err)
+	}
+	return n > 0, nil
+}
+
+func createUser(ctx context.Context, db execer, user *domain.User) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userValues(user)...,
 	)
 	if err != nil {
 		return fmt.Errorf("create user: %w", err)
@@ -31,49 +54,51 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+// userValues returns the values of userColumns for the user.
+func userValues(user *domain.User) []any {
+	return []any{
+		user.ID, user.Username, user.Email, user.Password, user.IsAdmin, user.Status, user.DMPolicy, user.TokenVersion,
+		user.EmailVerified, nullString(user.PendingEmail), user.IsBot,
+		user.CreatedAt.UTC().Format(time.RFC3339),
+		user.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
 func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
 	return r.scanUser(r.db.QueryRowContext(ctx,
-		`SELECT id, username, email, password, created_at, updated_at FROM users WHERE id = ?`, id,
+		`SELECT `+userColumns+` FROM users WHERE id = ?`, id,
 	))
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	return r.scanUser(r.db.QueryRowContext(ctx,
-		`SELECT id, username, email, password, created_at, updated_at FROM users WHERE email = ?`, email,
+		`SELECT `+userColumns+` FROM users WHERE email = ?`, email,
 	))
 }
 
 func (r *UserRepository) GetAll(ctx context.Context) ([]domain.User, error) {
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, username, email, password, created_at, updated_at FROM users`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("get all users: %w", err)
-	}
-	defer rows.Close()
+	return r.queryUsers(ctx, `SELECT `+userColumns+` FROM users`)
+}
 
-	var users []domain.User
-	for rows.Next() {
-		var u domain.User
-		var createdAt, updatedAt string
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &createdAt, &updatedAt); err != nil {
-			return nil, fmt.Errorf("scan user: %w", err)
-		}
-		u.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		u.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-		users = append(users, u)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate users: %w", err)
+func (r *UserRepository) GetByStatus(ctx context.Context, status domain.UserStatus) ([]domain.User, error) {
+	return r.queryUsers(ctx, `SELECT `+userColumns+` FROM users WHERE status = ? ORDER BY created_at`, status)
+}
+
+func (r *UserRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
 	}
-	return users, nil
+	return n, nil
 }
 
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	user.UpdatedAt = time.Now().UTC()
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE users SET username = ?, email = ?, updated_at = ? WHERE id = ?`,
-		user.Username, user.Email, user.UpdatedAt.Format(time.RFC3339), user.ID,
+		`UPDATE users SET username = ?, email = ?, is_admin = ?, status = ?, dm_policy = ?,
+		 email_verified = ?, pending_email = ?, updated_at = ? WHERE id = ?`,
+		user.Username, user.Email, user.IsAdmin, user.Status, user.DMPolicy,
+		user.EmailVerified, nullString(user.PendingEmail), user.UpdatedAt.Format(time.RFC3339), user.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("update user: %w", err)
@@ -81,19 +106,68 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, hash string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password = ?, updated_at = ? WHERE id = ?`,
+		hash, time.Now().UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes the user along with the bots they own, whose accounts would
+// otherwise be left without an owner or a token.
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id IN (SELECT user_id FROM bots WHERE owner_id = ?)`, id)
 	if err != nil {
+		return fmt.Errorf("delete bots: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id); err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user deletion: %w", err)
+	}
 	return nil
 }
 
-func (r *UserRepository) scanUser(row *sql.Row) (*domain.User, error) {
+func (r *UserRepository) queryUsers(ctx context.Context, query string, args ...any) ([]domain.User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		u, err := r.scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate users: %w", err)
+	}
+	return users, nil
+}
+
+func (r *UserRepository) scanUser(row rowScanner) (*domain.User, error) {
 	var u domain.User
+	var pendingEmail sql.NullString
 	var createdAt, updatedAt string
 
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &createdAt, &updatedAt)
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.IsAdmin, &u.Status, &u.DMPolicy, &u.TokenVersion,
+		&u.EmailVerified, &pendingEmail, &u.IsBot, &createdAt, &updatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -101,6 +175,7 @@ func (r *UserRepository) scanUser(row *sql.Row) (*domain.User, error) {
 		return nil, fmt.Errorf("scan user: %w", err)
 	}
 
+	u.PendingEmail = pendingEmail.String
 	u.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 	u.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 	return &u, nil
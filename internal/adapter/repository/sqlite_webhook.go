@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const webhookColumns = `id, channel_id, name, avatar_url, token_hash, creator_id, created_at`
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, w *domain.Webhook) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		w.ID, w.ChannelID, w.Name, nullString(w.AvatarURL), w.TokenHash, nullString(w.CreatorID),
+		w.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("create webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id string) (*domain.Webhook, error) {
+	w, err := scanWebhook(r.db.QueryRowContext(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (r *WebhookRepository) GetByChannel(ctx context.Context, channelID string) ([]domain.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE channel_id = ? ORDER BY created_at`, channelID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []domain.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) UpdateToken(ctx context.Context, id, hash string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE webhooks SET token_hash = ? WHERE id = ?`, hash, id)
+	if err != nil {
+		return fmt.Errorf("update webhook token: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+func scanWebhook(row rowScanner) (*domain.Webhook, error) {
+	var w domain.Webhook
+	var avatarURL, creatorID sql.NullString
+	var createdAt string
+
+	err := row.Scan(&w.ID, &w.ChannelID, &w.Name, &avatarURL, &w.TokenHash, &creatorID, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan webhook: %w", err)
+	}
+
+	w.AvatarURL = avatarURL.String
+	w.CreatorID = creatorID.String
+	w.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &w, nil
+}
@@ -5,36 +5,45 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID string           `json:"userId"`
-	Type   domain.TokenType `json:"type"`
+	UserID    string           `json:"userId"`
+	SessionID string           `json:"sid"`
+	Version   int              `json:"ver"`
+	Type      domain.TokenType `json:"type"`
 }
 
+// JWTService signs tokens with the active key of its keyring, and names the
+// key in their kid header so that they can be verified after it is retired.
 type JWTService struct {
-	secret     []byte
-	accessTTL  time.Duration
-	refreshTTL time.Duration
+	keys *Keyring
+	// legacySecret verifies the HS256 access tokens issued before signing
+	// keys, so that upgrading does not log everyone out. It may be empty.
+	legacySecret []byte
+	accessTTL    time.Duration
+	refreshTTL   time.Duration
 }
 
-func NewJwtService(secret string, accessTTL, refreshTTL time.Duration) *JWTService {
+func NewJwtService(keys *Keyring, legacySecret string, accessTTL, refreshTTL time.Duration) *JWTService {
 	return &JWTService{
-		secret:     []byte(secret),
-		accessTTL:  accessTTL,
-		refreshTTL: refreshTTL,
+		keys:         keys,
+		legacySecret: []byte(legacySecret),
+		accessTTL:    accessTTL,
+		refreshTTL:   refreshTTL,
 	}
 }
 
-func (s *JWTService) GenerateTokenPair(userID string) (domain.TokenPair, error) {
-	access, err := s.generateToken(userID, domain.AccessToken, s.accessTTL)
+func (s *JWTService) GenerateTokenPair(userID, sessionID string, version int) (domain.TokenPair, error) {
+	access, err := s.generateToken(userID, sessionID, version, domain.AccessToken, s.accessTTL)
 	if err != nil {
 		return domain.TokenPair{}, fmt.Errorf("generate access token: %w", err)
 	}
 
-	refresh, err := s.generateToken(userID, domain.RefreshToken, s.refreshTTL)
+	refresh, err := s.generateToken(userID, sessionID, version, domain.RefreshToken, s.refreshTTL)
 	if err != nil {
 		return domain.TokenPair{}, fmt.Errorf("generate refresh token: %w", err)
 	}
@@ -43,12 +52,9 @@ func (s *JWTService) GenerateTokenPair(userID string) (domain.TokenPair, error)
 }
 
 func (s *JWTService) ValidateToken(tokenString string) (*domain.AuthClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return s.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey,
+		jwt.WithValidMethods([]string{AlgorithmEdDSA, AlgorithmES256, jwt.SigningMethodHS256.Alg()}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("parse token: %w", err)
 	}
@@ -59,22 +65,73 @@ func (s *JWTService) ValidateToken(tokenString string) (*domain.AuthClaims, erro
 	}
 
 	return &domain.AuthClaims{
-		UserID: claims.UserID,
-		Type:   claims.Type,
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		Version:   claims.Version,
+		Type:      claims.Type,
 	}, nil
 }
 
-func (s *JWTService) generateToken(userID string, tokenType domain.TokenType, ttl time.Duration) (string, error) {
+func (s *JWTService) generateToken(userID, sessionID string, version int, tokenType domain.TokenType, ttl time.Duration) (string, error) {
 	now := time.Now().UTC()
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A unique ID keeps two tokens issued within the same second
+			// distinct, which refresh token rotation relies on.
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
-		UserID: userID,
-		Type:   tokenType,
+		UserID:    userID,
+		SessionID: sessionID,
+		Version:   version,
+		Type:      tokenType,
+	}
+
+	key := s.keys.signingKey()
+	if key == nil {
+		return "", fmt.Errorf("no signing key loaded")
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// verificationKey returns the key the token claims to be signed with, making
+// sure that it is one of ours and that the algorithm is the key's.
+func (s *JWTService) verificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok || len(s.legacySecret) == 0 {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		if err := s.checkLegacy(t); err != nil {
+			return nil, err
+		}
+		return s.legacySecret, nil
+	}
+
+	key := s.keys.verificationKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
 	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+// checkLegacy only lets through legacy tokens that were issued before the
+// keyring was created and expire within an access token lifetime of it, so
+// that whoever holds the old secret cannot mint new ones.
+func (s *JWTService) checkLegacy(t *jwt.Token) error {
+	claims, ok := t.Claims.(*Claims)
+	if !ok || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return fmt.Errorf("legacy token without issue or expiry time")
+	}
+	upgradedAt := s.keys.CreatedAt()
+	if claims.IssuedAt.After(upgradedAt) || claims.ExpiresAt.After(upgradedAt.Add(s.accessTTL)) {
+		return fmt.Errorf("legacy token issued after signing keys were introduced")
+	}
+	return nil
 }
@@ -0,0 +1,91 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// memorySigningKeys is a domain.SigningKeyRepository kept in memory.
+type memorySigningKeys struct {
+	keys []domain.SigningKey
+}
+
+func (m *memorySigningKeys) GetSigningKeys(context.Context, time.Time) ([]domain.SigningKey, error) {
+	return m.keys, nil
+}
+
+func (m *memorySigningKeys) RotateSigningKey(_ context.Context, key *domain.SigningKey, expiresAt time.Time) error {
+	for i := range m.keys {
+		if m.keys[i].RetiredAt == nil {
+			m.keys[i].RetiredAt, m.keys[i].ExpiresAt = &key.CreatedAt, &expiresAt
+		}
+	}
+	m.keys = append([]domain.SigningKey{*key}, m.keys...)
+	return nil
+}
+
+func (m *memorySigningKeys) DeleteExpiredSigningKeys(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestLegacyTokensOnlyWorkDuringUpgrade(t *testing.T) {
+	const secret = "legacy secret"
+	accessTTL := 15 * time.Minute
+
+	keyring := NewKeyring(&memorySigningKeys{}, AlgorithmEdDSA, time.Hour)
+	if err := keyring.Load(context.Background()); err != nil {
+		t.Fatalf("load keyring: %v", err)
+	}
+	svc := NewJwtService(keyring, secret, accessTTL, time.Hour)
+	upgradedAt := keyring.CreatedAt()
+
+	sign := func(issuedAt, expiresAt time.Time) string {
+		t.Helper()
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(issuedAt),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			UserID: "user",
+			Type:   domain.AccessToken,
+		})
+		signed, err := token.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("sign legacy token: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name      string
+		issuedAt  time.Time
+		expiresAt time.Time
+		valid     bool
+	}{
+		{"issued before the upgrade", upgradedAt.Add(-time.Minute), upgradedAt.Add(accessTTL - time.Minute), true},
+		{"issued after the upgrade", upgradedAt.Add(2 * time.Second), upgradedAt.Add(accessTTL), false},
+		{"backdated with a long expiry", upgradedAt.Add(-time.Minute), upgradedAt.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ValidateToken(sign(tt.issuedAt, tt.expiresAt))
+			if (err == nil) != tt.valid {
+				t.Errorf("validate = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+
+	// Tokens from the keyring are unaffected.
+	pair, err := svc.GenerateTokenPair("user", "session", 0)
+	if err != nil {
+		t.Fatalf("generate tokens: %v", err)
+	}
+	if _, err := svc.ValidateToken(pair.AccessToken); err != nil {
+		t.Errorf("validate signed token: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmES256 = "ES256"
+)
+
+// minReloadInterval bounds how often tokens signed with an unknown key make
+// the keyring reload its keys, in case another instance rotated them.
+const minReloadInterval = 10 * time.Second
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+// Keyring holds the keys tokens are signed with. Keys are stored in a
+// repository, so that every instance signs with the same active key and
+// verifies tokens signed by the others.
+type Keyring struct {
+	repo      domain.SigningKeyRepository
+	algorithm string
+	retention time.Duration
+
+	mu     sync.RWMutex
+	active *signingKey
+	// keys holds the keys by ID, and ordered newest first.
+	keys     map[string]*signingKey
+	ordered  []*signingKey
+	loadedAt time.Time
+}
+
+// NewKeyring creates a keyring that generates keys for algorithm and keeps
+// retired keys for retention, which must be at least the lifetime of the
+// longest-lived token.
+func NewKeyring(repo domain.SigningKeyRepository, algorithm string, retention time.Duration) *Keyring {
+	return &Keyring{repo: repo, algorithm: algorithm, retention: retention}
+}
+
+// Load reads the keys from the repository, generating the first one when there
+// is none. It is called on startup and periodically to pick up rotations made
+// by other instances.
+func (k *Keyring) Load(ctx context.Context) error {
+	stored, err := k.repo.GetSigningKeys(ctx, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("get signing keys: %w", err)
+	}
+	if len(stored) == 0 || stored[0].RetiredAt != nil {
+		_, err := k.Rotate(ctx)
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(stored))
+	ordered := make([]*signingKey, len(stored))
+	for i, s := range stored {
+		key, err := parseSigningKey(s)
+		if err != nil {
+			return err
+		}
+		keys[key.id] = key
+		ordered[i] = key
+	}
+
+	k.mu.Lock()
+	k.active = ordered[0]
+	k.keys = keys
+	k.ordered = ordered
+	k.loadedAt = time.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+// Rotate generates a new key and makes it the active one, and returns its ID.
+// The previous key keeps verifying the tokens it signed until they expire.
+func (k *Keyring) Rotate(ctx context.Context) (string, error) {
+	private, err := generatePrivateKey(k.algorithm)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("marshal signing key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	key := &domain.SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  k.algorithm,
+		PrivateKey: der,
+		CreatedAt:  now,
+	}
+	if err := k.repo.RotateSigningKey(ctx, key, now.Add(k.retention)); err != nil {
+		return "", fmt.Errorf("rotate signing key: %w", err)
+	}
+	if err := k.Load(ctx); err != nil {
+		return "", err
+	}
+	return key.ID, nil
+}
+
+// PruneExpired deletes the retired keys whose tokens have all expired.
+func (k *Keyring) PruneExpired(ctx context.Context) (int64, error) {
+	n, err := k.repo.DeleteExpiredSigningKeys(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired signing keys: %w", err)
+	}
+	return n, nil
+}
+
+func (k *Keyring) PublicKeys() []domain.PublicKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]domain.PublicKey, len(k.ordered))
+	for i, key := range k.ordered {
+		keys[i] = domain.PublicKey{
+			ID:        key.id,
+			Algorithm: key.method.Alg(),
+			Key:       key.private.Public(),
+		}
+	}
+	return keys
+}
+
+// CreatedAt returns when the oldest key still held was created, which is when
+// the keyring was first created until that key expires. Keys only expire a
+// full retention after being retired, so the time it returns never gets
+// closer to the present than the retention.
+func (k *Keyring) CreatedAt() time.Time {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.ordered) == 0 {
+		return time.Time{}
+	}
+	return k.ordered[len(k.ordered)-1].createdAt
+}
+
+func (k *Keyring) signingKey() *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// verificationKey returns the key with the given ID, reloading the keys when
+// it is unknown, or nil when there is no such key.
+func (k *Keyring) verificationKey(id string) *signingKey {
+	k.mu.RLock()
+	key, loadedAt := k.keys[id], k.loadedAt
+	k.mu.RUnlock()
+	if key != nil || time.Since(loadedAt) < minReloadInterval {
+		return key
+	}
+
+	if err := k.Load(context.Background()); err != nil {
+		return nil
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[id]
+}
+
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ed25519 key: %w", err)
+		}
+		return private, nil
+	case AlgorithmES256:
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ecdsa key: %w", err)
+		}
+		return private, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+}
+
+func parseSigningKey(s domain.SigningKey) (*signingKey, error) {
+	private, err := x509.ParsePKCS8PrivateKey(s.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key %s: %w", s.ID, err)
+	}
+
+	key := &signingKey{id: s.ID, createdAt: s.CreatedAt}
+	switch p := private.(type) {
+	case ed25519.PrivateKey:
+		key.method, key.private = jwt.SigningMethodEdDSA, p
+	case *ecdsa.PrivateKey:
+		key.method, key.private = jwt.SigningMethodES256, p
+	default:
+		return nil, fmt.Errorf("signing key %s has unsupported type %T", s.ID, private)
+	}
+	if key.method.Alg() != s.Algorithm {
+		return nil, fmt.Errorf("signing key %s is not an %s key", s.ID, s.Algorithm)
+	}
+	return key, nil
+}
@@ -0,0 +1,97 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const (
+	defaultAuditLogLimit = 50
+	maxAuditLogLimit     = 100
+)
+
+type AuditService struct {
+	repo      domain.AuditLogRepository
+	retention time.Duration
+}
+
+// NewAuditService creates an audit service that keeps entries for the given
+// retention period. A zero retention keeps entries forever.
+func NewAuditService(repo domain.AuditLogRepository, retention time.Duration) *AuditService {
+	return &AuditService{repo: repo, retention: retention}
+}
+
+// Record stores an audit log entry attributed to the actor found in ctx.
+func (s *AuditService) Record(ctx context.Context, action domain.AuditAction, targetType domain.AuditTargetType, targetID string, changes []domain.AuditChange) error {
+	actor, _ := domain.ActorFromContext(ctx)
+	if changes == nil {
+		changes = []domain.AuditChange{}
+	}
+
+	entry := &domain.AuditLogEntry{
+		ID:         uuid.New().String(),
+		ActorID:    actor.UserID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		Reason:     actor.Reason,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("record audit log: %w", err)
+	}
+	return nil
+}
+
+func (s *AuditService) Find(ctx context.Context, filter domain.AuditLogFilter) ([]domain.AuditLogEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLogLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLogLimit)
+
+	entries, err := s.repo.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("find audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// PruneExpired deletes entries older than the retention period.
+func (s *AuditService) PruneExpired(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	n, err := s.repo.DeleteOlderThan(ctx, time.Now().UTC().Add(-s.retention))
+	if err != nil {
+		return 0, fmt.Errorf("prune audit log: %w", err)
+	}
+	return n, nil
+}
+
+// auditDiff collects the fields changed by an audited action.
+type auditDiff []domain.AuditChange
+
+// add records the change unless the values are equal. They are compared
+// deeply, so slices and structs can be recorded as well.
+func (d *auditDiff) add(key string, old, new any) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	*d = append(*d, domain.AuditChange{Key: key, Old: old, New: new})
+}
+
+// formatAuditTime renders an optional timestamp for an audit change, keeping
+// unset values as nil.
+func formatAuditTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,6 +2,11 @@ package application
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -16,87 +21,507 @@ var (
 	ErrEmailTaken         = errors.New("email already taken")
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrInvalidToken       = errors.New("invalid or expired refresh token")
+	ErrRegistrationClosed = errors.New("registration is closed")
+	ErrInviteRequired     = errors.New("an invite is required to register")
+	ErrAccountPending     = errors.New("account is awaiting approval")
+	ErrAccountDisabled    = errors.New("account is disabled")
+)
+
+// LoginResult is the outcome of a password login. Tokens is set when the login
+// is complete; otherwise the user has two-factor authentication enabled and
+// must present one of MFAMethods along with MFAToken before MFAExpiresAt.
+type LoginResult struct {
+	Tokens       *domain.TokenPair
+	MFAToken     string
+	MFAMethods   []string
+	MFAExpiresAt time.Time
+}
+
+// Second factors accepted to complete a login.
+const (
+	MFAMethodTOTP    = "totp"
+	MFAMethodPasskey = "passkey"
 )
 
 type AuthService struct {
 	repo          domain.UserRepository
+	verifiers     []domain.CredentialVerifier
+	members       domain.MemberRepository
+	sessions      domain.SessionRepository
+	sessionSvc    *SessionService
+	emails        *EmailService
+	mfa           *MFAService
+	passkeys      *PasskeyService
+	lockout       *LockoutService
+	invites       *InviteService
+	audit         *AuditService
 	tokenProvider domain.TokenProvider
+	mode          domain.RegistrationMode
+	sessionTTL    time.Duration
 }
 
-func NewAuthService(repo domain.UserRepository, jwtSvc domain.TokenProvider) *AuthService {
-	return &AuthService{repo: repo, tokenProvider: jwtSvc}
+func NewAuthService(repo domain.UserRepository, verifiers []domain.CredentialVerifier, members domain.MemberRepository, sessions domain.SessionRepository, sessionSvc *SessionService, emails *EmailService, mfa *MFAService, passkeys *PasskeyService, lockout *LockoutService, invites *InviteService, audit *AuditService, jwtSvc domain.TokenProvider, mode domain.RegistrationMode, sessionTTL time.Duration) *AuthService {
+	return &AuthService{
+		repo:          repo,
+		verifiers:     verifiers,
+		members:       members,
+		sessions:      sessions,
+		sessionSvc:    sessionSvc,
+		emails:        emails,
+		mfa:           mfa,
+		passkeys:      passkeys,
+		lockout:       lockout,
+		invites:       invites,
+		audit:         audit,
+		tokenProvider: jwtSvc,
+		mode:          mode,
+		sessionTTL:    sessionTTL,
+	}
 }
 
-func (s *AuthService) Register(ctx context.Context, name, email, password string) (*domain.User, error) {
-	existing, err := s.repo.GetByEmail(ctx, email)
+// Register creates a new account according to the registration mode. The
+// very first account is always accepted and made an administrator so that a
+// fresh instance can be bootstrapped regardless of the mode. When inviteCode
+// is set the invite is redeemed for the new user, otherwise they join the
+// community directly. A link to verify their email is sent in either case;
+// when that fails the user is returned along with ErrVerificationNotSent.
+func (s *AuthService) Register(ctx context.Context, name, email, password, inviteCode string) (*domain.User, error) {
+	user, err := createFirstUser(ctx, s.repo, name, email, password)
 	if err != nil {
-		return nil, fmt.Errorf("check email: %w", err)
+		return nil, err
 	}
-	if existing != nil {
-		return nil, ErrEmailTaken
+	if user == nil {
+		status := domain.UserStatusActive
+		switch s.mode {
+		case domain.RegistrationClosed:
+			return nil, ErrRegistrationClosed
+		case domain.RegistrationInviteOnly:
+			if inviteCode == "" {
+				return nil, ErrInviteRequired
+			}
+		case domain.RegistrationApproval:
+			status = domain.UserStatusPending
+		}
+
+		if inviteCode != "" {
+			if _, _, err := s.invites.Preview(ctx, inviteCode); err != nil {
+				return nil, err
+			}
+		}
+
+		user, err = createUser(ctx, s.repo, name, email, password, false, status)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
-	if err != nil {
-		return nil, fmt.Errorf("hash password: %w", err)
+	if inviteCode != "" {
+		if _, err := s.invites.Accept(ctx, inviteCode, user.ID); err != nil {
+			// The invite was used up between the check and the redemption.
+			if delErr := s.repo.Delete(ctx, user.ID); delErr != nil {
+				return nil, fmt.Errorf("rollback user: %w", delErr)
+			}
+			return nil, err
+		}
+	} else if err := addMember(ctx, s.members, user); err != nil {
+		return nil, err
 	}
 
-	now := time.Now().UTC()
-	user := &domain.User{
-		ID:        uuid.New().String(),
-		Username:  name,
-		Email:     email,
-		Password:  string(hash),
-		CreatedAt: now,
-		UpdatedAt: now,
+	if err := s.emails.SendVerification(ctx, user); err != nil {
+		return user, fmt.Errorf("%w: %w", ErrVerificationNotSent, err)
 	}
+	return user, nil
+}
 
-	if err := s.repo.Create(ctx, user); err != nil {
-		return nil, fmt.Errorf("create user: %w", err)
+// CreateAccount creates an active account on behalf of an administrator,
+// bypassing the registration mode. Like Register, it returns the user along
+// with ErrVerificationNotSent when the verification link could not be sent.
+func (s *AuthService) CreateAccount(ctx context.Context, name, email, password string, isAdmin bool) (*domain.User, error) {
+	user, err := createUser(ctx, s.repo, name, email, password, isAdmin, domain.UserStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := addMember(ctx, s.members, user); err != nil {
+		return nil, err
+	}
+
+	var diff auditDiff
+	diff.add("username", nil, user.Username)
+	diff.add("email", nil, user.Email)
+	diff.add("isAdmin", nil, user.IsAdmin)
+	if err := s.audit.Record(ctx, domain.AuditUserCreate, domain.AuditTargetUser, user.ID, diff); err != nil {
+		return nil, err
 	}
 
+	if err := s.emails.SendVerification(ctx, user); err != nil {
+		return user, fmt.Errorf("%w: %w", ErrVerificationNotSent, err)
+	}
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, email, password string) (*domain.TokenPair, error) {
-	user, err := s.repo.GetByEmail(ctx, email)
+// Login checks the user's credentials with each verifier in turn and opens a
+// new session for the client with the first that accepts them. Users with
+// two-factor authentication get a challenge to complete through LoginMFA
+// instead. Repeated failures for the account or from the client's address
+// make further attempts fail with a TooManyAttemptsError for a while.
+func (s *AuthService) Login(ctx context.Context, login, password string, client domain.ClientInfo) (*LoginResult, error) {
+	// The account is only looked up to track failed attempts against it.
+	account, err := s.repo.GetByEmail(ctx, login)
 	if err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
 	}
-	if user == nil {
-		return nil, ErrInvalidCredentials
+	if err := s.lockout.reserve(ctx, login, account, client.IPAddress); err != nil {
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, ErrInvalidCredentials
+	for _, verifier := range s.verifiers {
+		user, err := verifier.VerifyCredentials(ctx, login, password)
+		if err != nil {
+			// The credentials were not checked, so the attempt is not a
+			// failure.
+			if err := s.lockout.release(ctx, login, account, client.IPAddress); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("verify credentials: %w", err)
+		}
+		if user != nil {
+			if err := s.lockout.recordSuccess(ctx, login, account, client.IPAddress); err != nil {
+				return nil, err
+			}
+			return s.completeLogin(ctx, user, client)
+		}
 	}
 
-	pair, err := s.tokenProvider.GenerateTokenPair(user.ID)
+	if err := s.lockout.recordFailure(ctx, login, account, client.IPAddress); err != nil {
+		return nil, err
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// completeLogin opens a session for a user who proved their identity, or
+// starts a two-factor challenge when they have a second factor.
+func (s *AuthService) completeLogin(ctx context.Context, user *domain.User, client domain.ClientInfo) (*LoginResult, error) {
+	if err := checkCanLogin(user); err != nil {
+		return nil, err
+	}
+
+	totp, passkeys, err := s.mfa.factors(ctx, user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("generate tokens: %w", err)
+		return nil, err
+	}
+	if totp || passkeys > 0 {
+		token, expiresAt, err := s.mfa.beginChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		result := &LoginResult{MFAToken: token, MFAExpiresAt: expiresAt}
+		if totp {
+			result.MFAMethods = append(result.MFAMethods, MFAMethodTOTP)
+		}
+		if passkeys > 0 {
+			result.MFAMethods = append(result.MFAMethods, MFAMethodPasskey)
+		}
+		return result, nil
 	}
 
-	return &pair, nil
+	pair, err := s.openSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair}, nil
+}
+
+// BeginLoginMFAPasskey starts a passkey assertion to complete a login started
+// by Login, returning the options to pass to navigator.credentials.get.
+func (s *AuthService) BeginLoginMFAPasskey(ctx context.Context, mfaToken string) (json.RawMessage, error) {
+	return s.mfa.beginPasskeyChallenge(ctx, mfaToken)
+}
+
+// LoginMFA completes a login started by Login with a code from the user's
+// authenticator app, one of their recovery codes, or a passkey assertion
+// requested through BeginLoginMFAPasskey.
+func (s *AuthService) LoginMFA(ctx context.Context, mfaToken, code string, passkey []byte, client domain.ClientInfo) (*domain.TokenPair, error) {
+	userID, err := s.mfa.completeChallenge(ctx, mfaToken, code, passkey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account may have been disabled while the challenge was pending.
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if err := checkCanLogin(user); err != nil {
+		return nil, err
+	}
+	return s.openSession(ctx, user, client)
+}
+
+// LoginPasskey logs in without a password using the assertion for a ceremony
+// started by PasskeyService.BeginLogin. The passkey verified the user, so no
+// second factor is asked for.
+func (s *AuthService) LoginPasskey(ctx context.Context, token string, response []byte, client domain.ClientInfo) (*domain.TokenPair, error) {
+	user, err := s.passkeys.finishLogin(ctx, token, response)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCanLogin(user); err != nil {
+		return nil, err
+	}
+	return s.openSession(ctx, user, client)
 }
 
-func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// is single use: presenting one that was already rotated means it leaked, so
+// the whole session is revoked and every token issued for it stops working.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, client domain.ClientInfo) (*domain.TokenPair, error) {
 	claims, err := s.tokenProvider.ValidateToken(refreshToken)
+	if err != nil || claims.Type != domain.RefreshToken || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	hash := hashToken(refreshToken)
+	stored, err := s.sessions.GetRefreshToken(ctx, hash)
 	if err != nil {
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+	if stored == nil || stored.SessionID != claims.SessionID {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.sessions.GetByID(ctx, stored.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	now := time.Now().UTC()
+	if session == nil || session.UserID != claims.UserID || !session.Active(now) {
 		return nil, ErrInvalidToken
 	}
 
+	fresh, err := s.sessions.UseRefreshToken(ctx, hash, now)
+	if err != nil {
+		return nil, fmt.Errorf("use refresh token: %w", err)
+	}
+	if !fresh {
+		if err := s.sessionSvc.revoke(ctx, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: refresh token reused, session %s revoked", ErrInvalidToken, session.ID)
+	}
+
 	user, err := s.repo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
 	}
-	if user == nil {
+	if user == nil || user.Status != domain.UserStatusActive || claims.Version != user.TokenVersion {
 		return nil, ErrInvalidToken
 	}
 
-	pair, err := s.tokenProvider.GenerateTokenPair(user.ID)
+	if err := s.sessions.Touch(ctx, session.ID, client.IPAddress, now, now.Add(s.sessionTTL)); err != nil {
+		return nil, fmt.Errorf("touch session: %w", err)
+	}
+	return s.issueTokens(ctx, session, user.TokenVersion, now)
+}
+
+// Logout revokes the session, invalidating its refresh tokens.
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return s.sessionSvc.revoke(ctx, sessionID)
+}
+
+// PruneExpiredSessions deletes sessions that expired or were revoked, along
+// with their refresh tokens.
+func (s *AuthService) PruneExpiredSessions(ctx context.Context) (int64, error) {
+	n, err := s.sessions.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired sessions: %w", err)
+	}
+	return n, nil
+}
+
+// openSession starts a new session for the user on the client and issues its
+// first token pair.
+func (s *AuthService) openSession(ctx context.Context, user *domain.User, client domain.ClientInfo) (*domain.TokenPair, error) {
+	now := time.Now().UTC()
+	session := &domain.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		Device:     client.Device,
+		IPAddress:  client.IPAddress,
+		ExpiresAt:  now.Add(s.sessionTTL),
+		LastUsedAt: now,
+		CreatedAt:  now,
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	return s.issueTokens(ctx, session, user.TokenVersion, now)
+}
+
+// issueTokens generates a token pair for the session and records the hash of
+// its refresh token so that it can be rotated later.
+func (s *AuthService) issueTokens(ctx context.Context, session *domain.Session, version int, now time.Time) (*domain.TokenPair, error) {
+	pair, err := s.tokenProvider.GenerateTokenPair(session.UserID, session.ID, version)
 	if err != nil {
 		return nil, fmt.Errorf("generate tokens: %w", err)
 	}
 
+	token := &domain.RefreshTokenRecord{
+		Hash:      hashToken(pair.RefreshToken),
+		SessionID: session.ID,
+		CreatedAt: now,
+	}
+	if err := s.sessions.CreateRefreshToken(ctx, token); err != nil {
+		return nil, fmt.Errorf("create refresh token: %w", err)
+	}
 	return &pair, nil
 }
+
+func createUser(ctx context.Context, users domain.UserRepository, name, email, password string, isAdmin bool, status domain.UserStatus) (*domain.User, error) {
+	user, err := newUser(ctx, users, name, email, password, isAdmin, status)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	return user, nil
+}
+
+// createFirstUser creates the instance's first account, an active
+// administrator, or returns nil when there is another account. Counting
+// first spares hashing the password on every registration; the insert checks
+// again, so that only one of parallel first registrations gets through.
+func createFirstUser(ctx context.Context, users domain.UserRepository, name, email, password string) (*domain.User, error) {
+	count, err := users.Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("count users: %w", err)
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	user, err := newUser(ctx, users, name, email, password, true, domain.UserStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	created, err := users.CreateFirst(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	if !created {
+		return nil, nil
+	}
+	return user, nil
+}
+
+func newUser(ctx context.Context, users domain.UserRepository, name, email, password string, isAdmin bool, status domain.UserStatus) (*domain.User, error) {
+	existing, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("check email: %w", err)
+	}
+	if existing != nil {
+		return nil, ErrEmailTaken
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &domain.User{
+		ID:        uuid.New().String(),
+		Username:  name,
+		Email:     email,
+		Password:  hash,
+		IsAdmin:   isAdmin,
+		Status:    status,
+		DMPolicy:  domain.DMPolicyEveryone,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// provisionUser creates an account for a user an external identity source
+// vouches for, along with their email address. The registration mode only
+// applies when it asks for approval. The account gets a random password the
+// user can replace through a password reset.
+func provisionUser(ctx context.Context, users domain.UserRepository, members domain.MemberRepository, mode domain.RegistrationMode, name, email string) (*domain.User, error) {
+	password, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	user, err := createFirstUser(ctx, users, name, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		status := domain.UserStatusActive
+		if mode == domain.RegistrationApproval {
+			status = domain.UserStatusPending
+		}
+		user, err = createUser(ctx, users, name, email, password, false, status)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user.EmailVerified = true
+	if err := users.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	if err := addMember(ctx, members, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func addMember(ctx context.Context, members domain.MemberRepository, user *domain.User) error {
+	member := &domain.Member{UserID: user.ID, JoinedAt: user.CreatedAt}
+	if err := members.Create(ctx, member); err != nil {
+		return fmt.Errorf("create member: %w", err)
+	}
+	return nil
+}
+
+func checkCanLogin(user *domain.User) error {
+	switch user.Status {
+	case domain.UserStatusPending:
+		return ErrAccountPending
+	case domain.UserStatusDisabled:
+		return ErrAccountDisabled
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// generateToken returns a random URL-safe token suitable for links sent by
+// email.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 digest of a token. Tokens carry
+// enough entropy that a fast, unsalted hash is sufficient for storage.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package application_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestOnlyFirstRegistrationBecomesAdmin(t *testing.T) {
+	app := newTestApp(t, testOptions{})
+
+	// Every registration may see an instance without users.
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = app.auth.Register(context.Background(), fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@harmony.test", i), "correct horse battery", "")
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+
+	users, err := app.users.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("get users: %v", err)
+	}
+	var admins int
+	for _, user := range users {
+		if user.IsAdmin {
+			admins++
+		}
+	}
+	if len(users) != len(errs) || admins != 1 {
+		t.Errorf("%d users with %d administrators, want %d with 1", len(users), admins, len(errs))
+	}
+}
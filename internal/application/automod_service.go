@@ -0,0 +1,553 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrAutoModRuleNotFound = errors.New("automod rule not found")
+	ErrInvalidAutoModRule  = errors.New("invalid automod rule")
+	ErrAutoModBlocked      = errors.New("message blocked by auto moderation")
+)
+
+const (
+	maxAutoModPatternLength = 260
+	maxAutoModTimeout       = 28 * 24 * time.Hour
+	maxAutoModAlertLength   = 500
+	autoModFlagLimit        = 100
+)
+
+var (
+	mentionPattern    = regexp.MustCompile(`<@!?[\w-]+>|@everyone|@here`)
+	linkPattern       = regexp.MustCompile(`(?i)\bhttps?://([^\s/?#<>"']+)`)
+	inviteLinkPattern = regexp.MustCompile(`(?i)(?:discord(?:app)?\.com/invite|discord\.gg|/invites?)/[a-z0-9-]+`)
+)
+
+// AutoModError reports the rule that blocked a message. It matches
+// ErrAutoModBlocked.
+type AutoModError struct {
+	RuleID   string
+	RuleName string
+	Trigger  domain.AutoModTrigger
+}
+
+func (e *AutoModError) Error() string {
+	return fmt.Sprintf("message blocked by auto moderation rule %q", e.RuleName)
+}
+
+func (e *AutoModError) Is(target error) bool {
+	return target == ErrAutoModBlocked
+}
+
+type AutoModRuleInput struct {
+	Name           string
+	Trigger        domain.AutoModTrigger
+	Config         domain.AutoModConfig
+	Actions        []domain.AutoModAction
+	ExemptChannels []string
+	ExemptAdmins   bool
+	Enabled        bool
+}
+
+// AutoModRuleUpdate changes the fields that are set. A rule's trigger cannot
+// be changed.
+type AutoModRuleUpdate struct {
+	Name           *string
+	Config         *domain.AutoModConfig
+	Actions        []domain.AutoModAction
+	ExemptChannels []string
+	ExemptAdmins   *bool
+	Enabled        *bool
+}
+
+type compiledRule struct {
+	domain.AutoModRule
+	patterns []*regexp.Regexp
+}
+
+type AutoModService struct {
+	repo       domain.AutoModRepository
+	messages   domain.MessageRepository
+	channels   domain.ChannelRepository
+	users      domain.UserRepository
+	moderation *ModerationService
+	audit      *AuditService
+
+	mu     sync.RWMutex
+	rules  []compiledRule
+	loaded bool
+}
+
+func NewAutoModService(repo domain.AutoModRepository, messages domain.MessageRepository, channels domain.ChannelRepository, users domain.UserRepository, moderation *ModerationService, audit *AuditService) *AutoModService {
+	return &AutoModService{repo: repo, messages: messages, channels: channels, users: users, moderation: moderation, audit: audit}
+}
+
+func (s *AutoModService) CreateRule(ctx context.Context, creatorID string, input AutoModRuleInput) (*domain.AutoModRule, error) {
+	now := time.Now().UTC()
+	rule := &domain.AutoModRule{
+		ID:             uuid.New().String(),
+		Name:           input.Name,
+		Trigger:        input.Trigger,
+		Config:         input.Config,
+		Actions:        input.Actions,
+		ExemptChannels: input.ExemptChannels,
+		ExemptAdmins:   input.ExemptAdmins,
+		Enabled:        input.Enabled,
+		CreatorID:      creatorID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if rule.ExemptChannels == nil {
+		rule.ExemptChannels = []string{}
+	}
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		return nil, fmt.Errorf("create automod rule: %w", err)
+	}
+	s.invalidate()
+
+	var diff auditDiff
+	diff.add("name", nil, rule.Name)
+	diff.add("trigger", nil, string(rule.Trigger))
+	if err := s.audit.Record(ctx, domain.AuditAutoModRuleCreate, domain.AuditTargetAutoModRule, rule.ID, diff); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *AutoModService) GetRules(ctx context.Context) ([]domain.AutoModRule, error) {
+	rules, err := s.repo.GetRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get automod rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *AutoModService) UpdateRule(ctx context.Context, id string, update AutoModRuleUpdate) (*domain.AutoModRule, error) {
+	rule, err := s.repo.GetRuleByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get automod rule: %w", err)
+	}
+	if rule == nil {
+		return nil, ErrAutoModRuleNotFound
+	}
+
+	var diff auditDiff
+	if update.Name != nil {
+		diff.add("name", rule.Name, *update.Name)
+		rule.Name = *update.Name
+	}
+	if update.Config != nil {
+		diff.add("config", rule.Config, *update.Config)
+		rule.Config = *update.Config
+	}
+	if update.Actions != nil {
+		diff.add("actions", rule.Actions, update.Actions)
+		rule.Actions = update.Actions
+	}
+	if update.ExemptChannels != nil {
+		diff.add("exemptChannels", rule.ExemptChannels, update.ExemptChannels)
+		rule.ExemptChannels = update.ExemptChannels
+	}
+	if update.ExemptAdmins != nil {
+		diff.add("exemptAdmins", rule.ExemptAdmins, *update.ExemptAdmins)
+		rule.ExemptAdmins = *update.ExemptAdmins
+	}
+	if update.Enabled != nil {
+		diff.add("enabled", rule.Enabled, *update.Enabled)
+		rule.Enabled = *update.Enabled
+	}
+	rule.UpdatedAt = time.Now().UTC()
+
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRule(ctx, rule); err != nil {
+		return nil, fmt.Errorf("update automod rule: %w", err)
+	}
+	s.invalidate()
+
+	if err := s.audit.Record(ctx, domain.AuditAutoModRuleUpdate, domain.AuditTargetAutoModRule, rule.ID, diff); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *AutoModService) DeleteRule(ctx context.Context, id string) error {
+	rule, err := s.repo.GetRuleByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get automod rule: %w", err)
+	}
+	if rule == nil {
+		return ErrAutoModRuleNotFound
+	}
+	if err := s.repo.DeleteRule(ctx, id); err != nil {
+		return fmt.Errorf("delete automod rule: %w", err)
+	}
+	s.invalidate()
+
+	var diff auditDiff
+	diff.add("name", rule.Name, nil)
+	diff.add("trigger", string(rule.Trigger), nil)
+	return s.audit.Record(ctx, domain.AuditAutoModRuleDelete, domain.AuditTargetAutoModRule, rule.ID, diff)
+}
+
+func (s *AutoModService) GetFlags(ctx context.Context) ([]domain.AutoModFlag, error) {
+	flags, err := s.repo.GetFlags(ctx, autoModFlagLimit)
+	if err != nil {
+		return nil, fmt.Errorf("get automod flags: %w", err)
+	}
+	return flags, nil
+}
+
+// Evaluate runs every enabled rule against a message about to be stored and
+// carries out the actions of the rules it matches. It returns an
+// AutoModError for the first matching rule that blocks the message.
+func (s *AutoModService) Evaluate(ctx context.Context, channelID, authorID, content string) error {
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	var admin *bool
+	var blocked error
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Enabled || slices.Contains(rule.ExemptChannels, channelID) {
+			continue
+		}
+		if rule.ExemptAdmins {
+			if admin == nil {
+				isAdmin, err := s.isAdmin(ctx, authorID)
+				if err != nil {
+					return err
+				}
+				admin = &isAdmin
+			}
+			if *admin {
+				continue
+			}
+		}
+
+		matched, err := s.matches(ctx, rule, authorID, content)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if err := s.apply(ctx, rule, channelID, authorID, content); err != nil {
+			return err
+		}
+		if blocked == nil && rule.blocks() {
+			blocked = &AutoModError{RuleID: rule.ID, RuleName: rule.Name, Trigger: rule.Trigger}
+		}
+	}
+	return blocked
+}
+
+func (s *AutoModService) matches(ctx context.Context, rule *compiledRule, authorID, content string) (bool, error) {
+	switch rule.Trigger {
+	case domain.AutoModTriggerKeyword, domain.AutoModTriggerRegex:
+		for _, p := range rule.patterns {
+			if p.MatchString(content) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case domain.AutoModTriggerMentionSpam:
+		return len(mentionPattern.FindAllString(content, -1)) > rule.Config.MentionLimit, nil
+	case domain.AutoModTriggerLink:
+		return matchesLinkRule(rule.Config, content), nil
+	case domain.AutoModTriggerInviteLink:
+		return inviteLinkPattern.MatchString(content), nil
+	case domain.AutoModTriggerRepeatedMessage:
+		since := time.Now().UTC().Add(-time.Duration(rule.Config.RepeatWindow) * time.Second)
+		n, err := s.messages.CountByAuthorSince(ctx, authorID, content, since)
+		if err != nil {
+			return false, fmt.Errorf("count repeated messages: %w", err)
+		}
+		return n >= rule.Config.RepeatLimit, nil
+	}
+	return false, nil
+}
+
+func (s *AutoModService) apply(ctx context.Context, rule *compiledRule, channelID, authorID, content string) error {
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case domain.AutoModActionFlag:
+			flag := &domain.AutoModFlag{
+				ID:        uuid.New().String(),
+				RuleID:    rule.ID,
+				UserID:    authorID,
+				ChannelID: channelID,
+				Content:   content,
+				Blocked:   rule.blocks(),
+				CreatedAt: time.Now().UTC(),
+			}
+			if err := s.repo.CreateFlag(ctx, flag); err != nil {
+				return fmt.Errorf("flag message: %w", err)
+			}
+		case domain.AutoModActionTimeout:
+			reason := fmt.Sprintf("auto moderation rule %q", rule.Name)
+			duration := time.Duration(action.Duration) * time.Second
+			if err := s.moderation.TimeoutBySystem(ctx, authorID, duration, reason); err != nil {
+				return err
+			}
+		case domain.AutoModActionAlert:
+			if err := s.alert(ctx, rule, action.ChannelID, channelID, authorID, content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// alert posts a server-authored message describing the violation to the
+// rule's log channel. Alerts for log channels that no longer exist are
+// dropped.
+func (s *AutoModService) alert(ctx context.Context, rule *compiledRule, logChannelID, channelID, authorID, content string) error {
+	channel, err := s.channels.GetByID(ctx, logChannelID)
+	if err != nil {
+		return fmt.Errorf("get alert channel: %w", err)
+	}
+	if channel == nil {
+		return nil
+	}
+
+	if runes := []rune(content); len(runes) > maxAutoModAlertLength {
+		content = string(runes[:maxAutoModAlertLength]) + "…"
+	}
+	message := &domain.Message{
+		ID:        uuid.New().String(),
+		ChannelID: channel.ID,
+		Type:      domain.MessageTypeAutoModAlert,
+		Content: fmt.Sprintf("Auto moderation rule %q triggered by <@%s> in <#%s>: %s",
+			rule.Name, authorID, channelID, content),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.messages.Create(ctx, message); err != nil {
+		return fmt.Errorf("create alert message: %w", err)
+	}
+	return nil
+}
+
+func (s *AutoModService) loadRules(ctx context.Context) ([]compiledRule, error) {
+	s.mu.RLock()
+	if s.loaded {
+		rules := s.rules
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded {
+		return s.rules, nil
+	}
+
+	rules, err := s.repo.GetRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load automod rules: %w", err)
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		patterns, err := compilePatterns(&rule)
+		if err != nil {
+			return nil, fmt.Errorf("compile automod rule %s: %w", rule.ID, err)
+		}
+		compiled = append(compiled, compiledRule{AutoModRule: rule, patterns: patterns})
+	}
+
+	s.rules = compiled
+	s.loaded = true
+	return compiled, nil
+}
+
+func (s *AutoModService) invalidate() {
+	s.mu.Lock()
+	s.loaded = false
+	s.rules = nil
+	s.mu.Unlock()
+}
+
+func (s *AutoModService) validateRule(ctx context.Context, rule *domain.AutoModRule) error {
+	c := rule.Config
+	switch rule.Trigger {
+	case domain.AutoModTriggerKeyword:
+		if len(c.Keywords) == 0 {
+			return fmt.Errorf("%w: keyword rules need at least one keyword", ErrInvalidAutoModRule)
+		}
+		for _, kw := range c.Keywords {
+			if strings.TrimSpace(kw) == "" {
+				return fmt.Errorf("%w: keywords cannot be blank", ErrInvalidAutoModRule)
+			}
+		}
+	case domain.AutoModTriggerRegex:
+		if len(c.Patterns) == 0 {
+			return fmt.Errorf("%w: regex rules need at least one pattern", ErrInvalidAutoModRule)
+		}
+		for _, p := range c.Patterns {
+			if len(p) > maxAutoModPatternLength {
+				return fmt.Errorf("%w: patterns must be at most %d characters", ErrInvalidAutoModRule, maxAutoModPatternLength)
+			}
+		}
+	case domain.AutoModTriggerMentionSpam:
+		if c.MentionLimit < 1 {
+			return fmt.Errorf("%w: mention limit must be at least 1", ErrInvalidAutoModRule)
+		}
+	case domain.AutoModTriggerLink:
+		if len(c.AllowedDomains) == 0 && len(c.DeniedDomains) == 0 {
+			return fmt.Errorf("%w: link rules need allowed or denied domains", ErrInvalidAutoModRule)
+		}
+	case domain.AutoModTriggerRepeatedMessage:
+		if c.RepeatLimit < 1 || c.RepeatWindow < 1 {
+			return fmt.Errorf("%w: repeat limit and window must be at least 1", ErrInvalidAutoModRule)
+		}
+	case domain.AutoModTriggerInviteLink:
+	default:
+		return fmt.Errorf("%w: unknown trigger %q", ErrInvalidAutoModRule, rule.Trigger)
+	}
+
+	if _, err := compilePatterns(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAutoModRule, err)
+	}
+
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidAutoModRule)
+	}
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case domain.AutoModActionBlock, domain.AutoModActionFlag:
+		case domain.AutoModActionTimeout:
+			d := time.Duration(action.Duration) * time.Second
+			if d <= 0 || d > maxAutoModTimeout {
+				return fmt.Errorf("%w: timeout duration must be between 1 second and 28 days", ErrInvalidAutoModRule)
+			}
+		case domain.AutoModActionAlert:
+			channel, err := s.channels.GetByID(ctx, action.ChannelID)
+			if err != nil {
+				return fmt.Errorf("get alert channel: %w", err)
+			}
+			if channel == nil || channel.Type != domain.ChannelTypeText {
+				return fmt.Errorf("%w: alert channel must be an existing text channel", ErrInvalidAutoModRule)
+			}
+		default:
+			return fmt.Errorf("%w: unknown action %q", ErrInvalidAutoModRule, action.Type)
+		}
+	}
+	return nil
+}
+
+func (s *AutoModService) isAdmin(ctx context.Context, userID string) (bool, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get user: %w", err)
+	}
+	return user != nil && user.IsAdmin, nil
+}
+
+func (r *compiledRule) blocks() bool {
+	return slices.ContainsFunc(r.Actions, func(a domain.AutoModAction) bool {
+		return a.Type == domain.AutoModActionBlock
+	})
+}
+
+// compilePatterns builds the regular expressions used by keyword and regex
+// rules. Keywords are matched case-insensitively as whole words.
+func compilePatterns(rule *domain.AutoModRule) ([]*regexp.Regexp, error) {
+	switch rule.Trigger {
+	case domain.AutoModTriggerKeyword:
+		quoted := make([]string, len(rule.Config.Keywords))
+		for i, kw := range rule.Config.Keywords {
+			quoted[i] = keywordPattern(strings.TrimSpace(kw))
+		}
+		re, err := regexp.Compile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+		if err != nil {
+			return nil, err
+		}
+		return []*regexp.Regexp{re}, nil
+	case domain.AutoModTriggerRegex:
+		patterns := make([]*regexp.Regexp, len(rule.Config.Patterns))
+		for i, p := range rule.Config.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			patterns[i] = re
+		}
+		return patterns, nil
+	}
+	return nil, nil
+}
+
+// keywordPattern matches the keyword as a whole word. A word boundary only
+// exists next to a word character, so keywords starting or ending with
+// another character, such as "c++" or "@everyone", are left open on that
+// side.
+func keywordPattern(kw string) string {
+	pattern := regexp.QuoteMeta(kw)
+	if r, _ := utf8.DecodeRuneInString(kw); isWordChar(r) {
+		pattern = `\b` + pattern
+	}
+	if r, _ := utf8.DecodeLastRuneInString(kw); isWordChar(r) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+// isWordChar reports whether r is a word character as \b understands it,
+// which only considers ASCII.
+func isWordChar(r rune) bool {
+	return r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// matchesLinkRule reports whether content links to a denied domain or, when
+// an allow list is set, to any domain outside of it.
+func matchesLinkRule(config domain.AutoModConfig, content string) bool {
+	for _, m := range linkPattern.FindAllStringSubmatch(content, -1) {
+		host := strings.ToLower(m[1])
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+
+		if domainListContains(config.DeniedDomains, host) {
+			return true
+		}
+		if len(config.AllowedDomains) > 0 && !domainListContains(config.AllowedDomains, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func domainListContains(domains []string, host string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrBotNotFound = errors.New("bot not found")
+	ErrTooManyBots = errors.New("too many bots")
+)
+
+const maxBotsPerUser = 10
+
+// BotAccount is a bot along with its user account.
+type BotAccount struct {
+	User *domain.User
+	Bot  *domain.Bot
+}
+
+type BotService struct {
+	repo        domain.BotRepository
+	users       domain.UserRepository
+	members     domain.MemberRepository
+	bans        domain.BanRepository
+	audit       *AuditService
+	connections domain.ConnectionTerminator
+}
+
+func NewBotService(repo domain.BotRepository, users domain.UserRepository, members domain.MemberRepository, bans domain.BanRepository, audit *AuditService, connections domain.ConnectionTerminator) *BotService {
+	return &BotService{repo: repo, users: users, members: members, bans: bans, audit: audit, connections: connections}
+}
+
+// GetAll returns the bots owned by the user.
+func (s *BotService) GetAll(ctx context.Context, ownerID string) ([]BotAccount, error) {
+	bots, err := s.repo.GetByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get bots: %w", err)
+	}
+
+	accounts := make([]BotAccount, 0, len(bots))
+	for i := range bots {
+		user, err := s.users.GetByID(ctx, bots[i].UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		if user != nil {
+			accounts = append(accounts, BotAccount{User: user, Bot: &bots[i]})
+		}
+	}
+	return accounts, nil
+}
+
+// Get returns the bot with the given ID, whoever owns it, so that
+// administrators can review it before authorizing it.
+func (s *BotService) Get(ctx context.Context, id string) (*BotAccount, error) {
+	bot, err := s.repo.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get bot: %w", err)
+	}
+	if bot == nil {
+		return nil, ErrBotNotFound
+	}
+
+	user, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrBotNotFound
+	}
+	return &BotAccount{User: user, Bot: bot}, nil
+}
+
+// Create creates a bot owned by the user and returns it along with its token,
+// which is only known at this point. The bot cannot act in the community until
+// an administrator authorizes it.
+func (s *BotService) Create(ctx context.Context, ownerID, username string) (*BotAccount, string, error) {
+	owner, err := s.users.GetByID(ctx, ownerID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get user: %w", err)
+	}
+	if owner == nil {
+		return nil, "", ErrUserNotFound
+	}
+	if owner.IsBot {
+		return nil, "", ErrForbidden
+	}
+
+	existing, err := s.repo.GetByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get bots: %w", err)
+	}
+	if len(existing) >= maxBotsPerUser {
+		return nil, "", ErrTooManyBots
+	}
+
+	secret, err := generateBotToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	id := uuid.New().String()
+	user := &domain.User{
+		ID:       id,
+		Username: strings.TrimSpace(username),
+		// Email is unique, so every bot gets its own placeholder address.
+		Email:     id + "@" + domain.BotEmailDomain,
+		Status:    domain.UserStatusActive,
+		DMPolicy:  domain.DMPolicyEveryone,
+		IsBot:     true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	bot := &domain.Bot{
+		UserID:    id,
+		OwnerID:   ownerID,
+		TokenHash: hashToken(secret),
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, user, bot); err != nil {
+		return nil, "", fmt.Errorf("create bot: %w", err)
+	}
+	return &BotAccount{User: user, Bot: bot}, secret, nil
+}
+
+// ResetToken replaces the bot's token and returns the new one. Requests and
+// connections made with the previous token are rejected from then on.
+func (s *BotService) ResetToken(ctx context.Context, ownerID, id string) (string, error) {
+	if _, err := s.getOwned(ctx, ownerID, id); err != nil {
+		return "", err
+	}
+
+	secret, err := generateBotToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.UpdateToken(ctx, id, hashToken(secret)); err != nil {
+		return "", fmt.Errorf("update bot token: %w", err)
+	}
+	s.connections.TerminateUser(id)
+	return secret, nil
+}
+
+// Delete deletes the bot along with its user account and everything it
+// posted.
+func (s *BotService) Delete(ctx context.Context, ownerID, id string) error {
+	if _, err := s.getOwned(ctx, ownerID, id); err != nil {
+		return err
+	}
+
+	if err := s.users.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	s.connections.TerminateUser(id)
+	return nil
+}
+
+// Authorize adds the bot to the community with the given permissions, or
+// replaces the permissions of a bot that already joined. Bots cannot be
+// granted the admin scope, since they cannot be administrators.
+func (s *BotService) Authorize(ctx context.Context, adminID, id string, permissions []domain.TokenScope) (*BotAccount, error) {
+	account, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(permissions) == 0 {
+		return nil, ErrInvalidTokenScope
+	}
+	for _, scope := range permissions {
+		if !scope.Valid() || scope == domain.ScopeAdmin {
+			return nil, ErrInvalidTokenScope
+		}
+	}
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+	permissions = slices.Compact(permissions)
+
+	now := time.Now().UTC()
+	ban, err := s.bans.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get ban: %w", err)
+	}
+	if ban != nil && ban.Active(now) {
+		return nil, ErrBanned
+	}
+
+	member, err := s.members.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		member = &domain.Member{UserID: id, JoinedAt: now}
+		if err := s.members.Create(ctx, member); err != nil {
+			return nil, fmt.Errorf("create member: %w", err)
+		}
+	}
+
+	bot := account.Bot
+	previous := bot.Permissions
+	bot.Permissions = permissions
+	bot.AuthorizedBy = adminID
+	bot.AuthorizedAt = &now
+	if err := s.repo.UpdateAuthorization(ctx, bot); err != nil {
+		return nil, fmt.Errorf("update bot authorization: %w", err)
+	}
+
+	changes := []domain.AuditChange{{Key: "permissions", Old: previous, New: permissions}}
+	if err := s.audit.Record(ctx, domain.AuditBotAuthorize, domain.AuditTargetUser, id, changes); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *BotService) getOwned(ctx context.Context, ownerID, id string) (*domain.Bot, error) {
+	bot, err := s.repo.GetByUserID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get bot: %w", err)
+	}
+	if bot == nil || bot.OwnerID != ownerID {
+		return nil, ErrBotNotFound
+	}
+	return bot, nil
+}
+
+func generateBotToken() (string, error) {
+	secret, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	return domain.BotTokenPrefix + secret, nil
+}
+
+// BotAuthenticator checks bot tokens. It is separate from BotService, which
+// disconnects bots from the gateway, so that the gateway can use it too.
+type BotAuthenticator struct {
+	repo  domain.BotRepository
+	users domain.UserRepository
+}
+
+func NewBotAuthenticator(repo domain.BotRepository, users domain.UserRepository) *BotAuthenticator {
+	return &BotAuthenticator{repo: repo, users: users}
+}
+
+// Authenticate returns the bot whose token is secret, or nil when there is
+// none or either its account or its owner's was disabled.
+func (a *BotAuthenticator) Authenticate(ctx context.Context, secret string) (*domain.Bot, error) {
+	bot, err := a.repo.GetByHash(ctx, hashToken(secret))
+	if err != nil {
+		return nil, fmt.Errorf("get bot: %w", err)
+	}
+	if bot == nil {
+		return nil, nil
+	}
+
+	for _, id := range []string{bot.UserID, bot.OwnerID} {
+		user, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		if user == nil || user.Status != domain.UserStatusActive {
+			return nil, nil
+		}
+	}
+	return bot, nil
+}
@@ -0,0 +1,94 @@
+package application_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/mail"
+	"github.com/tartine-studio/harmony-server/internal/adapter/repository"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+func TestBotStopsAuthenticatingWithItsOwner(t *testing.T) {
+	ctx := context.Background()
+	db, err := repository.Open(filepath.Join(t.TempDir(), "harmony.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	gateway := &recordingGateway{}
+	mailer, err := mail.NewOutboxMailer(t.TempDir(), "Harmony <noreply@harmony.test>", zap.NewNop())
+	if err != nil {
+		t.Fatalf("create outbox mailer: %v", err)
+	}
+	userRepo := repository.NewUserRepository(db)
+	botRepo := repository.NewBotRepository(db)
+	auditSvc := application.NewAuditService(repository.NewAuditLogRepository(db), time.Hour)
+	sessionSvc := application.NewSessionService(repository.NewSessionRepository(db), repository.NewTokenVersionStore(db, time.Minute), gateway)
+	emailSvc := application.NewEmailService(userRepo, repository.NewEmailVerificationRepository(db), mailer, time.Hour, testPublicURL, false)
+	users := application.NewUserService(userRepo, botRepo, sessionSvc, emailSvc, auditSvc, gateway)
+	bots := application.NewBotService(botRepo, userRepo, repository.NewMemberRepository(db), repository.NewBanRepository(db), auditSvc, gateway)
+	authenticator := application.NewBotAuthenticator(botRepo, userRepo)
+
+	now := time.Now().UTC()
+	owner := &domain.User{
+		ID:        "owner",
+		Username:  "alice",
+		Email:     "alice@harmony.test",
+		Status:    domain.UserStatusActive,
+		DMPolicy:  domain.DMPolicyEveryone,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := userRepo.Create(ctx, owner); err != nil {
+		t.Fatalf("create owner: %v", err)
+	}
+	account, secret, err := bots.Create(ctx, owner.ID, "helper")
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+
+	authenticates := func() bool {
+		t.Helper()
+		bot, err := authenticator.Authenticate(ctx, secret)
+		if err != nil {
+			t.Fatalf("authenticate bot: %v", err)
+		}
+		return bot != nil
+	}
+
+	if !authenticates() {
+		t.Fatal("bot does not authenticate")
+	}
+
+	if _, err := users.Disable(ctx, owner.ID); err != nil {
+		t.Fatalf("disable owner: %v", err)
+	}
+	if authenticates() {
+		t.Error("bot authenticates while its owner is disabled")
+	}
+	if !slices.Contains(gateway.terminatedUsers, account.User.ID) {
+		t.Error("disabling the owner left the bot connected")
+	}
+
+	if _, err := users.Enable(ctx, owner.ID); err != nil {
+		t.Fatalf("enable owner: %v", err)
+	}
+	if !authenticates() {
+		t.Error("bot does not authenticate once its owner is enabled")
+	}
+
+	if err := users.Delete(ctx, owner.ID); err != nil {
+		t.Fatalf("delete owner: %v", err)
+	}
+	if authenticates() {
+		t.Error("bot authenticates after its owner was deleted")
+	}
+}
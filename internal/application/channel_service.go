@@ -14,19 +14,21 @@ import (
 var ErrChannelNotFound = errors.New("channel not found")
 
 type ChannelService struct {
-	repo domain.ChannelRepository
+	repo  domain.ChannelRepository
+	audit *AuditService
 }
 
-func NewChannelService(repo domain.ChannelRepository) *ChannelService {
-	return &ChannelService{repo: repo}
+func NewChannelService(repo domain.ChannelRepository, audit *AuditService) *ChannelService {
+	return &ChannelService{repo: repo, audit: audit}
 }
 
-func (s *ChannelService) Create(ctx context.Context, name string, channelType domain.ChannelType) (*domain.Channel, error) {
+func (s *ChannelService) Create(ctx context.Context, name string, channelType domain.ChannelType, slowmode int) (*domain.Channel, error) {
 	now := time.Now().UTC()
 	channel := &domain.Channel{
 		ID:        uuid.New().String(),
 		Name:      name,
 		Type:      channelType,
+		Slowmode:  slowmode,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -34,6 +36,16 @@ func (s *ChannelService) Create(ctx context.Context, name string, channelType do
 	if err := s.repo.Create(ctx, channel); err != nil {
 		return nil, fmt.Errorf("create channel: %w", err)
 	}
+
+	var diff auditDiff
+	diff.add("name", nil, channel.Name)
+	diff.add("type", nil, string(channel.Type))
+	if channel.Slowmode > 0 {
+		diff.add("slowmode", nil, channel.Slowmode)
+	}
+	if err := s.audit.Record(ctx, domain.AuditChannelCreate, domain.AuditTargetChannel, channel.ID, diff); err != nil {
+		return nil, err
+	}
 	return channel, nil
 }
 
@@ -56,7 +68,9 @@ func (s *ChannelService) GetByID(ctx context.Context, id string) (*domain.Channe
 	return channel, nil
 }
 
-func (s *ChannelService) Update(ctx context.Context, id, name string) (*domain.Channel, error) {
+// Update renames the channel and changes its slowmode. Empty or nil values
+// leave the corresponding setting untouched.
+func (s *ChannelService) Update(ctx context.Context, id, name string, slowmode *int) (*domain.Channel, error) {
 	channel, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get channel: %w", err)
@@ -65,12 +79,23 @@ func (s *ChannelService) Update(ctx context.Context, id, name string) (*domain.C
 		return nil, ErrChannelNotFound
 	}
 
-	channel.Name = name
+	var diff auditDiff
+	if name != "" {
+		diff.add("name", channel.Name, name)
+		channel.Name = name
+	}
+	if slowmode != nil {
+		diff.add("slowmode", channel.Slowmode, *slowmode)
+		channel.Slowmode = *slowmode
+	}
 	channel.UpdatedAt = time.Now().UTC()
 
 	if err := s.repo.Update(ctx, channel); err != nil {
 		return nil, fmt.Errorf("update channel: %w", err)
 	}
+	if err := s.audit.Record(ctx, domain.AuditChannelUpdate, domain.AuditTargetChannel, channel.ID, diff); err != nil {
+		return nil, err
+	}
 	return channel, nil
 }
 
@@ -85,5 +110,9 @@ func (s *ChannelService) Delete(ctx context.Context, id string) error {
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("delete channel: %w", err)
 	}
-	return nil
+
+	var diff auditDiff
+	diff.add("name", channel.Name, nil)
+	diff.add("type", string(channel.Type), nil)
+	return s.audit.Record(ctx, domain.AuditChannelDelete, domain.AuditTargetChannel, channel.ID, diff)
 }
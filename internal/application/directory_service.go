@@ -0,0 +1,194 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrDirectoryUnavailable  = errors.New("directory is unavailable")
+	ErrDirectoryEntryNoEmail = errors.New("directory entry has no email address")
+	ErrDirectoryLinkRefused  = errors.New("an account with an unverified email already uses the directory entry's address")
+)
+
+// directoryProvider names the directory in the identities it links.
+const directoryProvider = "ldap"
+
+// DirectoryService lets users sign in with the credentials of their entry in
+// an external directory. It implements domain.CredentialVerifier.
+type DirectoryService struct {
+	users         domain.UserRepository
+	members       domain.MemberRepository
+	identities    domain.IdentityRepository
+	directory     domain.Directory
+	audit         *AuditService
+	mode          domain.RegistrationMode
+	autoProvision bool
+	adminGroups   []string
+}
+
+// NewDirectoryService creates a directory service. Members of adminGroups, if
+// any, are made administrators and everyone else is not.
+func NewDirectoryService(users domain.UserRepository, members domain.MemberRepository, identities domain.IdentityRepository, directory domain.Directory, audit *AuditService, mode domain.RegistrationMode, autoProvision bool, adminGroups []string) *DirectoryService {
+	return &DirectoryService{
+		users:         users,
+		members:       members,
+		identities:    identities,
+		directory:     directory,
+		audit:         audit,
+		mode:          mode,
+		autoProvision: autoProvision,
+		adminGroups:   adminGroups,
+	}
+}
+
+// VerifyCredentials authenticates against the directory and returns the user
+// linked to the entry, linking or provisioning one on first sign-in.
+func (s *DirectoryService) VerifyCredentials(ctx context.Context, login, password string) (*domain.User, error) {
+	// Many directories treat a bind with an empty password as anonymous and
+	// let it succeed.
+	if password == "" {
+		return nil, nil
+	}
+
+	entry, err := s.directory.Authenticate(ctx, login, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	user, err := s.resolveUser(ctx, entry)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if _, err := s.syncUser(ctx, user, entry); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Sync brings the name and administrator status of every user linked to the
+// directory up to date, and returns how many changed. Users whose entry was
+// removed are left alone; they can no longer sign in through the directory.
+func (s *DirectoryService) Sync(ctx context.Context) (int, error) {
+	identities, err := s.identities.GetIdentitiesByProvider(ctx, directoryProvider)
+	if err != nil {
+		return 0, fmt.Errorf("get user identities: %w", err)
+	}
+
+	var updated int
+	for _, identity := range identities {
+		entry, err := s.directory.Lookup(ctx, identity.Subject)
+		if err != nil {
+			return updated, fmt.Errorf("look up directory entry: %w", err)
+		}
+		if entry == nil {
+			continue
+		}
+
+		user, err := s.users.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return updated, fmt.Errorf("get user: %w", err)
+		}
+		if user == nil {
+			continue
+		}
+
+		changed, err := s.syncUser(ctx, user, entry)
+		if err != nil {
+			return updated, err
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// resolveUser returns the user linked to the entry, linking or provisioning
+// one the first time the entry signs in. It returns nil when the entry has no
+// account and provisioning is off.
+func (s *DirectoryService) resolveUser(ctx context.Context, entry *domain.DirectoryEntry) (*domain.User, error) {
+	linked, err := s.identities.GetIdentity(ctx, directoryProvider, entry.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get user identity: %w", err)
+	}
+	if linked != nil {
+		user, err := s.users.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		return user, nil
+	}
+
+	if entry.Email == "" {
+		return nil, ErrDirectoryEntryNoEmail
+	}
+
+	user, err := s.users.GetByEmail(ctx, entry.Email)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		if !s.autoProvision {
+			return nil, nil
+		}
+		name := externalUsername(entry.DisplayName, entry.Email)
+		if user, err = provisionUser(ctx, s.users, s.members, s.mode, name, entry.Email); err != nil {
+			return nil, err
+		}
+	} else if !user.EmailVerified {
+		// Whoever registered the address locally never proved they own it;
+		// linking would hand the account to the entry while they keep its
+		// password.
+		return nil, ErrDirectoryLinkRefused
+	}
+
+	link := &domain.UserIdentity{
+		Provider:  directoryProvider,
+		Subject:   entry.ID,
+		UserID:    user.ID,
+		Email:     entry.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.identities.CreateIdentity(ctx, link); err != nil {
+		return nil, fmt.Errorf("create user identity: %w", err)
+	}
+	return user, nil
+}
+
+// syncUser brings the user's name and administrator status up to date with
+// the entry and reports whether anything changed.
+func (s *DirectoryService) syncUser(ctx context.Context, user *domain.User, entry *domain.DirectoryEntry) (bool, error) {
+	var diff auditDiff
+	if entry.DisplayName != "" {
+		name := externalUsername(entry.DisplayName, entry.Email)
+		diff.add("username", user.Username, name)
+		user.Username = name
+	}
+	if len(s.adminGroups) > 0 {
+		isAdmin := memberOfAny(entry.Groups, s.adminGroups)
+		diff.add("isAdmin", user.IsAdmin, isAdmin)
+		user.IsAdmin = isAdmin
+	}
+	if len(diff) == 0 {
+		return false, nil
+	}
+
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.users.Update(ctx, user); err != nil {
+		return false, fmt.Errorf("update user: %w", err)
+	}
+
+	ctx = domain.ContextWithActor(ctx, domain.Actor{Reason: "directory sync"})
+	if err := s.audit.Record(ctx, domain.AuditUserUpdate, domain.AuditTargetUser, user.ID, diff); err != nil {
+		return false, err
+	}
+	return true, nil
+}
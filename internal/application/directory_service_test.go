@@ -0,0 +1,453 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/ldap"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+const (
+	testLDAPBaseDN      = "ou=people,dc=harmony,dc=test"
+	testLDAPServiceDN   = "cn=harmony,dc=harmony,dc=test"
+	testLDAPServicePass = "service secret"
+	testLDAPAdminGroup  = "cn=admins,ou=groups,dc=harmony,dc=test"
+)
+
+// ldapEntry is an entry of ldapServer. Entries with a password can bind.
+type ldapEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// ldapServer is an LDAP directory on a local port. It supports simple binds
+// and searches, which is all the directory adapter uses, and evaluates the
+// filters it receives rather than matching them as text. Searching requires
+// a bind.
+type ldapServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	entries  map[string]ldapEntry
+	searches []string
+}
+
+func newLDAPServer(t *testing.T) *ldapServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &ldapServer{listener: l, entries: make(map[string]ldapEntry)}
+	s.add(ldapEntry{dn: testLDAPServiceDN, password: testLDAPServicePass})
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// directory returns the directory adapter pointed at the server, configured
+// the way the server's defaults suggest.
+func (s *ldapServer) directory() *ldap.Directory {
+	return ldap.NewDirectory(ldap.Config{
+		URL:            "ldap://" + s.listener.Addr().String(),
+		BindDN:         testLDAPServiceDN,
+		BindPassword:   testLDAPServicePass,
+		BaseDN:         testLDAPBaseDN,
+		UserFilter:     "(|(uid={login})(mail={login}))",
+		IDAttribute:    "entryUUID",
+		EmailAttribute: "mail",
+		NameAttribute:  "displayName",
+		GroupAttribute: "memberOf",
+	})
+}
+
+func (s *ldapServer) add(entry ldapEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[strings.ToLower(entry.dn)] = entry
+}
+
+func (s *ldapServer) set(dn, attr string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entries[strings.ToLower(dn)]
+	entry.attrs = maps.Clone(entry.attrs)
+	entry.attrs[attr] = values
+	s.entries[strings.ToLower(dn)] = entry
+}
+
+func (s *ldapServer) remove(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, strings.ToLower(dn))
+}
+
+// filters returns the filters of the searches made so far.
+func (s *ldapServer) filters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.searches)
+}
+
+func (s *ldapServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var bound bool
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			bound = s.bind(op)
+			code := uint16(goldap.LDAPResultSuccess)
+			if !bound {
+				code = goldap.LDAPResultInvalidCredentials
+			}
+			responses = append(responses, ldapResult(id, goldap.ApplicationBindResponse, code))
+		case goldap.ApplicationSearchRequest:
+			if !bound {
+				responses = append(responses, ldapResult(id, goldap.ApplicationSearchResultDone, goldap.LDAPResultInsufficientAccessRights))
+				break
+			}
+			responses = s.search(id, op)
+		case goldap.ApplicationUnbindRequest:
+			return
+		default:
+			responses = append(responses, ldapResult(id, goldap.ApplicationExtendedResponse, goldap.LDAPResultUnwillingToPerform))
+		}
+
+		for _, response := range responses {
+			if _, err := conn.Write(response.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind reports whether a simple bind request names an entry and its password.
+func (s *ldapServer) bind(op *ber.Packet) bool {
+	if len(op.Children) < 3 {
+		return false
+	}
+	dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[strings.ToLower(dn)]
+	return ok && entry.password != "" && entry.password == password
+}
+
+func (s *ldapServer) search(id int64, op *ber.Packet) []*ber.Packet {
+	base := strings.ToLower(op.Children[0].Data.String())
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, attr := range op.Children[7].Children {
+		attributes = append(attributes, attr.Data.String())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if text, err := goldap.DecompileFilter(filter); err == nil {
+		s.searches = append(s.searches, text)
+	}
+
+	if _, ok := s.entries[base]; !ok && scope == goldap.ScopeBaseObject {
+		return []*ber.Packet{ldapResult(id, goldap.ApplicationSearchResultDone, goldap.LDAPResultNoSuchObject)}
+	}
+
+	var responses []*ber.Packet
+	code := uint16(goldap.LDAPResultSuccess)
+	for key, entry := range s.entries {
+		inScope := key == base
+		if scope != goldap.ScopeBaseObject {
+			inScope = inScope || strings.HasSuffix(key, ","+base)
+		}
+		if !inScope || !matchFilter(filter, entry.attrs) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+			code = goldap.LDAPResultSizeLimitExceeded
+			break
+		}
+		responses = append(responses, searchEntry(id, entry, attributes))
+	}
+	return append(responses, ldapResult(id, goldap.ApplicationSearchResultDone, code))
+}
+
+// matchFilter evaluates the filters the adapter builds: and, or, not,
+// equality, substrings and presence. Matching ignores case, as it does for
+// the attributes directories usually sign users in with.
+func matchFilter(filter *ber.Packet, attrs map[string][]string) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, attrs) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, attrs) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterNot:
+		return len(filter.Children) == 1 && !matchFilter(filter.Children[0], attrs)
+	case goldap.FilterPresent:
+		return len(attribute(attrs, filter.Data.String())) > 0
+	case goldap.FilterEqualityMatch:
+		want := filter.Children[1].Data.String()
+		return slices.ContainsFunc(attribute(attrs, filter.Children[0].Data.String()), func(v string) bool {
+			return strings.EqualFold(v, want)
+		})
+	case goldap.FilterSubstrings:
+		parts := filter.Children[1].Children
+		return slices.ContainsFunc(attribute(attrs, filter.Children[0].Data.String()), func(v string) bool {
+			return matchSubstrings(strings.ToLower(v), parts)
+		})
+	}
+	return false
+}
+
+func matchSubstrings(v string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		s := strings.ToLower(part.Data.String())
+		switch part.Tag {
+		case goldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case goldap.FilterSubstringsAny:
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		case goldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func attribute(attrs map[string][]string, name string) []string {
+	for key, values := range attrs {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func ldapResult(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return ldapMessage(id, op)
+}
+
+func searchEntry(id int64, entry ldapEntry, attributes []string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, ""))
+	list := ber.NewSequence("")
+	for _, name := range attributes {
+		values := attribute(entry.attrs, name)
+		if len(values) == 0 {
+			continue
+		}
+		attr := ber.NewSequence("")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	op.AppendChild(list)
+	return ldapMessage(id, op)
+}
+
+func ldapMessage(id int64, op *ber.Packet) *ber.Packet {
+	msg := ber.NewSequence("")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	msg.AppendChild(op)
+	return msg
+}
+
+// personEntry is a directory user with the password "wonderland".
+func personEntry(uid, email, name string, groups ...string) ldapEntry {
+	return ldapEntry{
+		dn:       "uid=" + uid + "," + testLDAPBaseDN,
+		password: "wonderland",
+		attrs: map[string][]string{
+			"entryUUID":   {uid + "-uuid"},
+			"uid":         {uid},
+			"mail":        {email},
+			"displayName": {name},
+			"memberOf":    groups,
+		},
+	}
+}
+
+func newDirectoryTestApp(t *testing.T) (*testApp, *ldapServer) {
+	t.Helper()
+	srv := newLDAPServer(t)
+	app := newTestApp(t, testOptions{directory: srv.directory(), directoryAdminGroups: []string{testLDAPAdminGroup}})
+	// The first account becomes an administrator whatever the directory says.
+	app.register(t, "owner", "owner@example.com")
+	return app, srv
+}
+
+func TestDirectoryLoginProvisionsAndSyncs(t *testing.T) {
+	ctx := context.Background()
+	app, srv := newDirectoryTestApp(t)
+	alice := personEntry("alice", "alice@example.com", "alice", testLDAPAdminGroup)
+	srv.add(alice)
+
+	for _, login := range []string{"alice", "ALICE@example.com"} {
+		result, err := app.auth.Login(ctx, login, "wonderland", testClient)
+		if err != nil {
+			t.Fatalf("login as %s: %v", login, err)
+		}
+		if result.Tokens == nil {
+			t.Fatalf("login as %s returned no tokens", login)
+		}
+	}
+
+	user, err := app.users.GetByEmail(ctx, "alice@example.com")
+	if err != nil || user == nil {
+		t.Fatalf("get provisioned user = %v, %v", user, err)
+	}
+	if !user.EmailVerified || !user.IsAdmin || user.Username != "alice" {
+		t.Fatalf("provisioned user = %+v, want a verified administrator named alice", user)
+	}
+
+	srv.set(alice.dn, "displayName", "alice.liddell")
+	srv.set(alice.dn, "memberOf")
+	if n, err := app.directory.Sync(ctx); err != nil || n != 1 {
+		t.Fatalf("sync = %d, %v, want 1 user updated", n, err)
+	}
+	if user, err = app.users.GetByID(ctx, user.ID); err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.IsAdmin || user.Username != "alice.liddell" {
+		t.Errorf("synced user = %+v, want alice.liddell without administrator status", user)
+	}
+	if n, err := app.directory.Sync(ctx); err != nil || n != 0 {
+		t.Errorf("second sync = %d, %v, want nothing to update", n, err)
+	}
+
+	// Removed entries are left alone, and no longer sign in.
+	srv.remove(alice.dn)
+	if n, err := app.directory.Sync(ctx); err != nil || n != 0 {
+		t.Errorf("sync after removal = %d, %v, want nothing to update", n, err)
+	}
+	if _, err := app.auth.Login(ctx, "alice", "wonderland", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("login as a removed entry = %v, want %v", err, application.ErrInvalidCredentials)
+	}
+}
+
+func TestDirectoryLoginRejectsWrongPassword(t *testing.T) {
+	ctx := context.Background()
+	app, srv := newDirectoryTestApp(t)
+	srv.add(personEntry("alice", "alice@example.com", "alice"))
+
+	if _, err := app.auth.Login(ctx, "alice", "looking glass", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("login with a wrong password = %v, want %v", err, application.ErrInvalidCredentials)
+	}
+	if _, err := app.auth.Login(ctx, "bob", "wonderland", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("login as an unknown entry = %v, want %v", err, application.ErrInvalidCredentials)
+	}
+}
+
+func TestDirectoryLoginEscapesFilter(t *testing.T) {
+	ctx := context.Background()
+	app, srv := newDirectoryTestApp(t)
+	srv.add(personEntry("alice", "alice@example.com", "alice"))
+
+	// Unescaped, each of these would find alice's entry and bind as her.
+	logins := map[string]string{
+		"*":            `(|(uid=\2a)(mail=\2a))`,
+		"al*":          `(|(uid=al\2a)(mail=al\2a))`,
+		"*)(uid=alice": `(|(uid=\2a\29\28uid=alice)(mail=\2a\29\28uid=alice))`,
+		"x)(|(uid=*":   `(|(uid=x\29\28|\28uid=\2a)(mail=x\29\28|\28uid=\2a))`,
+	}
+	for login, filter := range logins {
+		if _, err := app.auth.Login(ctx, login, "wonderland", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+			t.Errorf("login as %q = %v, want %v", login, err, application.ErrInvalidCredentials)
+		}
+		if !slices.Contains(srv.filters(), filter) {
+			t.Errorf("login as %q searched %q, want %q", login, srv.filters(), filter)
+		}
+	}
+}
+
+func TestDirectoryRefusesToLinkUnverifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	app, srv := newDirectoryTestApp(t)
+	local := app.register(t, "alice", "alice@example.com")
+	srv.add(personEntry("alice", "alice@example.com", "alice"))
+
+	if _, err := app.auth.Login(ctx, "alice", "wonderland", testClient); !errors.Is(err, application.ErrDirectoryLinkRefused) {
+		t.Fatalf("login matching an unverified account = %v, want %v", err, application.ErrDirectoryLinkRefused)
+	}
+	// The local password keeps working for whoever registered it.
+	if _, err := app.auth.Login(ctx, "alice@example.com", "correct horse battery", testClient); err != nil {
+		t.Fatalf("login with the local password: %v", err)
+	}
+
+	verifyEmail(t, app, local.ID)
+	if _, err := app.auth.Login(ctx, "alice", "wonderland", testClient); err != nil {
+		t.Errorf("login once the account is verified: %v", err)
+	}
+}
+
+func TestDirectoryLoginReportsDirectoryErrors(t *testing.T) {
+	ctx := context.Background()
+	app, srv := newDirectoryTestApp(t)
+	srv.add(personEntry("alice", "", "alice"))
+
+	if _, err := app.auth.Login(ctx, "alice", "wonderland", testClient); !errors.Is(err, application.ErrDirectoryEntryNoEmail) {
+		t.Errorf("login as an entry without email = %v, want %v", err, application.ErrDirectoryEntryNoEmail)
+	}
+
+	srv.listener.Close()
+	if _, err := app.auth.Login(ctx, "alice", "wonderland", testClient); !errors.Is(err, application.ErrDirectoryUnavailable) {
+		t.Errorf("login while the directory is down = %v, want %v", err, application.ErrDirectoryUnavailable)
+	}
+}
@@ -0,0 +1,531 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrDMChannelNotFound = errors.New("dm channel not found")
+	ErrCannotDMSelf      = errors.New("cannot open a dm with yourself")
+	ErrDMNotAllowed      = errors.New("recipient does not accept direct messages from you")
+	ErrNotGroupDM        = errors.New("channel is not a group dm")
+	ErrGroupDMFull       = errors.New("group dm has reached its recipient limit")
+	ErrAlreadyRecipient  = errors.New("user is already in this channel")
+	ErrNotRecipient      = errors.New("user is not in this channel")
+)
+
+// DMService manages direct messages. They live outside the community: bans,
+// timeouts, slowmode and automod do not apply, and only the recipients of a
+// channel can read or post in it.
+type DMService struct {
+	repo     domain.DMChannelRepository
+	messages domain.MessageRepository
+	users    domain.UserRepository
+	members  domain.MemberRepository
+	// relationships decides who may message whom and whose messages are
+	// hidden from each recipient.
+	relationships domain.RelationshipRepository
+	emails        *EmailService
+	events        domain.EventPublisher
+	// maxGroupRecipients caps the number of participants in a group channel,
+	// its owner included.
+	maxGroupRecipients int
+}
+
+func NewDMService(repo domain.DMChannelRepository, messages domain.MessageRepository, users domain.UserRepository, members domain.MemberRepository, relationships domain.RelationshipRepository, emails *EmailService, events domain.EventPublisher, maxGroupRecipients int) *DMService {
+	return &DMService{
+		repo:               repo,
+		messages:           messages,
+		users:              users,
+		members:            members,
+		relationships:      relationships,
+		emails:             emails,
+		events:             events,
+		maxGroupRecipients: maxGroupRecipients,
+	}
+}
+
+// Open returns the one-to-one channel between the two users, creating it on
+// first use.
+func (s *DMService) Open(ctx context.Context, userID, recipientID string) (*domain.DMChannel, error) {
+	if userID == recipientID {
+		return nil, ErrCannotDMSelf
+	}
+
+	channel, err := s.repo.GetByRecipients(ctx, userID, recipientID)
+	if err != nil {
+		return nil, fmt.Errorf("get dm channel: %w", err)
+	}
+	if channel != nil {
+		return channel, nil
+	}
+
+	if err := s.checkAllowed(ctx, userID, recipientID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	channel = &domain.DMChannel{
+		Channel: domain.Channel{
+			ID:        uuid.New().String(),
+			Type:      domain.ChannelTypeDM,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		RecipientIDs:   []string{userID, recipientID},
+		LastActivityAt: now,
+	}
+	// The channel may have been opened concurrently since it was looked up.
+	channel, created, err := s.repo.CreateDM(ctx, channel)
+	if err != nil {
+		return nil, fmt.Errorf("create dm channel: %w", err)
+	}
+	if !created {
+		return channel, nil
+	}
+
+	s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventChannelCreate, Data: channel})
+	return channel, nil
+}
+
+// CreateGroup opens a group channel owned by ownerID with the given
+// recipients.
+func (s *DMService) CreateGroup(ctx context.Context, ownerID string, recipientIDs []string, name string) (*domain.DMChannel, error) {
+	recipients := []string{ownerID}
+	for _, id := range recipientIDs {
+		if !slices.Contains(recipients, id) {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) < 2 {
+		return nil, ErrCannotDMSelf
+	}
+	if len(recipients) > s.maxGroupRecipients {
+		return nil, ErrGroupDMFull
+	}
+
+	for _, id := range recipients[1:] {
+		if err := s.checkAllowed(ctx, ownerID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	channel := &domain.DMChannel{
+		Channel: domain.Channel{
+			ID:        uuid.New().String(),
+			Name:      name,
+			Type:      domain.ChannelTypeGroupDM,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		OwnerID:        ownerID,
+		RecipientIDs:   recipients,
+		LastActivityAt: now,
+	}
+	if err := s.repo.Create(ctx, channel); err != nil {
+		return nil, fmt.Errorf("create group dm: %w", err)
+	}
+
+	s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventChannelCreate, Data: channel})
+	return channel, nil
+}
+
+// UpdateGroup renames a group channel or changes its icon. Nil values leave
+// the corresponding setting untouched. Only the owner may update the group.
+func (s *DMService) UpdateGroup(ctx context.Context, id, userID string, name, icon *string) (*domain.DMChannel, error) {
+	channel, err := s.getOwnedGroup(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*domain.Message
+	if name != nil && *name != channel.Name {
+		channel.Name = *name
+		changes = append(changes, systemMessage(channel.ID, userID, domain.MessageTypeChannelNameChange, *name))
+	}
+	if icon != nil && *icon != channel.Icon {
+		channel.Icon = *icon
+		changes = append(changes, systemMessage(channel.ID, userID, domain.MessageTypeChannelIconChange, *icon))
+	}
+	if len(changes) == 0 {
+		return channel, nil
+	}
+
+	channel.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(ctx, channel); err != nil {
+		return nil, fmt.Errorf("update group dm: %w", err)
+	}
+	if err := s.postSystemMessages(ctx, channel, changes...); err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventChannelUpdate, Data: channel})
+	return channel, nil
+}
+
+// AddRecipient adds a user to a group channel. Only the owner may add
+// recipients.
+func (s *DMService) AddRecipient(ctx context.Context, id, userID, recipientID string) (*domain.DMChannel, error) {
+	channel, err := s.getOwnedGroup(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(channel.RecipientIDs, recipientID) {
+		return nil, ErrAlreadyRecipient
+	}
+	if len(channel.RecipientIDs) >= s.maxGroupRecipients {
+		return nil, ErrGroupDMFull
+	}
+	if err := s.checkAllowed(ctx, userID, recipientID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AddRecipient(ctx, channel.ID, recipientID); err != nil {
+		return nil, fmt.Errorf("add recipient: %w", err)
+	}
+	previous := channel.RecipientIDs
+	channel.RecipientIDs = append(slices.Clone(previous), recipientID)
+
+	s.events.Publish(previous, domain.Event{Type: domain.EventChannelUpdate, Data: channel})
+	s.events.Publish([]string{recipientID}, domain.Event{Type: domain.EventChannelCreate, Data: channel})
+
+	message := systemMessage(channel.ID, userID, domain.MessageTypeRecipientAdd, recipientID)
+	if err := s.postSystemMessages(ctx, channel, message); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// RemoveRecipient removes a user from a group channel. Only the owner may
+// remove other recipients; removing oneself is the same as leaving.
+func (s *DMService) RemoveRecipient(ctx context.Context, id, userID, recipientID string) error {
+	if recipientID == userID {
+		return s.Leave(ctx, id, userID)
+	}
+
+	channel, err := s.getOwnedGroup(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(channel.RecipientIDs, recipientID) {
+		return ErrNotRecipient
+	}
+
+	if err := s.repo.RemoveRecipient(ctx, channel.ID, recipientID); err != nil {
+		return fmt.Errorf("remove recipient: %w", err)
+	}
+	channel.RecipientIDs = slices.DeleteFunc(channel.RecipientIDs, func(id string) bool { return id == recipientID })
+
+	message := systemMessage(channel.ID, userID, domain.MessageTypeRecipientRemove, recipientID)
+	if err := s.postSystemMessages(ctx, channel, message); err != nil {
+		return err
+	}
+
+	s.events.Publish([]string{recipientID}, domain.Event{Type: domain.EventChannelDelete, Data: channel})
+	s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventChannelUpdate, Data: channel})
+	return nil
+}
+
+// Leave removes the user from a group channel. When the owner leaves,
+// ownership passes to the longest-standing remaining recipient, and the
+// channel is deleted once nobody is left.
+func (s *DMService) Leave(ctx context.Context, id, userID string) error {
+	channel, err := s.getGroup(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RemoveRecipient(ctx, channel.ID, userID); err != nil {
+		return fmt.Errorf("remove recipient: %w", err)
+	}
+	channel.RecipientIDs = slices.DeleteFunc(channel.RecipientIDs, func(id string) bool { return id == userID })
+	s.events.Publish([]string{userID}, domain.Event{Type: domain.EventChannelDelete, Data: channel})
+
+	if len(channel.RecipientIDs) == 0 {
+		if err := s.repo.Delete(ctx, channel.ID); err != nil {
+			return fmt.Errorf("delete group dm: %w", err)
+		}
+		return nil
+	}
+
+	messages := []*domain.Message{
+		systemMessage(channel.ID, userID, domain.MessageTypeRecipientRemove, userID),
+	}
+	if channel.OwnerID == userID {
+		channel.OwnerID = channel.RecipientIDs[0]
+		channel.UpdatedAt = time.Now().UTC()
+		if err := s.repo.Update(ctx, channel); err != nil {
+			return fmt.Errorf("transfer group dm ownership: %w", err)
+		}
+		messages = append(messages, systemMessage(channel.ID, userID, domain.MessageTypeOwnerChange, channel.OwnerID))
+	}
+	if err := s.postSystemMessages(ctx, channel, messages...); err != nil {
+		return err
+	}
+
+	s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventChannelUpdate, Data: channel})
+	return nil
+}
+
+func (s *DMService) GetAll(ctx context.Context, userID string) ([]domain.DMChannel, error) {
+	channels, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get dm channels: %w", err)
+	}
+	return channels, nil
+}
+
+func (s *DMService) GetByID(ctx context.Context, id, userID string) (*domain.DMChannel, error) {
+	channel, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get dm channel: %w", err)
+	}
+	if channel == nil || !slices.Contains(channel.RecipientIDs, userID) {
+		return nil, ErrDMChannelNotFound
+	}
+	return channel, nil
+}
+
+func (s *DMService) CreateMessage(ctx context.Context, channelID, authorID, content string) (*domain.Message, error) {
+	channel, err := s.GetByID(ctx, channelID, authorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.emails.CheckVerified(ctx, authorID); err != nil {
+		return nil, err
+	}
+
+	// The recipient may have tightened their settings since the channel
+	// opened. Group members agreed to the conversation when they were added.
+	if channel.Type == domain.ChannelTypeDM {
+		for _, recipientID := range channel.RecipientIDs {
+			if recipientID == authorID {
+				continue
+			}
+			if err := s.checkAllowed(ctx, authorID, recipientID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	message := &domain.Message{
+		ID:        uuid.New().String(),
+		ChannelID: channel.ID,
+		AuthorID:  authorID,
+		Type:      domain.MessageTypeDefault,
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.messages.Create(ctx, message); err != nil {
+		return nil, fmt.Errorf("create message: %w", err)
+	}
+
+	if err := s.publishMessage(ctx, channel, domain.EventMessageCreate, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (s *DMService) GetMessages(ctx context.Context, channelID, userID, before string, limit int) ([]domain.Message, error) {
+	if _, err := s.GetByID(ctx, channelID, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultMessageLimit
+	}
+	limit = min(limit, maxMessageLimit)
+
+	messages, err := s.messages.Find(ctx, domain.MessageQuery{ChannelID: channelID, Before: before, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("get messages: %w", err)
+	}
+	if err := hideBlockedMessages(ctx, s.relationships, userID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// UpdateMessage edits a message. Only its author may edit it, and system
+// messages cannot be edited.
+func (s *DMService) UpdateMessage(ctx context.Context, channelID, id, userID, content string) (*domain.Message, error) {
+	channel, message, err := s.getMessage(ctx, channelID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.AuthorID != userID || message.Type != domain.MessageTypeDefault {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now().UTC()
+	message.Content = content
+	message.EditedAt = &now
+
+	if err := s.messages.Update(ctx, message); err != nil {
+		return nil, fmt.Errorf("update message: %w", err)
+	}
+
+	if err := s.publishMessage(ctx, channel, domain.EventMessageUpdate, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// DeleteMessage removes a message. Only its author may delete it.
+func (s *DMService) DeleteMessage(ctx context.Context, channelID, id, userID string) error {
+	channel, message, err := s.getMessage(ctx, channelID, id, userID)
+	if err != nil {
+		return err
+	}
+	if message.AuthorID != userID {
+		return ErrForbidden
+	}
+
+	if err := s.messages.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+
+	s.events.Publish(channel.RecipientIDs, domain.Event{
+		Type: domain.EventMessageDelete,
+		Data: &domain.MessageDeleteEvent{ID: message.ID, ChannelID: channel.ID},
+	})
+	return nil
+}
+
+// checkAllowed reports whether the sender may reach the recipient: neither
+// must have blocked the other, and the recipient's DM policy must let the
+// sender through.
+func (s *DMService) checkAllowed(ctx context.Context, senderID, recipientID string) error {
+	recipient, err := s.users.GetByID(ctx, recipientID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if recipient == nil || recipient.Status != domain.UserStatusActive {
+		return ErrUserNotFound
+	}
+
+	blocked, err := blockedBetween(ctx, s.relationships, senderID, recipientID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrDMNotAllowed
+	}
+
+	switch recipient.DMPolicy {
+	case domain.DMPolicyFriends:
+		rel, err := s.relationships.Get(ctx, recipientID, senderID)
+		if err != nil {
+			return fmt.Errorf("get relationship: %w", err)
+		}
+		if rel == nil || rel.Type != domain.RelationshipFriend {
+			return ErrDMNotAllowed
+		}
+	case domain.DMPolicyMembers:
+		for _, id := range []string{senderID, recipientID} {
+			member, err := s.members.GetByUserID(ctx, id)
+			if err != nil {
+				return fmt.Errorf("get member: %w", err)
+			}
+			if member == nil {
+				return ErrDMNotAllowed
+			}
+		}
+	}
+	return nil
+}
+
+func (s *DMService) getGroup(ctx context.Context, id, userID string) (*domain.DMChannel, error) {
+	channel, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.Type != domain.ChannelTypeGroupDM {
+		return nil, ErrNotGroupDM
+	}
+	return channel, nil
+}
+
+func (s *DMService) getOwnedGroup(ctx context.Context, id, userID string) (*domain.DMChannel, error) {
+	channel, err := s.getGroup(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.OwnerID != userID {
+		return nil, ErrForbidden
+	}
+	return channel, nil
+}
+
+func systemMessage(channelID, authorID string, messageType domain.MessageType, content string) *domain.Message {
+	return &domain.Message{
+		ID:        uuid.New().String(),
+		ChannelID: channelID,
+		AuthorID:  authorID,
+		Type:      messageType,
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// publishMessage delivers a message event to the channel's recipients, with
+// the content withheld from those who blocked the author.
+func (s *DMService) publishMessage(ctx context.Context, channel *domain.DMChannel, eventType domain.EventType, message *domain.Message) error {
+	var visible, hidden []string
+	for _, recipientID := range channel.RecipientIDs {
+		rel, err := s.relationships.Get(ctx, recipientID, message.AuthorID)
+		if err != nil {
+			return fmt.Errorf("get relationship: %w", err)
+		}
+		if isBlock(rel) {
+			hidden = append(hidden, recipientID)
+		} else {
+			visible = append(visible, recipientID)
+		}
+	}
+
+	s.events.Publish(visible, domain.Event{Type: eventType, Data: message})
+	if len(hidden) > 0 {
+		masked := *message
+		masked.Hide()
+		s.events.Publish(hidden, domain.Event{Type: eventType, Data: &masked})
+	}
+	return nil
+}
+
+// postSystemMessages records changes to a group channel in its history and
+// delivers them to the current recipients.
+func (s *DMService) postSystemMessages(ctx context.Context, channel *domain.DMChannel, messages ...*domain.Message) error {
+	for _, message := range messages {
+		if err := s.messages.Create(ctx, message); err != nil {
+			return fmt.Errorf("create system message: %w", err)
+		}
+		s.events.Publish(channel.RecipientIDs, domain.Event{Type: domain.EventMessageCreate, Data: message})
+	}
+	return nil
+}
+
+func (s *DMService) getMessage(ctx context.Context, channelID, id, userID string) (*domain.DMChannel, *domain.Message, error) {
+	channel, err := s.GetByID(ctx, channelID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message, err := s.messages.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get message: %w", err)
+	}
+	if message == nil || message.ChannelID != channel.ID {
+		return nil, nil, ErrMessageNotFound
+	}
+	return channel, message, nil
+}
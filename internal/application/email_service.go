@@ -0,0 +1,209 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+	// ErrVerificationNotSent is returned along with the user when their
+	// account or email change was saved but the verification link could not
+	// be sent. They can ask for a new one.
+	ErrVerificationNotSent = errors.New("verification email could not be sent")
+)
+
+type EmailService struct {
+	users         domain.UserRepository
+	verifications domain.EmailVerificationRepository
+	mailer        domain.Mailer
+	tokenTTL      time.Duration
+	publicURL     string
+	// requireVerified stops users from posting until they verify their email.
+	requireVerified bool
+}
+
+func NewEmailService(users domain.UserRepository, verifications domain.EmailVerificationRepository, mailer domain.Mailer, tokenTTL time.Duration, publicURL string, requireVerified bool) *EmailService {
+	return &EmailService{
+		users:           users,
+		verifications:   verifications,
+		mailer:          mailer,
+		tokenTTL:        tokenTTL,
+		publicURL:       publicURL,
+		requireVerified: requireVerified,
+	}
+}
+
+// SendVerification emails a verification link for the user's pending email
+// if they asked to change it, or for their current one otherwise. Links sent
+// earlier stop working.
+func (s *EmailService) SendVerification(ctx context.Context, user *domain.User) error {
+	email := user.PendingEmail
+	if email == "" {
+		if user.EmailVerified {
+			return ErrEmailAlreadyVerified
+		}
+		email = user.Email
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	if err := s.verifications.DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete email verifications: %w", err)
+	}
+
+	now := time.Now().UTC()
+	verification := &domain.EmailVerification{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     email,
+		ExpiresAt: now.Add(s.tokenTTL),
+		CreatedAt: now,
+	}
+	if err := s.verifications.Create(ctx, verification); err != nil {
+		return fmt.Errorf("create email verification: %w", err)
+	}
+
+	mail := domain.Mail{
+		To:      email,
+		Subject: "Verify your email for Harmony",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm that this address belongs to your Harmony account by opening
+the link below. It expires in %d hours.
+
+%s/verify-email?token=%s
+
+If you did not sign up for Harmony, you can ignore this email.
+`, user.Username, int(s.tokenTTL.Hours()), s.publicURL, token),
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("send email verification: %w", err)
+	}
+	return nil
+}
+
+// Resend sends a new verification link to the user.
+func (s *EmailService) Resend(ctx context.Context, userID string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// Verify consumes a verification token. When it was sent for a pending email
+// change the new address replaces the old one, which is told about the
+// change.
+func (s *EmailService) Verify(ctx context.Context, token string) (*domain.User, error) {
+	hash := hashToken(token)
+	verification, err := s.verifications.GetByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("get email verification: %w", err)
+	}
+	if verification == nil || !time.Now().UTC().Before(verification.ExpiresAt) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	ok, err := s.verifications.Delete(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("delete email verification: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.users.GetByID(ctx, verification.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var previous string
+	switch verification.Email {
+	case user.Email:
+		user.EmailVerified = true
+	case user.PendingEmail:
+		// Someone may have registered the address in the meantime.
+		existing, err := s.users.GetByEmail(ctx, user.PendingEmail)
+		if err != nil {
+			return nil, fmt.Errorf("check email: %w", err)
+		}
+		if existing != nil {
+			return nil, ErrEmailTaken
+		}
+		previous = user.Email
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.EmailVerified = true
+	default:
+		// The user changed their mind about the address since.
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	if previous != "" {
+		mail := domain.Mail{
+			To:      previous,
+			Subject: "Your Harmony email address was changed",
+			Body: fmt.Sprintf(`Hi %s,
+
+The email address of your Harmony account was changed to %s. You will no
+longer receive emails at this address.
+
+If you did not make this change, reset your password and contact an
+administrator of your instance.
+`, user.Username, user.Email),
+		}
+		if err := s.mailer.Send(ctx, mail); err != nil {
+			return nil, fmt.Errorf("send email change notice: %w", err)
+		}
+	}
+	return user, nil
+}
+
+// CheckVerified rejects users who have not verified their email when the
+// instance requires it.
+func (s *EmailService) CheckVerified(ctx context.Context, userID string) error {
+	if !s.requireVerified {
+		return nil
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	// Bots have no mailbox to verify.
+	if !user.EmailVerified && !user.IsBot {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// PruneExpired deletes verification tokens that expired.
+func (s *EmailService) PruneExpired(ctx context.Context) (int64, error) {
+	n, err := s.verifications.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired email verifications: %w", err)
+	}
+	return n, nil
+}
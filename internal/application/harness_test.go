@@ -0,0 +1,145 @@
+package application_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/mail"
+	"github.com/tartine-studio/harmony-server/internal/adapter/passkey"
+	"github.com/tartine-studio/harmony-server/internal/adapter/repository"
+	"github.com/tartine-studio/harmony-server/internal/adapter/token"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+const (
+	testPublicURL = "https://harmony.test"
+	testRPID      = "harmony.test"
+)
+
+var testClient = domain.ClientInfo{Device: "Go test", IPAddress: "192.0.2.1"}
+
+// testOptions selects the adapters a testApp is wired with. Zero values get
+// stand-ins: mail goes to an outbox and directory and OIDC sign-ins are off.
+type testOptions struct {
+	mailer        domain.Mailer
+	oidcProviders []application.OIDCProvider
+	// directory adds directory sign-ins, provisioning accounts and making
+	// members of directoryAdminGroups administrators.
+	directory            domain.Directory
+	directoryAdminGroups []string
+	// lockout replaces a policy lenient enough that tests never hit it.
+	lockout *application.LockoutPolicy
+}
+
+// testApp wires the services the way cmd/main.go does, on a fresh database.
+type testApp struct {
+	users        domain.UserRepository
+	auth         *application.AuthService
+	sessions     *application.SessionService
+	passwords    *application.PasswordService
+	passkeys     *application.PasskeyService
+	oidc         *application.OIDCService
+	directory    *application.DirectoryService
+	accessTokens *application.PersonalAccessTokenService
+	lockout      *application.LockoutService
+	// loginAttempts holds the failed logins lockout counts.
+	loginAttempts domain.LoginAttemptRepository
+	events        *recordingGateway
+}
+
+func newTestApp(t *testing.T, opts testOptions) *testApp {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := repository.Open(filepath.Join(t.TempDir(), "harmony.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mailer := opts.mailer
+	if mailer == nil {
+		mailer, err = mail.NewOutboxMailer(t.TempDir(), "Harmony <noreply@harmony.test>", zap.NewNop())
+		if err != nil {
+			t.Fatalf("create outbox mailer: %v", err)
+		}
+	}
+	gateway := &recordingGateway{}
+
+	keyring := token.NewKeyring(repository.NewSigningKeyRepository(db), "EdDSA", 24*time.Hour)
+	if err := keyring.Load(ctx); err != nil {
+		t.Fatalf("load signing keys: %v", err)
+	}
+	jwtSvc := token.NewJwtService(keyring, "", 15*time.Minute, 24*time.Hour)
+
+	userRepo := repository.NewUserRepository(db)
+	memberRepo := repository.NewMemberRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	accessTokenRepo := repository.NewPersonalAccessTokenRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	auditSvc := application.NewAuditService(repository.NewAuditLogRepository(db), time.Hour)
+
+	sessionSvc := application.NewSessionService(sessionRepo, repository.NewTokenVersionStore(db, time.Minute), gateway)
+	inviteSvc := application.NewInviteService(repository.NewInviteRepository(db), memberRepo, repository.NewBanRepository(db), repository.NewChannelRepository(db), gateway)
+	emailSvc := application.NewEmailService(userRepo, repository.NewEmailVerificationRepository(db), mailer, time.Hour, testPublicURL, false)
+
+	passkeyProvider, err := passkey.NewWebAuthnProvider(testRPID, "Harmony", []string{testPublicURL})
+	if err != nil {
+		t.Fatalf("configure passkeys: %v", err)
+	}
+	passkeyRepo := repository.NewPasskeyRepository(db)
+	mfaSvc := application.NewMFAService(userRepo, repository.NewMFARepository(db), passkeyRepo, passkeyProvider, auditSvc, false)
+	passkeySvc := application.NewPasskeyService(userRepo, passkeyRepo, passkeyProvider, mfaSvc)
+
+	verifiers := []domain.CredentialVerifier{application.NewPasswordVerifier(userRepo)}
+	var directorySvc *application.DirectoryService
+	if opts.directory != nil {
+		directorySvc = application.NewDirectoryService(userRepo, memberRepo, identityRepo, opts.directory, auditSvc, domain.RegistrationOpen, true, opts.directoryAdminGroups)
+		verifiers = append(verifiers, directorySvc)
+	}
+	policy := application.LockoutPolicy{
+		FreeAttempts:     100,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		AccountThreshold: 100,
+		IPThreshold:      100,
+		LockoutDuration:  time.Minute,
+		Window:           time.Hour,
+	}
+	if opts.lockout != nil {
+		policy = *opts.lockout
+	}
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	lockoutSvc := application.NewLockoutService(loginAttemptRepo, userRepo, mailer, auditSvc, policy)
+	authSvc := application.NewAuthService(userRepo, verifiers, memberRepo, sessionRepo, sessionSvc, emailSvc, mfaSvc, passkeySvc, lockoutSvc, inviteSvc, auditSvc, jwtSvc, domain.RegistrationOpen, 24*time.Hour)
+
+	return &testApp{
+		users:         userRepo,
+		auth:          authSvc,
+		sessions:      sessionSvc,
+		passwords:     application.NewPasswordService(userRepo, repository.NewPasswordResetRepository(db), authSvc, sessionSvc, mailer, time.Hour, testPublicURL),
+		passkeys:      passkeySvc,
+		oidc:          application.NewOIDCService(userRepo, repository.NewOIDCRepository(db), identityRepo, authSvc, auditSvc, opts.oidcProviders),
+		directory:     directorySvc,
+		accessTokens:  application.NewPersonalAccessTokenService(accessTokenRepo, userRepo),
+		lockout:       lockoutSvc,
+		loginAttempts: loginAttemptRepo,
+		events:        gateway,
+	}
+}
+
+// register creates an account with the password "correct horse battery".
+func (a *testApp) register(t *testing.T, name, email string) *domain.User {
+	t.Helper()
+	user, err := a.auth.Register(context.Background(), name, email, "correct horse battery", "")
+	if err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+	return user
+}
@@ -0,0 +1,228 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrInviteInvalid  = errors.New("invite is invalid or expired")
+	ErrAlreadyMember  = errors.New("user is already a member")
+	ErrForbidden      = errors.New("forbidden")
+)
+
+const (
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// temporaryMemberGrace leaves members who joined through a temporary
+	// invite time to connect to the gateway before they are removed.
+	temporaryMemberGrace = 10 * time.Minute
+)
+
+type CreateInviteInput struct {
+	ChannelID string
+	MaxUses   int
+	MaxAge    time.Duration
+	Temporary bool
+}
+
+type InviteService struct {
+	repo     domain.InviteRepository
+	members  domain.MemberRepository
+	bans     domain.BanRepository
+	channels domain.ChannelRepository
+	presence domain.PresenceTracker
+}
+
+func NewInviteService(repo domain.InviteRepository, members domain.MemberRepository, bans domain.BanRepository, channels domain.ChannelRepository, presence domain.PresenceTracker) *InviteService {
+	return &InviteService{repo: repo, members: members, bans: bans, channels: channels, presence: presence}
+}
+
+func (s *InviteService) Create(ctx context.Context, creatorID string, input CreateInviteInput) (*domain.Invite, error) {
+	if input.ChannelID != "" {
+		channel, err := s.channels.GetByID(ctx, input.ChannelID)
+		if err != nil {
+			return nil, fmt.Errorf("get channel: %w", err)
+		}
+		if channel == nil {
+			return nil, ErrChannelNotFound
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, fmt.Errorf("generate invite code: %w", err)
+	}
+
+	now := time.Now().UTC()
+	invite := &domain.Invite{
+		Code:      code,
+		CreatorID: creatorID,
+		ChannelID: input.ChannelID,
+		MaxUses:   input.MaxUses,
+		Temporary: input.Temporary,
+		CreatedAt: now,
+	}
+	if input.MaxAge > 0 {
+		expiresAt := now.Add(input.MaxAge)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.Create(ctx, invite); err != nil {
+		return nil, fmt.Errorf("create invite: %w", err)
+	}
+	return invite, nil
+}
+
+func (s *InviteService) GetAll(ctx context.Context) ([]domain.Invite, error) {
+	invites, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get all invites: %w", err)
+	}
+	return invites, nil
+}
+
+// Preview returns a usable invite together with its target channel, if any,
+// without redeeming it.
+func (s *InviteService) Preview(ctx context.Context, code string) (*domain.Invite, *domain.Channel, error) {
+	invite, err := s.getUsable(ctx, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if invite.ChannelID == "" {
+		return invite, nil, nil
+	}
+	channel, err := s.channels.GetByID(ctx, invite.ChannelID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get channel: %w", err)
+	}
+	return invite, channel, nil
+}
+
+// Accept redeems the invite for the given user and makes them a member.
+func (s *InviteService) Accept(ctx context.Context, code, userID string) (*domain.Invite, error) {
+	invite, err := s.getUsable(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get member: %w", err)
+	}
+	if member != nil {
+		return invite, ErrAlreadyMember
+	}
+
+	now := time.Now().UTC()
+	ban, err := s.bans.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get ban: %w", err)
+	}
+	if ban != nil && ban.Active(now) {
+		return nil, ErrBanned
+	}
+
+	ok, err := s.repo.Use(ctx, code, now)
+	if err != nil {
+		return nil, fmt.Errorf("use invite: %w", err)
+	}
+	if !ok {
+		return nil, ErrInviteInvalid
+	}
+	invite.Uses++
+
+	member = &domain.Member{
+		UserID:     userID,
+		InviteCode: invite.Code,
+		Temporary:  invite.Temporary,
+		JoinedAt:   now,
+	}
+	if err := s.members.Create(ctx, member); err != nil {
+		return nil, fmt.Errorf("create member: %w", err)
+	}
+	return invite, nil
+}
+
+func (s *InviteService) Revoke(ctx context.Context, code, userID string) error {
+	invite, err := s.repo.GetByCode(ctx, code)
+	if err != nil {
+		return fmt.Errorf("get invite: %w", err)
+	}
+	if invite == nil {
+		return ErrInviteNotFound
+	}
+	if invite.CreatorID != userID {
+		return ErrForbidden
+	}
+	if err := s.repo.Delete(ctx, code); err != nil {
+		return fmt.Errorf("delete invite: %w", err)
+	}
+	return nil
+}
+
+// PruneExpired deletes every invite whose expiry has passed.
+func (s *InviteService) PruneExpired(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("prune invites: %w", err)
+	}
+	return n, nil
+}
+
+// PruneTemporaryMembers removes the temporary members who are no longer
+// connected to the gateway, once they had time to connect after joining.
+func (s *InviteService) PruneTemporaryMembers(ctx context.Context) (int64, error) {
+	members, err := s.members.GetTemporary(ctx, time.Now().UTC().Add(-temporaryMemberGrace))
+	if err != nil {
+		return 0, fmt.Errorf("get temporary members: %w", err)
+	}
+
+	var n int64
+	for _, member := range members {
+		if s.presence.Online(member.UserID) {
+			continue
+		}
+		if err := s.members.Delete(ctx, member.UserID); err != nil {
+			return n, fmt.Errorf("delete member: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *InviteService) getUsable(ctx context.Context, code string) (*domain.Invite, error) {
+	invite, err := s.repo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("get invite: %w", err)
+	}
+	if invite == nil {
+		return nil, ErrInviteNotFound
+	}
+	if !invite.Usable(time.Now().UTC()) {
+		return nil, ErrInviteInvalid
+	}
+	return invite, nil
+}
+
+func generateInviteCode() (string, error) {
+	size := big.NewInt(int64(len(inviteCodeAlphabet)))
+	b := make([]byte, inviteCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,290 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrTooManyAttempts  = errors.New("too many failed login attempts")
+	ErrAccountNotLocked = errors.New("account has no failed login attempts")
+)
+
+// TooManyAttemptsError reports how long to wait before trying to log in
+// again. It matches ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
+func (e *TooManyAttemptsError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// LockoutPolicy decides how failed logins slow down further attempts.
+type LockoutPolicy struct {
+	// FreeAttempts failures in a row for an account, or IPFreeAttempts from
+	// an address, are allowed without delay. Each one after that doubles
+	// the wait before the next attempt, starting at BaseDelay and up to
+	// MaxDelay.
+	FreeAttempts   int
+	IPFreeAttempts int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	// AccountThreshold failures lock the account for LockoutDuration, and
+	// IPThreshold failures from one address lock that address.
+	AccountThreshold int
+	IPThreshold      int
+	LockoutDuration  time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+const (
+	// maxBackoffShift bounds the doubling of the delay so that it cannot
+	// overflow before being capped.
+	maxBackoffShift = 20
+	// maxReserveTries bounds how often a login retries counting its attempt
+	// while parallel logins keep changing the count under it.
+	maxReserveTries = 5
+)
+
+// LockoutService tracks failed logins per account and per IP address to slow
+// down password guessing.
+type LockoutService struct {
+	repo   domain.LoginAttemptRepository
+	users  domain.UserRepository
+	mailer domain.Mailer
+	audit  *AuditService
+	policy LockoutPolicy
+}
+
+func NewLockoutService(repo domain.LoginAttemptRepository, users domain.UserRepository, mailer domain.Mailer, audit *AuditService, policy LockoutPolicy) *LockoutService {
+	return &LockoutService{repo: repo, users: users, mailer: mailer, audit: audit, policy: policy}
+}
+
+// Unlock forgets the failed logins of the user's account, lifting any lockout
+// and backoff. Failures recorded against the IP addresses they came from are
+// kept.
+func (s *LockoutService) Unlock(ctx context.Context, userID string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	n, err := s.repo.Delete(ctx, s.accountKeys(user.Email, user)...)
+	if err != nil {
+		return fmt.Errorf("delete login attempts: %w", err)
+	}
+	if n == 0 {
+		return ErrAccountNotLocked
+	}
+	return s.audit.Record(ctx, domain.AuditUserUnlock, domain.AuditTargetUser, user.ID, nil)
+}
+
+// PruneStale forgets failures older than the policy window.
+func (s *LockoutService) PruneStale(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+	n, err := s.repo.DeleteStale(ctx, now.Add(-s.policy.Window), now)
+	if err != nil {
+		return 0, fmt.Errorf("delete stale login attempts: %w", err)
+	}
+	return n, nil
+}
+
+// reserve counts a login attempt against the IP address and the account
+// before the credentials are checked, or returns a TooManyAttemptsError when
+// either must wait. Counting first means parallel attempts cannot all pass
+// the check before any of them fails; recordSuccess gives the attempt back.
+// Refused attempts are not counted. user is the account login belongs to, if
+// any.
+func (s *LockoutService) reserve(ctx context.Context, login string, user *domain.User, ip string) error {
+	now := time.Now().UTC()
+	if ip == "" {
+		return s.reserveKey(ctx, s.accountKey(login, user), s.policy.FreeAttempts, now)
+	}
+
+	if err := s.reserveKey(ctx, ipKey(ip), s.policy.IPFreeAttempts, now); err != nil {
+		return err
+	}
+	if err := s.reserveKey(ctx, s.accountKey(login, user), s.policy.FreeAttempts, now); err != nil {
+		if err := s.repo.Release(ctx, ipKey(ip)); err != nil {
+			return fmt.Errorf("release login attempt: %w", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *LockoutService) reserveKey(ctx context.Context, key string, free int, now time.Time) error {
+	for range maxReserveTries {
+		attempts, err := s.repo.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("get login attempts: %w", err)
+		}
+		if wait := s.retryAfter(attempts, free, now); wait > 0 {
+			return &TooManyAttemptsError{RetryAfter: wait}
+		}
+
+		ok, err := s.repo.Reserve(ctx, key, attempts, now, now.Add(-s.policy.Window))
+		if err != nil {
+			return fmt.Errorf("reserve login attempt: %w", err)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return &TooManyAttemptsError{RetryAfter: s.policy.BaseDelay}
+}
+
+// recordFailure locks the account or the IP address once the attempts
+// counted by reserve reach their threshold. The owner of a locked account is
+// told by email.
+func (s *LockoutService) recordFailure(ctx context.Context, login string, user *domain.User, ip string) error {
+	now := time.Now().UTC()
+
+	locked, err := s.lockAtThreshold(ctx, s.accountKey(login, user), s.policy.AccountThreshold, now)
+	if err != nil {
+		return fmt.Errorf("lock account: %w", err)
+	}
+	if locked && user != nil {
+		if err := s.notifyLocked(ctx, user, ip, now.Add(s.policy.LockoutDuration)); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	if _, err := s.lockAtThreshold(ctx, ipKey(ip), s.policy.IPThreshold, now); err != nil {
+		return fmt.Errorf("lock ip address: %w", err)
+	}
+	return nil
+}
+
+// lockAtThreshold locks key for the lockout duration when its failures
+// reached threshold and it is not locked already, and reports whether it did.
+func (s *LockoutService) lockAtThreshold(ctx context.Context, key string, threshold int, now time.Time) (bool, error) {
+	attempts, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if attempts == nil || attempts.Failures < threshold || lockedAt(attempts, now) {
+		return false, nil
+	}
+	if err := s.repo.Lock(ctx, key, now.Add(s.policy.LockoutDuration)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordSuccess forgets the failed logins of the account. The IP address only
+// gets back the attempt reserve counted, so that logging into one account
+// does not reset the count of guesses made against others.
+func (s *LockoutService) recordSuccess(ctx context.Context, login string, user *domain.User, ip string) error {
+	if _, err := s.repo.Delete(ctx, s.accountKeys(login, user)...); err != nil {
+		return fmt.Errorf("delete login attempts: %w", err)
+	}
+	if ip == "" {
+		return nil
+	}
+	if err := s.repo.Release(ctx, ipKey(ip)); err != nil {
+		return fmt.Errorf("release login attempt: %w", err)
+	}
+	return nil
+}
+
+// release gives back the attempts reserve counted, for logins that ended
+// before the credentials could be checked.
+func (s *LockoutService) release(ctx context.Context, login string, user *domain.User, ip string) error {
+	if err := s.repo.Release(ctx, s.accountKey(login, user)); err != nil {
+		return fmt.Errorf("release login attempt: %w", err)
+	}
+	if ip == "" {
+		return nil
+	}
+	if err := s.repo.Release(ctx, ipKey(ip)); err != nil {
+		return fmt.Errorf("release login attempt: %w", err)
+	}
+	return nil
+}
+
+// retryAfter returns how long the attempts block further logins from now.
+func (s *LockoutService) retryAfter(attempts *domain.LoginAttempts, free int, now time.Time) time.Duration {
+	if attempts == nil {
+		return 0
+	}
+	if lockedAt(attempts, now) {
+		return attempts.LockedUntil.Sub(now)
+	}
+	if attempts.Failures < free {
+		return 0
+	}
+
+	shift := min(attempts.Failures-free, maxBackoffShift)
+	delay := min(s.policy.BaseDelay<<shift, s.policy.MaxDelay)
+	if next := attempts.LastFailureAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+func (s *LockoutService) notifyLocked(ctx context.Context, user *domain.User, ip string, until time.Time) error {
+	mail := domain.Mail{
+		To:      user.Email,
+		Subject: "Your Harmony account was locked",
+		Body: fmt.Sprintf(`Hi %s,
+
+There were too many failed attempts to log into your Harmony account, the
+last one from %s. To protect it, logins are refused until
+%s.
+
+If these attempts were not yours, someone may be trying to guess your
+password. Consider changing it once the lock ends, and enabling two-factor
+authentication.
+`, user.Username, ip, until.Format(time.RFC1123)),
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("send lockout notice: %w", err)
+	}
+	return nil
+}
+
+// accountKey identifies the account failures are counted against. Logins that
+// match no local account are tracked too, so that they are throttled the same
+// way and do not reveal which accounts exist.
+func (s *LockoutService) accountKey(login string, user *domain.User) string {
+	if user != nil {
+		return "user:" + user.ID
+	}
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+// accountKeys returns every key the account's failures may have been counted
+// under.
+func (s *LockoutService) accountKeys(login string, user *domain.User) []string {
+	keys := []string{s.accountKey(login, user)}
+	if user != nil {
+		keys = append(keys, s.accountKey(user.Email, nil))
+	}
+	return keys
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func lockedAt(attempts *domain.LoginAttempts, now time.Time) bool {
+	return attempts.LockedUntil != nil && now.Before(*attempts.LockedUntil)
+}
@@ -0,0 +1,194 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// wantRetryAfter fails unless err asks to wait about want. Failures are
+// stored to the second, so the wait may be up to a second shorter.
+func wantRetryAfter(t *testing.T, err error, want time.Duration) {
+	t.Helper()
+	var tooMany *application.TooManyAttemptsError
+	if !errors.As(err, &tooMany) {
+		t.Fatalf("login = %v, want %v", err, application.ErrTooManyAttempts)
+	}
+	if tooMany.RetryAfter > want || tooMany.RetryAfter < want-2*time.Second {
+		t.Errorf("retry after %s, want %s", tooMany.RetryAfter, want)
+	}
+}
+
+// countFailure records a failed login for the account the way a login in
+// progress does.
+func countFailure(t *testing.T, app *testApp, user *domain.User) {
+	t.Helper()
+	ctx := context.Background()
+	key := "user:" + user.ID
+	attempts, err := app.loginAttempts.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("get login attempts: %v", err)
+	}
+	now := time.Now().UTC()
+	if ok, err := app.loginAttempts.Reserve(ctx, key, attempts, now, now.Add(-time.Hour)); err != nil || !ok {
+		t.Fatalf("count failure = %v, %v", ok, err)
+	}
+}
+
+func TestLoginBacksOffAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{lockout: &application.LockoutPolicy{
+		FreeAttempts:     2,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Minute,
+		MaxDelay:         4 * time.Minute,
+		AccountThreshold: 100,
+		IPThreshold:      100,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	for range 2 {
+		if _, err := app.auth.Login(ctx, user.Email, "wrong password", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+			t.Fatalf("login with a wrong password = %v, want %v", err, application.ErrInvalidCredentials)
+		}
+	}
+
+	// Each failure past the free ones doubles the wait, up to the maximum.
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		_, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient)
+		wantRetryAfter(t, err, want)
+		countFailure(t, app, user)
+	}
+}
+
+func TestLoginLocksAccountAtThreshold(t *testing.T) {
+	ctx := context.Background()
+	mailer := &recordingMailer{}
+	app := newTestApp(t, testOptions{mailer: mailer, lockout: &application.LockoutPolicy{
+		FreeAttempts:     100,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Second,
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		LockoutDuration:  15 * time.Minute,
+		Window:           time.Hour,
+	}})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	if err := app.lockout.Unlock(ctx, user.ID); !errors.Is(err, application.ErrAccountNotLocked) {
+		t.Errorf("unlock before any failure = %v, want %v", err, application.ErrAccountNotLocked)
+	}
+
+	for range 3 {
+		if _, err := app.auth.Login(ctx, user.Email, "wrong password", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+			t.Fatalf("login with a wrong password = %v, want %v", err, application.ErrInvalidCredentials)
+		}
+	}
+	_, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient)
+	wantRetryAfter(t, err, 15*time.Minute)
+
+	var notices []domain.Mail
+	for _, mail := range mailer.sent {
+		if mail.Subject == "Your Harmony account was locked" {
+			notices = append(notices, mail)
+		}
+	}
+	if len(notices) != 1 || notices[0].To != user.Email {
+		t.Errorf("lockout notices = %+v, want one to %s", notices, user.Email)
+	}
+
+	if err := app.lockout.Unlock(ctx, user.ID); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient); err != nil {
+		t.Errorf("login once unlocked: %v", err)
+	}
+}
+
+func TestParallelLoginsCannotSkipBackoff(t *testing.T) {
+	ctx := context.Background()
+	const free = 3
+	app := newTestApp(t, testOptions{lockout: &application.LockoutPolicy{
+		FreeAttempts:     free,
+		IPFreeAttempts:   100,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		AccountThreshold: 100,
+		IPThreshold:      100,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = app.auth.Login(ctx, user.Email, "wrong password", testClient)
+		}()
+	}
+	wg.Wait()
+
+	var checked int
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, application.ErrInvalidCredentials):
+			checked++
+		case !errors.Is(err, application.ErrTooManyAttempts):
+			t.Fatalf("parallel login = %v", err)
+		}
+	}
+	if checked == 0 || checked > free {
+		t.Errorf("%d parallel logins had their password checked, want between 1 and %d", checked, free)
+	}
+}
+
+func TestLoginGivesBackAttemptsWhenVerifierFails(t *testing.T) {
+	ctx := context.Background()
+	srv := newLDAPServer(t)
+	srv.listener.Close()
+	app := newTestApp(t, testOptions{directory: srv.directory(), lockout: &application.LockoutPolicy{
+		FreeAttempts:     1,
+		IPFreeAttempts:   1,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Minute,
+		AccountThreshold: 100,
+		IPThreshold:      100,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	// The directory being down says nothing about the password.
+	for range 3 {
+		if _, err := app.auth.Login(ctx, user.Email, "wrong password", testClient); !errors.Is(err, application.ErrDirectoryUnavailable) {
+			t.Fatalf("login while the directory is down = %v, want %v", err, application.ErrDirectoryUnavailable)
+		}
+	}
+	if _, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient); err != nil {
+		t.Errorf("login after directory outages: %v", err)
+	}
+}
+
+// recordingMailer keeps the mail it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []domain.Mail
+}
+
+func (m *recordingMailer) Send(_ context.Context, mail domain.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, mail)
+	return nil
+}
@@ -0,0 +1,244 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrNotTextChannel  = errors.New("channel does not accept messages")
+	ErrSlowmode        = errors.New("slowmode is active")
+)
+
+const (
+	defaultMessageLimit = 50
+	maxMessageLimit     = 100
+
+	// maxSlowmode is the longest slowmode a channel can have.
+	maxSlowmode = 6 * time.Hour
+)
+
+// SlowmodeError reports how long the author must wait before posting again
+// in a channel with slowmode enabled. It matches ErrSlowmode.
+type SlowmodeError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SlowmodeError) Error() string {
+	return fmt.Sprintf("slowmode is active, retry after %s", e.RetryAfter)
+}
+
+func (e *SlowmodeError) Is(target error) bool {
+	return target == ErrSlowmode
+}
+
+type MessageService struct {
+	repo       domain.MessageRepository
+	channels   domain.ChannelRepository
+	slowmode   domain.SlowmodeRepository
+	users      domain.UserRepository
+	moderation *ModerationService
+	emails     *EmailService
+	automod    *AutoModService
+	audit      *AuditService
+	// relationships hides messages from authors the reader blocked.
+	relationships domain.RelationshipRepository
+}
+
+func NewMessageService(repo domain.MessageRepository, channels domain.ChannelRepository, slowmode domain.SlowmodeRepository, users domain.UserRepository, moderation *ModerationService, emails *EmailService, automod *AutoModService, audit *AuditService, relationships domain.RelationshipRepository) *MessageService {
+	return &MessageService{repo: repo, channels: channels, slowmode: slowmode, users: users, moderation: moderation, emails: emails, automod: automod, audit: audit, relationships: relationships}
+}
+
+func (s *MessageService) Create(ctx context.Context, channelID, authorID, content string) (*domain.Message, error) {
+	channel, err := s.getTextChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.moderation.CheckCanCommunicate(ctx, authorID); err != nil {
+		return nil, err
+	}
+	if err := s.emails.CheckVerified(ctx, authorID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.checkSlowmode(ctx, channel, authorID, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.automod.Evaluate(ctx, channel.ID, authorID, content); err != nil {
+		return nil, err
+	}
+
+	message := &domain.Message{
+		ID:        uuid.New().String(),
+		ChannelID: channel.ID,
+		AuthorID:  authorID,
+		Type:      domain.MessageTypeDefault,
+		Content:   content,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, message); err != nil {
+		return nil, fmt.Errorf("create message: %w", err)
+	}
+	return message, nil
+}
+
+// GetByChannel returns a page of the channel's messages as seen by viewerID.
+func (s *MessageService) GetByChannel(ctx context.Context, channelID, viewerID, before string, limit int) ([]domain.Message, error) {
+	if _, err := s.getTextChannel(ctx, channelID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultMessageLimit
+	}
+	limit = min(limit, maxMessageLimit)
+
+	messages, err := s.repo.Find(ctx, domain.MessageQuery{ChannelID: channelID, Before: before, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("get messages: %w", err)
+	}
+	if err := hideBlockedMessages(ctx, s.relationships, viewerID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Update edits a message. Only its author may edit it.
+func (s *MessageService) Update(ctx context.Context, channelID, id, userID, content string) (*domain.Message, error) {
+	message, err := s.get(ctx, channelID, id)
+	if err != nil {
+		return nil, err
+	}
+	if message.AuthorID != userID {
+		return nil, ErrForbidden
+	}
+
+	if err := s.moderation.CheckCanCommunicate(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.automod.Evaluate(ctx, channelID, userID, content); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	message.Content = content
+	message.EditedAt = &now
+
+	if err := s.repo.Update(ctx, message); err != nil {
+		return nil, fmt.Errorf("update message: %w", err)
+	}
+	return message, nil
+}
+
+// Delete removes a message. Authors may delete their own messages and
+// administrators may delete any message, which is recorded in the audit log.
+func (s *MessageService) Delete(ctx context.Context, channelID, id, userID string) error {
+	message, err := s.get(ctx, channelID, id)
+	if err != nil {
+		return err
+	}
+
+	moderated := message.AuthorID != userID
+	if moderated {
+		admin, err := s.isAdmin(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return ErrForbidden
+		}
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+
+	if !moderated {
+		return nil
+	}
+	var diff auditDiff
+	diff.add("authorId", message.AuthorID, nil)
+	diff.add("content", message.Content, nil)
+	return s.audit.Record(ctx, domain.AuditMessageDelete, domain.AuditTargetMessage, message.ID, diff)
+}
+
+// PruneSlowmode forgets when members last posted once no slowmode can still
+// apply to them.
+func (s *MessageService) PruneSlowmode(ctx context.Context) (int64, error) {
+	n, err := s.slowmode.DeleteBefore(ctx, time.Now().UTC().Add(-maxSlowmode))
+	if err != nil {
+		return 0, fmt.Errorf("prune slowmode: %w", err)
+	}
+	return n, nil
+}
+
+// checkSlowmode returns a SlowmodeError when the author posted in the channel
+// more recently than its slowmode allows, and otherwise starts their cooldown
+// so that concurrent posts are rejected. Administrators are exempt.
+func (s *MessageService) checkSlowmode(ctx context.Context, channel *domain.Channel, authorID string, now time.Time) error {
+	if channel.Slowmode <= 0 {
+		return nil
+	}
+
+	admin, err := s.isAdmin(ctx, authorID)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return nil
+	}
+
+	interval := time.Duration(channel.Slowmode) * time.Second
+	ok, last, err := s.slowmode.Take(ctx, channel.ID, authorID, now, interval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &SlowmodeError{RetryAfter: last.Add(interval).Sub(now)}
+	}
+	return nil
+}
+
+func (s *MessageService) isAdmin(ctx context.Context, userID string) (bool, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get user: %w", err)
+	}
+	return user != nil && user.IsAdmin, nil
+}
+
+func (s *MessageService) getTextChannel(ctx context.Context, channelID string) (*domain.Channel, error) {
+	channel, err := s.channels.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel: %w", err)
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+	if channel.Type != domain.ChannelTypeText {
+		return nil, ErrNotTextChannel
+	}
+	return channel, nil
+}
+
+func (s *MessageService) get(ctx context.Context, channelID, id string) (*domain.Message, error) {
+	message, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get message: %w", err)
+	}
+	if message == nil || message.ChannelID != channelID {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
+}
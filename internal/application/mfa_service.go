@@ -0,0 +1,457 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication enrollment has not been started")
+	ErrMFARequired       = errors.New("two-factor authentication is required for administrators")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired two-factor authentication token")
+)
+
+const (
+	totpIssuer = "Harmony"
+
+	recoveryCodeCount = 10
+
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+)
+
+// MFAStatus describes the second factors a user has set up.
+type MFAStatus struct {
+	TOTPEnabled            bool
+	RecoveryCodesRemaining int
+	Passkeys               int
+	// Required is set for administrators when the instance requires them to
+	// use two-factor authentication.
+	Required bool
+}
+
+type MFAService struct {
+	users    domain.UserRepository
+	repo     domain.MFARepository
+	passkeys domain.PasskeyRepository
+	provider domain.PasskeyProvider
+	audit    *AuditService
+	// requireAdmin makes two-factor authentication mandatory for
+	// administrators.
+	requireAdmin bool
+}
+
+func NewMFAService(users domain.UserRepository, repo domain.MFARepository, passkeys domain.PasskeyRepository, provider domain.PasskeyProvider, audit *AuditService, requireAdmin bool) *MFAService {
+	return &MFAService{
+		users:        users,
+		repo:         repo,
+		passkeys:     passkeys,
+		provider:     provider,
+		audit:        audit,
+		requireAdmin: requireAdmin,
+	}
+}
+
+func (s *MFAService) Status(ctx context.Context, userID string) (*MFAStatus, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	totp, passkeys, err := s.factors(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return &MFAStatus{
+		TOTPEnabled:            totp,
+		RecoveryCodesRemaining: remaining,
+		Passkeys:               passkeys,
+		Required:               s.required(user),
+	}, nil
+}
+
+// Enabled reports whether the user must present a second factor to log in,
+// either a TOTP code or one of their passkeys.
+func (s *MFAService) Enabled(ctx context.Context, userID string) (bool, error) {
+	totp, passkeys, err := s.factors(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return totp || passkeys > 0, nil
+}
+
+// Satisfied reports whether the user meets the instance's two-factor
+// authentication requirement: either it does not apply to them or they
+// enabled it.
+func (s *MFAService) Satisfied(ctx context.Context, user *domain.User) (bool, error) {
+	if !s.required(user) {
+		return true, nil
+	}
+	return s.Enabled(ctx, user.ID)
+}
+
+// BeginTOTPEnrollment generates a new secret for the user and returns it along
+// with the otpauth URI to import in an authenticator app. The secret is not
+// used for logins until ConfirmTOTP is called with a code it generated.
+func (s *MFAService) BeginTOTPEnrollment(ctx context.Context, userID string) (string, string, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	totp, _, err := s.factors(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if totp {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	credential := &domain.TOTPCredential{
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.PutTOTP(ctx, credential); err != nil {
+		return "", "", fmt.Errorf("put totp credential: %w", err)
+	}
+	return secret, totpURI(totpIssuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP enables the secret generated by BeginTOTPEnrollment once the
+// user proves their app produces valid codes for it, and returns a fresh set
+// of recovery codes. They are only ever shown here.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	credential, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get totp credential: %w", err)
+	}
+	if credential == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if credential.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := validateTOTP(credential.Secret, normalizeMFACode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	credential.Enabled = true
+	credential.LastUsedStep = step
+	if err := s.repo.PutTOTP(ctx, credential); err != nil {
+		return nil, fmt.Errorf("put totp credential: %w", err)
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// DisableTOTP turns two-factor authentication off after checking a current
+// code or a recovery code.
+func (s *MFAService) DisableTOTP(ctx context.Context, userID, code string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.required(user) {
+		_, passkeys, err := s.factors(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if passkeys == 0 {
+			return ErrMFARequired
+		}
+	}
+	if err := s.verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.removeTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// current code, invalidating the previous set.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// Reset removes the second factors of a user who lost access to them,
+// passkeys included, so that they can log in with their password alone and
+// enroll again.
+func (s *MFAService) Reset(ctx context.Context, userID string) error {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return err
+	}
+	enabled, err := s.Enabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrMFANotEnabled
+	}
+	if err := s.removeTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.passkeys.DeleteByUser(ctx, userID); err != nil {
+		return fmt.Errorf("delete passkeys: %w", err)
+	}
+	return s.audit.Record(ctx, domain.AuditUserMFAReset, domain.AuditTargetUser, userID, nil)
+}
+
+// PruneExpiredChallenges deletes login challenges that were never completed.
+func (s *MFAService) PruneExpiredChallenges(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpiredChallenges(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired mfa challenges: %w", err)
+	}
+	return n, nil
+}
+
+// beginChallenge records that the user passed the password check and returns
+// the token that lets them complete the login with a second factor.
+func (s *MFAService) beginChallenge(ctx context.Context, userID string) (string, time.Time, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	challenge := &domain.MFAChallenge{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: now.Add(mfaChallengeTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateChallenge(ctx, challenge); err != nil {
+		return "", time.Time{}, fmt.Errorf("create mfa challenge: %w", err)
+	}
+	return token, challenge.ExpiresAt, nil
+}
+
+// beginPasskeyChallenge starts a passkey assertion to complete the challenge
+// and returns the options to pass to navigator.credentials.get.
+func (s *MFAService) beginPasskeyChallenge(ctx context.Context, token string) (json.RawMessage, error) {
+	challenge, err := s.getChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.passkeys.GetByUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get passkeys: %w", err)
+	}
+	if len(passkeys) == 0 {
+		return nil, ErrPasskeyNotFound
+	}
+
+	options, state, err := s.provider.BeginLogin(user, passkeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetChallengePasskeyState(ctx, challenge.TokenHash, state); err != nil {
+		return nil, fmt.Errorf("set mfa challenge passkey state: %w", err)
+	}
+	return options, nil
+}
+
+// completeChallenge checks the second factor for the challenge and returns the
+// ID of the user it was issued for. The factor is either a code or, after
+// beginPasskeyChallenge, a passkey assertion. A challenge can only be
+// completed once and is discarded after too many attempts. Each attempt is
+// counted before the factor is checked, so parallel guesses cannot exceed
+// the limit.
+func (s *MFAService) completeChallenge(ctx context.Context, token, code string, assertion []byte) (string, error) {
+	challenge, err := s.getChallenge(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	ok, err := s.repo.UseChallengeAttempt(ctx, challenge.TokenHash, mfaChallengeMaxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("use mfa challenge attempt: %w", err)
+	}
+	if !ok {
+		return "", ErrInvalidMFAToken
+	}
+
+	if len(assertion) > 0 {
+		err = s.verifyPasskey(ctx, challenge, assertion)
+	} else if err = s.verify(ctx, challenge.UserID, code); errors.Is(err, ErrMFANotEnabled) {
+		// Users with only passkeys have no codes to check against.
+		err = ErrInvalidMFACode
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ok, err = s.repo.DeleteChallenge(ctx, challenge.TokenHash)
+	if err != nil {
+		return "", fmt.Errorf("delete mfa challenge: %w", err)
+	}
+	if !ok {
+		// A parallel attempt completed it first.
+		return "", ErrInvalidMFAToken
+	}
+	return challenge.UserID, nil
+}
+
+func (s *MFAService) getChallenge(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	challenge, err := s.repo.GetChallenge(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("get mfa challenge: %w", err)
+	}
+	if challenge == nil || !time.Now().Before(challenge.ExpiresAt) || challenge.Attempts >= mfaChallengeMaxAttempts {
+		return nil, ErrInvalidMFAToken
+	}
+	return challenge, nil
+}
+
+// verifyPasskey checks a passkey assertion against the one started for the
+// challenge. Passkeys of other users are rejected.
+func (s *MFAService) verifyPasskey(ctx context.Context, challenge *domain.MFAChallenge, assertion []byte) error {
+	if len(challenge.PasskeyState) == 0 {
+		return fmt.Errorf("%w: no passkey assertion was started", ErrInvalidPasskey)
+	}
+	passkey, err := verifyPasskey(ctx, s.users, s.passkeys, s.provider, challenge.PasskeyState, assertion)
+	if err != nil {
+		return err
+	}
+	if passkey.UserID != challenge.UserID {
+		return ErrInvalidPasskey
+	}
+	return nil
+}
+
+// verify accepts either a code from the user's authenticator app or one of
+// their unused recovery codes. Both are single use.
+func (s *MFAService) verify(ctx context.Context, userID, code string) error {
+	credential, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get totp credential: %w", err)
+	}
+	if credential == nil || !credential.Enabled {
+		return ErrMFANotEnabled
+	}
+
+	code = normalizeMFACode(code)
+	if len(code) == totpDigits {
+		step, ok := validateTOTP(credential.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		fresh, err := s.repo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return fmt.Errorf("use totp step: %w", err)
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, hashToken(code), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeMFACode(code))
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("replace recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// factors returns whether the user enabled TOTP and how many passkeys they
+// registered.
+func (s *MFAService) factors(ctx context.Context, userID string) (bool, int, error) {
+	credential, err := s.repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("get totp credential: %w", err)
+	}
+	passkeys, err := s.passkeys.GetByUser(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("get passkeys: %w", err)
+	}
+	return credential != nil && credential.Enabled, len(passkeys), nil
+}
+
+// required reports whether the user must use two-factor authentication.
+func (s *MFAService) required(user *domain.User) bool {
+	return s.requireAdmin && user.IsAdmin
+}
+
+func (s *MFAService) removeTOTP(ctx context.Context, userID string) error {
+	if err := s.repo.DeleteTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("delete totp credential: %w", err)
+	}
+	if err := s.repo.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+func (s *MFAService) getUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// generateRecoveryCode returns 50 random bits formatted as two groups of five
+// base32 characters, such as "k7mxq-3rtzp".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate recovery code: %w", err)
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(b))
+	return code[:5] + "-" + code[5:10], nil
+}
+
+// normalizeMFACode strips the separators users tend to type or paste along
+// with a code, so "123 456" and "K7MXQ-3RTZP" are accepted.
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
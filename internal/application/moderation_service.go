@@ -0,0 +1,278 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrBanNotFound    = errors.New("ban not found")
+	ErrBanned         = errors.New("user is banned")
+	ErrTimedOut       = errors.New("user is timed out")
+	ErrCannotModerate = errors.New("cannot moderate this user")
+)
+
+// maxBanPurgeWindow bounds how far back a ban deletes the user's messages.
+const maxBanPurgeWindow = 7 * 24 * time.Hour
+
+type ModerationService struct {
+	members  domain.MemberRepository
+	bans     domain.BanRepository
+	users    domain.UserRepository
+	messages domain.MessageRepository
+	audit    *AuditService
+	events   domain.EventPublisher
+}
+
+func NewModerationService(members domain.MemberRepository, bans domain.BanRepository, users domain.UserRepository, messages domain.MessageRepository, audit *AuditService, events domain.EventPublisher) *ModerationService {
+	return &ModerationService{members: members, bans: bans, users: users, messages: messages, audit: audit, events: events}
+}
+
+// Kick removes the user's membership. They can rejoin with a new invite.
+func (s *ModerationService) Kick(ctx context.Context, moderatorID, userID string) error {
+	if err := s.checkTarget(ctx, moderatorID, userID); err != nil {
+		return err
+	}
+
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		return ErrMemberNotFound
+	}
+
+	if err := s.members.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+	return s.audit.Record(ctx, domain.AuditMemberKick, domain.AuditTargetUser, userID, nil)
+}
+
+// Ban removes the user's membership and prevents them from rejoining until
+// the ban is lifted or expires. A zero duration bans permanently. The
+// messages the user posted in community channels during the last purge
+// window, capped at seven days, are deleted.
+func (s *ModerationService) Ban(ctx context.Context, moderatorID, userID, reason string, duration, purge time.Duration) (*domain.Ban, error) {
+	if err := s.checkTarget(ctx, moderatorID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	ban := &domain.Ban{
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Reason:      reason,
+		CreatedAt:   now,
+	}
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	if err := s.bans.Create(ctx, ban); err != nil {
+		return nil, fmt.Errorf("create ban: %w", err)
+	}
+	if err := s.members.Delete(ctx, userID); err != nil {
+		return nil, fmt.Errorf("delete member: %w", err)
+	}
+
+	var purged int64
+	if purge > 0 {
+		var err error
+		purged, err = s.messages.DeleteByAuthorSince(ctx, userID, now.Add(-min(purge, maxBanPurgeWindow)))
+		if err != nil {
+			return nil, fmt.Errorf("purge messages: %w", err)
+		}
+	}
+
+	// The ban reason doubles as the audit reason when none was given.
+	if actor, ok := domain.ActorFromContext(ctx); ok && actor.Reason == "" {
+		actor.Reason = reason
+		ctx = domain.ContextWithActor(ctx, actor)
+	}
+
+	var diff auditDiff
+	diff.add("reason", nil, ban.Reason)
+	if ban.ExpiresAt != nil {
+		diff.add("expiresAt", nil, ban.ExpiresAt.Format(time.RFC3339))
+	}
+	if purged > 0 {
+		diff.add("deletedMessages", nil, purged)
+	}
+	if err := s.audit.Record(ctx, domain.AuditMemberBan, domain.AuditTargetUser, userID, diff); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+func (s *ModerationService) Unban(ctx context.Context, userID string) error {
+	ban, err := s.bans.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get ban: %w", err)
+	}
+	if ban == nil {
+		return ErrBanNotFound
+	}
+	if err := s.bans.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("delete ban: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("reason", ban.Reason, nil)
+	return s.audit.Record(ctx, domain.AuditMemberUnban, domain.AuditTargetUser, userID, diff)
+}
+
+func (s *ModerationService) GetBans(ctx context.Context) ([]domain.Ban, error) {
+	bans, err := s.bans.GetActive(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("get bans: %w", err)
+	}
+	return bans, nil
+}
+
+// Timeout prevents the member from communicating for the given duration.
+func (s *ModerationService) Timeout(ctx context.Context, moderatorID, userID string, duration time.Duration) (*domain.Member, error) {
+	if err := s.checkTarget(ctx, moderatorID, userID); err != nil {
+		return nil, err
+	}
+
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+
+	if err := s.applyTimeout(ctx, member, duration); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// TimeoutBySystem times a member out on behalf of the server itself, such as
+// for auto moderation. Administrators and non-members are left untouched.
+func (s *ModerationService) TimeoutBySystem(ctx context.Context, userID string, duration time.Duration, reason string) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil || user.IsAdmin {
+		return nil
+	}
+
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		return nil
+	}
+
+	ctx = domain.ContextWithActor(ctx, domain.Actor{Reason: reason})
+	return s.applyTimeout(ctx, member, duration)
+}
+
+func (s *ModerationService) applyTimeout(ctx context.Context, member *domain.Member, duration time.Duration) error {
+	until := time.Now().UTC().Add(duration)
+	if err := s.members.SetTimeout(ctx, member.UserID, &until); err != nil {
+		return fmt.Errorf("set timeout: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("timedOutUntil", formatAuditTime(member.TimedOutUntil), until.Format(time.RFC3339))
+	if err := s.audit.Record(ctx, domain.AuditMemberTimeout, domain.AuditTargetUser, member.UserID, diff); err != nil {
+		return err
+	}
+
+	member.TimedOutUntil = &until
+	s.events.Publish([]string{member.UserID}, domain.Event{Type: domain.EventMemberUpdate, Data: member})
+	return nil
+}
+
+func (s *ModerationService) RemoveTimeout(ctx context.Context, userID string) error {
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		return ErrMemberNotFound
+	}
+	if err := s.members.SetTimeout(ctx, userID, nil); err != nil {
+		return fmt.Errorf("clear timeout: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("timedOutUntil", formatAuditTime(member.TimedOutUntil), nil)
+	if err := s.audit.Record(ctx, domain.AuditMemberTimeoutRemove, domain.AuditTargetUser, userID, diff); err != nil {
+		return err
+	}
+
+	member.TimedOutUntil = nil
+	s.events.Publish([]string{userID}, domain.Event{Type: domain.EventMemberUpdate, Data: member})
+	return nil
+}
+
+func (s *ModerationService) GetTimeouts(ctx context.Context) ([]domain.Member, error) {
+	members, err := s.members.GetTimedOut(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("get timeouts: %w", err)
+	}
+	return members, nil
+}
+
+// CheckCanCommunicate returns ErrTimedOut when the user may not send
+// messages, react or speak, and ErrMemberNotFound when they are not a member.
+func (s *ModerationService) CheckCanCommunicate(ctx context.Context, userID string) error {
+	member, err := s.members.GetByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get member: %w", err)
+	}
+	if member == nil {
+		return ErrMemberNotFound
+	}
+	if member.TimedOut(time.Now().UTC()) {
+		return ErrTimedOut
+	}
+	return nil
+}
+
+// PruneExpired lifts bans and timeouts whose expiry has passed.
+func (s *ModerationService) PruneExpired(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+
+	bans, err := s.bans.DeleteExpired(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("prune bans: %w", err)
+	}
+	timeouts, err := s.members.ClearExpiredTimeouts(ctx, now)
+	if err != nil {
+		return bans, fmt.Errorf("prune timeouts: %w", err)
+	}
+	return bans + timeouts, nil
+}
+
+// checkTarget prevents moderators from acting on themselves, on
+// administrators or on unknown users.
+func (s *ModerationService) checkTarget(ctx context.Context, moderatorID, userID string) error {
+	if moderatorID == userID {
+		return ErrCannotModerate
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.IsAdmin {
+		return ErrCannotModerate
+	}
+	return nil
+}
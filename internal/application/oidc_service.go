@@ -0,0 +1,303 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidOIDCState     = errors.New("invalid or expired sign-in state")
+	ErrOIDCLoginFailed      = errors.New("identity provider rejected the sign-in")
+	ErrOIDCEmailNotVerified = errors.New("identity provider did not verify the email address")
+	ErrOIDCAccountNotFound  = errors.New("no account is linked to this identity")
+	ErrOIDCLinkRequired     = errors.New("an account with this email exists, sign in to link the identity to it")
+	ErrOIDCIdentityTaken    = errors.New("identity is already linked to another account")
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProvider is an OpenID Connect provider users can sign in with.
+type OIDCProvider struct {
+	Name        string
+	DisplayName string
+	Provider    domain.IdentityProvider
+	// AutoProvision creates an account on first sign-in for identities that
+	// match no existing user.
+	AutoProvision bool
+	// AdminGroups makes members of any of these groups administrators, and
+	// everyone else not. Administrator status is left alone when it is empty.
+	AdminGroups []string
+}
+
+type OIDCService struct {
+	users      domain.UserRepository
+	repo       domain.OIDCRepository
+	identities domain.IdentityRepository
+	auth       *AuthService
+	audit      *AuditService
+	providers  []OIDCProvider
+}
+
+func NewOIDCService(users domain.UserRepository, repo domain.OIDCRepository, identities domain.IdentityRepository, auth *AuthService, audit *AuditService, providers []OIDCProvider) *OIDCService {
+	return &OIDCService{users: users, repo: repo, identities: identities, auth: auth, audit: audit, providers: providers}
+}
+
+// Providers returns the configured providers, in configuration order.
+func (s *OIDCService) Providers() []OIDCProvider {
+	return s.providers
+}
+
+// Authorize starts signing in with the named provider and returns the URL to
+// send the user to. The provider sends them back to its redirect URL with the
+// code and state to pass to Callback.
+func (s *OIDCService) Authorize(ctx context.Context, name string) (string, error) {
+	return s.authorize(ctx, name, "")
+}
+
+// AuthorizeLink starts linking the named provider to the signed-in user's
+// account. The code and state the provider sends back go to Link.
+func (s *OIDCService) AuthorizeLink(ctx context.Context, userID, name string) (string, error) {
+	return s.authorize(ctx, name, userID)
+}
+
+func (s *OIDCService) authorize(ctx context.Context, name, userID string) (string, error) {
+	provider, err := s.getProvider(name)
+	if err != nil {
+		return "", err
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = generateToken(); err != nil {
+			return "", err
+		}
+	}
+	state, verifier, nonce := values[0], values[1], values[2]
+
+	now := time.Now().UTC()
+	record := &domain.OIDCState{
+		StateHash:    hashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    now.Add(oidcStateTTL),
+		CreatedAt:    now,
+	}
+	if err := s.repo.CreateState(ctx, record); err != nil {
+		return "", fmt.Errorf("create oidc state: %w", err)
+	}
+
+	url, err := provider.Provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", fmt.Errorf("build authorization url: %w", err)
+	}
+	return url, nil
+}
+
+// Callback completes signing in with the named provider. The identity is
+// matched to the account it was linked to before, then to an account with the
+// same email when both sides verified it, and otherwise gets a new account
+// when the provider allows it. Users with two-factor authentication still get
+// a challenge, as with Login.
+func (s *OIDCService) Callback(ctx context.Context, name, code, state string, client domain.ClientInfo) (*LoginResult, error) {
+	provider, err := s.getProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := s.exchange(ctx, provider, code, state, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncAdmin(ctx, provider, user, identity.Groups); err != nil {
+		return nil, err
+	}
+	return s.auth.completeLogin(ctx, user, client)
+}
+
+// Link completes linking the named provider to the signed-in user's account,
+// which lets them sign in with it from then on. The state must come from
+// AuthorizeLink for the same user.
+func (s *OIDCService) Link(ctx context.Context, userID, name, code, state string) error {
+	provider, err := s.getProvider(name)
+	if err != nil {
+		return err
+	}
+	identity, err := s.exchange(ctx, provider, code, state, userID)
+	if err != nil {
+		return err
+	}
+
+	linked, err := s.identities.GetIdentity(ctx, provider.Name, identity.Subject)
+	if err != nil {
+		return fmt.Errorf("get user identity: %w", err)
+	}
+	if linked != nil {
+		if linked.UserID != userID {
+			return ErrOIDCIdentityTaken
+		}
+		return nil
+	}
+
+	link := &domain.UserIdentity{
+		Provider:  provider.Name,
+		Subject:   identity.Subject,
+		UserID:    userID,
+		Email:     identity.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.identities.CreateIdentity(ctx, link); err != nil {
+		return fmt.Errorf("create user identity: %w", err)
+	}
+	return nil
+}
+
+// exchange consumes the state and trades the code for the identity it was
+// issued for. userID is empty for sign-ins and the linking user otherwise;
+// states started for one cannot be used for the other.
+func (s *OIDCService) exchange(ctx context.Context, provider *OIDCProvider, code, state, userID string) (*domain.ExternalIdentity, error) {
+	record, err := s.repo.TakeState(ctx, hashToken(state))
+	if err != nil {
+		return nil, fmt.Errorf("take oidc state: %w", err)
+	}
+	if record == nil || record.Provider != provider.Name || record.UserID != userID || !time.Now().UTC().Before(record.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	identity, err := provider.Provider.Exchange(ctx, code, record.CodeVerifier, record.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrOIDCLoginFailed)
+	}
+	return identity, nil
+}
+
+// PruneExpiredStates deletes sign-in states that were never completed.
+func (s *OIDCService) PruneExpiredStates(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpiredStates(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired oidc states: %w", err)
+	}
+	return n, nil
+}
+
+func (s *OIDCService) getProvider(name string) (*OIDCProvider, error) {
+	for i := range s.providers {
+		if s.providers[i].Name == name {
+			return &s.providers[i], nil
+		}
+	}
+	return nil, ErrOIDCProviderNotFound
+}
+
+func (s *OIDCService) resolveUser(ctx context.Context, provider *OIDCProvider, identity *domain.ExternalIdentity) (*domain.User, error) {
+	linked, err := s.identities.GetIdentity(ctx, provider.Name, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("get user identity: %w", err)
+	}
+	if linked != nil {
+		user, err := s.users.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		if user == nil {
+			return nil, ErrOIDCAccountNotFound
+		}
+		return user, nil
+	}
+
+	// Linking by an address the provider did not verify would let anyone
+	// claim an account by setting its email at the provider.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := s.users.GetByEmail(ctx, identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		if !provider.AutoProvision {
+			return nil, ErrOIDCAccountNotFound
+		}
+		name := externalUsername(identity.Name, identity.Email)
+		if user, err = provisionUser(ctx, s.users, s.auth.members, s.auth.mode, name, identity.Email); err != nil {
+			return nil, err
+		}
+	} else if !user.EmailVerified {
+		// Whoever registered the address locally never proved they own it,
+		// so the owner has to sign in and link the identity themselves.
+		return nil, ErrOIDCLinkRequired
+	}
+
+	link := &domain.UserIdentity{
+		Provider:  provider.Name,
+		Subject:   identity.Subject,
+		UserID:    user.ID,
+		Email:     identity.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.identities.CreateIdentity(ctx, link); err != nil {
+		return nil, fmt.Errorf("create user identity: %w", err)
+	}
+	return user, nil
+}
+
+// syncAdmin grants or revokes administrator status from the groups the
+// provider reported, when the provider maps groups to it.
+func (s *OIDCService) syncAdmin(ctx context.Context, provider *OIDCProvider, user *domain.User, groups []string) error {
+	if len(provider.AdminGroups) == 0 {
+		return nil
+	}
+
+	isAdmin := memberOfAny(groups, provider.AdminGroups)
+	if isAdmin == user.IsAdmin {
+		return nil
+	}
+
+	var diff auditDiff
+	diff.add("isAdmin", user.IsAdmin, isAdmin)
+	user.IsAdmin = isAdmin
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.users.Update(ctx, user); err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	ctx = domain.ContextWithActor(ctx, domain.Actor{Reason: "group membership at " + provider.Name})
+	return s.audit.Record(ctx, domain.AuditUserUpdate, domain.AuditTargetUser, user.ID, diff)
+}
+
+// memberOfAny reports whether any of groups is one of wanted.
+func memberOfAny(groups, wanted []string) bool {
+	return slices.ContainsFunc(groups, func(g string) bool {
+		return slices.Contains(wanted, g)
+	})
+}
+
+// externalUsername picks a username for an account held by an external
+// identity source from the name it reported, falling back to the local part
+// of the email address.
+func externalUsername(name, email string) string {
+	name = strings.TrimSpace(name)
+	if len([]rune(name)) < 2 {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	if runes := []rune(name); len(runes) > 32 {
+		name = string(runes[:32])
+	}
+	return name
+}
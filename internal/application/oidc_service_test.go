@@ -0,0 +1,418 @@
+package application_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/oidc"
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+const (
+	testOIDCClientID     = "harmony"
+	testOIDCClientSecret = "client secret"
+)
+
+// oidcGrant is an authorization code the provider issued, waiting to be
+// exchanged.
+type oidcGrant struct {
+	challenge string
+	claims    map[string]any
+}
+
+// oidcServer is an OpenID Connect provider on a local port. It serves
+// discovery, its signing keys and the token endpoint; the user's trip to the
+// authorization endpoint is played by signIn. Codes are single use and only
+// exchanged with the PKCE verifier they were issued for.
+type oidcServer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]oidcGrant
+}
+
+func newOIDCServer(t *testing.T) *oidcServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := &oidcServer{key: key, grants: make(map[string]oidcGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// provider returns a provider configuration named name for the server.
+func (s *oidcServer) provider(name string) application.OIDCProvider {
+	return application.OIDCProvider{
+		Name:        name,
+		DisplayName: name,
+		Provider: oidc.NewProvider(s.server.URL, testOIDCClientID, testOIDCClientSecret,
+			testPublicURL+"/oidc/"+name+"/callback", []string{"openid", "email", "profile"}, "groups"),
+	}
+}
+
+// signIn plays the user signing in at the authorization URL and returns the
+// code and state the provider redirects back with. The ID token will hold
+// claims, and the nonce from the URL unless claims sets one.
+func (s *oidcServer) signIn(t *testing.T, authURL string, claims map[string]any) (string, string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	if want := s.server.URL + "/authorize"; !strings.HasPrefix(authURL, want) {
+		t.Fatalf("authorization url = %s, want it to start with %s", authURL, want)
+	}
+	query := u.Query()
+	if query.Get("client_id") != testOIDCClientID || query.Get("response_type") != "code" {
+		t.Fatalf("authorization url = %s, want a code request for %s", authURL, testOIDCClientID)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization url = %s, want an S256 code challenge", authURL)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization url = %s, want a state and nonce", authURL)
+	}
+
+	claims = maps.Clone(claims)
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+	code := rand.Text()
+	s.mu.Lock()
+	s.grants[code] = oidcGrant{challenge: query.Get("code_challenge"), claims: claims}
+	s.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (s *oidcServer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.server.URL,
+		"authorization_endpoint":                s.server.URL + "/authorize",
+		"token_endpoint":                        s.server.URL + "/token",
+		"jwks_uri":                              s.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *oidcServer) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeTestJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &s.key.PublicKey,
+		KeyID:     "test",
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+func (s *oidcServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testOIDCClientID || secret != testOIDCClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	grant, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || b64.EncodeToString(sum[:]) != grant.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := maps.Clone(grant.claims)
+	claims["iss"] = s.server.URL
+	claims["aud"] = testOIDCClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	idToken, err := s.sign(claims)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *oidcServer) sign(claims map[string]any) (string, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: s.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// identityClaims are the claims of a provider account with a verified email.
+func identityClaims(subject, email string) map[string]any {
+	return map[string]any{
+		"sub":                subject,
+		"email":              email,
+		"email_verified":     true,
+		"preferred_username": subject,
+	}
+}
+
+// oidcSignIn signs in with the named provider as the holder of claims.
+func oidcSignIn(t *testing.T, app *testApp, srv *oidcServer, provider string, claims map[string]any) (*application.LoginResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := app.oidc.Authorize(ctx, provider)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code, state := srv.signIn(t, authURL, claims)
+	return app.oidc.Callback(ctx, provider, code, state, testClient)
+}
+
+// oidcLink links the named provider to the user's account as the holder of
+// claims.
+func oidcLink(t *testing.T, app *testApp, srv *oidcServer, userID, provider string, claims map[string]any) error {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := app.oidc.AuthorizeLink(ctx, userID, provider)
+	if err != nil {
+		t.Fatalf("authorize link: %v", err)
+	}
+	code, state := srv.signIn(t, authURL, claims)
+	return app.oidc.Link(ctx, userID, provider, code, state)
+}
+
+// verifyEmail marks the user's email as verified.
+func verifyEmail(t *testing.T, app *testApp, userID string) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := app.users.GetByID(ctx, userID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user.EmailVerified = true
+	if err := app.users.Update(ctx, user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+}
+
+func TestOIDCSignInLinksVerifiedAccount(t *testing.T) {
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp")}})
+	alice := app.register(t, "alice", "alice@example.com")
+	verifyEmail(t, app, alice.ID)
+
+	result, err := oidcSignIn(t, app, srv, "corp", identityClaims("alice-sub", "alice@example.com"))
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if result.Tokens == nil {
+		t.Fatal("sign in returned no tokens")
+	}
+
+	// The identity is linked now, so its email no longer matters.
+	claims := identityClaims("alice-sub", "alice@elsewhere.example")
+	claims["email_verified"] = false
+	if _, err := oidcSignIn(t, app, srv, "corp", claims); err != nil {
+		t.Errorf("sign in with the linked identity: %v", err)
+	}
+}
+
+func TestOIDCSignInRefusesUnverifiedEmails(t *testing.T) {
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp")}})
+	app.register(t, "alice", "alice@example.com")
+
+	// The local account never proved it owns the address.
+	if _, err := oidcSignIn(t, app, srv, "corp", identityClaims("alice-sub", "alice@example.com")); !errors.Is(err, application.ErrOIDCLinkRequired) {
+		t.Errorf("sign in matching an unverified account = %v, want %v", err, application.ErrOIDCLinkRequired)
+	}
+
+	// The provider never checked the address.
+	claims := identityClaims("mallory-sub", "alice@example.com")
+	claims["email_verified"] = false
+	if _, err := oidcSignIn(t, app, srv, "corp", claims); !errors.Is(err, application.ErrOIDCEmailNotVerified) {
+		t.Errorf("sign in with an unverified email = %v, want %v", err, application.ErrOIDCEmailNotVerified)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp")}})
+	alice := app.register(t, "alice", "alice@example.com")
+	bob := app.register(t, "bob", "bob@example.com")
+
+	if err := oidcLink(t, app, srv, alice.ID, "corp", identityClaims("alice-sub", "alice@example.com")); err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if _, err := oidcSignIn(t, app, srv, "corp", identityClaims("alice-sub", "alice@example.com")); err != nil {
+		t.Errorf("sign in with the linked identity: %v", err)
+	}
+
+	if err := oidcLink(t, app, srv, alice.ID, "corp", identityClaims("alice-sub", "alice@example.com")); err != nil {
+		t.Errorf("link again: %v", err)
+	}
+	if err := oidcLink(t, app, srv, bob.ID, "corp", identityClaims("alice-sub", "alice@example.com")); !errors.Is(err, application.ErrOIDCIdentityTaken) {
+		t.Errorf("link another account's identity = %v, want %v", err, application.ErrOIDCIdentityTaken)
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp")}})
+	alice := app.register(t, "alice", "alice@example.com")
+	verifyEmail(t, app, alice.ID)
+
+	// An ID token minted for another sign-in cannot be replayed into this one.
+	claims := identityClaims("alice-sub", "alice@example.com")
+	claims["nonce"] = "another sign-in"
+	if _, err := oidcSignIn(t, app, srv, "corp", claims); !errors.Is(err, application.ErrOIDCLoginFailed) {
+		t.Errorf("sign in with a foreign nonce = %v, want %v", err, application.ErrOIDCLoginFailed)
+	}
+}
+
+func TestOIDCRejectsCodeFromAnotherSignIn(t *testing.T) {
+	ctx := context.Background()
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp")}})
+	alice := app.register(t, "alice", "alice@example.com")
+	verifyEmail(t, app, alice.ID)
+
+	// A code injected into someone else's sign-in is exchanged with that
+	// sign-in's verifier, which the provider refuses.
+	victimURL, err := app.oidc.Authorize(ctx, "corp")
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	attackerURL, err := app.oidc.Authorize(ctx, "corp")
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code, _ := srv.signIn(t, attackerURL, identityClaims("alice-sub", "alice@example.com"))
+	_, state := srv.signIn(t, victimURL, identityClaims("alice-sub", "alice@example.com"))
+
+	if _, err := app.oidc.Callback(ctx, "corp", code, state, testClient); !errors.Is(err, application.ErrOIDCLoginFailed) {
+		t.Errorf("callback with another sign-in's code = %v, want %v", err, application.ErrOIDCLoginFailed)
+	}
+}
+
+func TestOIDCStateIsSingleUseAndBound(t *testing.T) {
+	ctx := context.Background()
+	srv := newOIDCServer(t)
+	app := newTestApp(t, testOptions{oidcProviders: []application.OIDCProvider{srv.provider("corp"), srv.provider("partner")}})
+	alice := app.register(t, "alice", "alice@example.com")
+	bob := app.register(t, "bob", "bob@example.com")
+	verifyEmail(t, app, alice.ID)
+	claims := identityClaims("alice-sub", "alice@example.com")
+
+	authURL, err := app.oidc.Authorize(ctx, "corp")
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	code, state := srv.signIn(t, authURL, claims)
+	if _, err := app.oidc.Callback(ctx, "corp", code, state, testClient); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if _, err := app.oidc.Callback(ctx, "corp", code, state, testClient); !errors.Is(err, application.ErrInvalidOIDCState) {
+		t.Errorf("replayed callback = %v, want %v", err, application.ErrInvalidOIDCState)
+	}
+
+	tests := []struct {
+		name string
+		// start begins the flow the state is issued for, finish presents it.
+		start  func() (string, error)
+		finish func(code, state string) error
+	}{
+		{
+			name:  "state for another provider",
+			start: func() (string, error) { return app.oidc.Authorize(ctx, "corp") },
+			finish: func(code, state string) error {
+				_, err := app.oidc.Callback(ctx, "partner", code, state, testClient)
+				return err
+			},
+		},
+		{
+			name:  "sign-in state used to link",
+			start: func() (string, error) { return app.oidc.Authorize(ctx, "corp") },
+			finish: func(code, state string) error {
+				return app.oidc.Link(ctx, bob.ID, "corp", code, state)
+			},
+		},
+		{
+			name:  "link state used to sign in",
+			start: func() (string, error) { return app.oidc.AuthorizeLink(ctx, bob.ID, "corp") },
+			finish: func(code, state string) error {
+				_, err := app.oidc.Callback(ctx, "corp", code, state, testClient)
+				return err
+			},
+		},
+		{
+			name:  "link state used by another user",
+			start: func() (string, error) { return app.oidc.AuthorizeLink(ctx, alice.ID, "corp") },
+			finish: func(code, state string) error {
+				return app.oidc.Link(ctx, bob.ID, "corp", code, state)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, err := tt.start()
+			if err != nil {
+				t.Fatalf("authorize: %v", err)
+			}
+			code, state := srv.signIn(t, authURL, claims)
+			if err := tt.finish(code, state); !errors.Is(err, application.ErrInvalidOIDCState) {
+				t.Errorf("got %v, want %v", err, application.ErrInvalidOIDCState)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrPasskeyNotFound        = errors.New("passkey not found")
+	ErrInvalidPasskey         = errors.New("passkey verification failed")
+	ErrInvalidPasskeyCeremony = errors.New("invalid or expired passkey ceremony")
+)
+
+const passkeyCeremonyTTL = 5 * time.Minute
+
+type PasskeyService struct {
+	users    domain.UserRepository
+	repo     domain.PasskeyRepository
+	provider domain.PasskeyProvider
+	mfa      *MFAService
+}
+
+func NewPasskeyService(users domain.UserRepository, repo domain.PasskeyRepository, provider domain.PasskeyProvider, mfa *MFAService) *PasskeyService {
+	return &PasskeyService{users: users, repo: repo, provider: provider, mfa: mfa}
+}
+
+func (s *PasskeyService) GetAll(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	passkeys, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get passkeys: %w", err)
+	}
+	return passkeys, nil
+}
+
+// BeginRegistration starts registering a new passkey for the user. It returns
+// the token identifying the ceremony and the options to pass to
+// navigator.credentials.create.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID string) (string, json.RawMessage, error) {
+	user, err := s.mfa.getUser(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	existing, err := s.GetAll(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	options, state, err := s.provider.BeginRegistration(user, existing)
+	if err != nil {
+		return "", nil, err
+	}
+	token, err := s.createCeremony(ctx, domain.PasskeyCeremonyRegistration, user.ID, state)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, options, nil
+}
+
+// FinishRegistration verifies the authenticator's response to the ceremony
+// started by BeginRegistration and stores the new passkey under name.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID, token, name string, response []byte) (*domain.Passkey, error) {
+	ceremony, err := s.takeCeremony(ctx, token, domain.PasskeyCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID != userID {
+		return nil, ErrInvalidPasskeyCeremony
+	}
+
+	user, err := s.mfa.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkey, err := s.provider.FinishRegistration(user, ceremony.State, response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	passkey.Name = name
+	if err := s.repo.Create(ctx, passkey); err != nil {
+		return nil, fmt.Errorf("create passkey: %w", err)
+	}
+	return passkey, nil
+}
+
+// Delete removes one of the user's passkeys. Administrators who are required
+// to use two-factor authentication cannot remove their last second factor.
+func (s *PasskeyService) Delete(ctx context.Context, userID, id string) error {
+	passkey, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get passkey: %w", err)
+	}
+	if passkey == nil || passkey.UserID != userID {
+		return ErrPasskeyNotFound
+	}
+
+	user, err := s.mfa.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.mfa.required(user) {
+		totp, passkeys, err := s.mfa.factors(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !totp && passkeys <= 1 {
+			return ErrMFARequired
+		}
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete passkey: %w", err)
+	}
+	return nil
+}
+
+// BeginLogin starts a passwordless login. The browser lets the user pick one
+// of the passkeys they registered for the instance.
+func (s *PasskeyService) BeginLogin(ctx context.Context) (string, json.RawMessage, error) {
+	options, state, err := s.provider.BeginLogin(nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	token, err := s.createCeremony(ctx, domain.PasskeyCeremonyLogin, "", state)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, options, nil
+}
+
+// PruneExpiredCeremonies deletes ceremonies that were never finished.
+func (s *PasskeyService) PruneExpiredCeremonies(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpiredCeremonies(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired passkey ceremonies: %w", err)
+	}
+	return n, nil
+}
+
+// finishLogin verifies the assertion for a ceremony started by BeginLogin and
+// returns the user who owns the passkey.
+func (s *PasskeyService) finishLogin(ctx context.Context, token string, response []byte) (*domain.User, error) {
+	ceremony, err := s.takeCeremony(ctx, token, domain.PasskeyCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey, err := verifyPasskey(ctx, s.users, s.repo, s.provider, ceremony.State, response)
+	if err != nil {
+		return nil, err
+	}
+	return s.mfa.getUser(ctx, passkey.UserID)
+}
+
+func (s *PasskeyService) createCeremony(ctx context.Context, ceremonyType domain.PasskeyCeremonyType, userID string, state []byte) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	ceremony := &domain.PasskeyCeremony{
+		TokenHash: hashToken(token),
+		Type:      ceremonyType,
+		UserID:    userID,
+		State:     state,
+		ExpiresAt: now.Add(passkeyCeremonyTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateCeremony(ctx, ceremony); err != nil {
+		return "", fmt.Errorf("create passkey ceremony: %w", err)
+	}
+	return token, nil
+}
+
+func (s *PasskeyService) takeCeremony(ctx context.Context, token string, ceremonyType domain.PasskeyCeremonyType) (*domain.PasskeyCeremony, error) {
+	ceremony, err := s.repo.TakeCeremony(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("take passkey ceremony: %w", err)
+	}
+	if ceremony == nil || ceremony.Type != ceremonyType || !time.Now().Before(ceremony.ExpiresAt) {
+		return nil, ErrInvalidPasskeyCeremony
+	}
+	return ceremony, nil
+}
+
+// verifyPasskey checks an assertion against the state of the ceremony that
+// requested it and records the new sign counter of the passkey that made it.
+func verifyPasskey(ctx context.Context, users domain.UserRepository, repo domain.PasskeyRepository, provider domain.PasskeyProvider, state, response []byte) (*domain.Passkey, error) {
+	// Errors from the lookup are ours rather than the client's, so they are
+	// kept aside and reported as such.
+	var lookupErr error
+	lookup := func(userID string) (*domain.User, []domain.Passkey, error) {
+		user, err := users.GetByID(ctx, userID)
+		if err != nil {
+			lookupErr = fmt.Errorf("get user: %w", err)
+			return nil, nil, lookupErr
+		}
+		if user == nil {
+			return nil, nil, nil
+		}
+		passkeys, err := repo.GetByUser(ctx, userID)
+		if err != nil {
+			lookupErr = fmt.Errorf("get passkeys: %w", err)
+			return nil, nil, lookupErr
+		}
+		return user, passkeys, nil
+	}
+
+	passkey, err := provider.FinishLogin(state, response, lookup)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	if err := repo.UpdateUsage(ctx, passkey.ID, passkey.SignCount, passkey.BackupState, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("update passkey usage: %w", err)
+	}
+	return passkey, nil
+}
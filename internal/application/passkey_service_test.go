@@ -0,0 +1,331 @@
+package application_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is a platform authenticator holding a single ES256
+// credential. It answers ceremonies the way a browser and authenticator
+// would together, with "none" attestation.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+	rpID         string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatalf("generate credential id: %v", err)
+	}
+	return &softAuthenticator{key: key, credentialID: id, origin: testPublicURL, rpID: testRPID}
+}
+
+// create answers the options of a registration ceremony.
+func (a *softAuthenticator) create(t *testing.T, options json.RawMessage) []byte {
+	t.Helper()
+	var opts struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatalf("decode creation options: %v", err)
+	}
+	handle, err := b64.DecodeString(opts.PublicKey.User.ID)
+	if err != nil {
+		t.Fatalf("decode user handle: %v", err)
+	}
+	a.userHandle = handle
+
+	x, y := a.key.X.FillBytes(make([]byte, 32)), a.key.Y.FillBytes(make([]byte, 32))
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: x,
+		YCoord: y,
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	authData := a.authenticatorData(flagUserPresent | flagUserVerified | flagAttested)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", opts.PublicKey.Challenge)),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// get answers the options of a login ceremony. The sign counter is advanced
+// by step first, so a step of zero replays the previous counter.
+func (a *softAuthenticator) get(t *testing.T, options json.RawMessage, step uint32) []byte {
+	t.Helper()
+	var opts struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatalf("decode request options: %v", err)
+	}
+
+	a.signCount += step
+	authData := a.authenticatorData(flagUserPresent | flagUserVerified)
+	clientData := a.clientData(t, "webauthn.get", opts.PublicKey.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.marshal(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, typ, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) marshal(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	id := b64.EncodeToString(a.credentialID)
+	body, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return body
+}
+
+// registerPasskey runs a registration ceremony for the user with a.
+func registerPasskey(t *testing.T, app *testApp, userID string, a *softAuthenticator) {
+	t.Helper()
+	ctx := context.Background()
+	token, options, err := app.passkeys.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	if _, err := app.passkeys.FinishRegistration(ctx, userID, token, "laptop", a.create(t, options)); err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+}
+
+// loginPasskey runs a login ceremony with a, advancing its counter by step.
+func loginPasskey(t *testing.T, app *testApp, a *softAuthenticator, step uint32) error {
+	t.Helper()
+	ctx := context.Background()
+	token, options, err := app.passkeys.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	tokens, err := app.auth.LoginPasskey(ctx, token, a.get(t, options, step), testClient)
+	if err == nil && tokens.AccessToken == "" {
+		t.Fatal("login returned no access token")
+	}
+	return err
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@example.com")
+	a := newSoftAuthenticator(t)
+
+	registerPasskey(t, app, user.ID, a)
+
+	passkeys, err := app.passkeys.GetAll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get passkeys: %v", err)
+	}
+	if len(passkeys) != 1 || passkeys[0].ID != b64.EncodeToString(a.credentialID) || passkeys[0].Name != "laptop" {
+		t.Fatalf("passkeys = %+v, want the registered credential", passkeys)
+	}
+
+	if err := loginPasskey(t, app, a, 1); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if err := loginPasskey(t, app, a, 1); err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	passkeys, err = app.passkeys.GetAll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get passkeys: %v", err)
+	}
+	if passkeys[0].SignCount != 2 {
+		t.Errorf("sign count = %d, want 2", passkeys[0].SignCount)
+	}
+}
+
+func TestPasskeyLoginRejectsClonedAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@example.com")
+	a := newSoftAuthenticator(t)
+	registerPasskey(t, app, user.ID, a)
+
+	if err := loginPasskey(t, app, a, 5); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// A copy of the key that lags behind the original cannot advance past the
+	// stored counter, whether it replays it or presents an older one.
+	clone := *a
+	if err := loginPasskey(t, app, &clone, 0); !errors.Is(err, application.ErrInvalidPasskey) {
+		t.Errorf("login with a repeated counter = %v, want %v", err, application.ErrInvalidPasskey)
+	}
+	clone.signCount = 1
+	if err := loginPasskey(t, app, &clone, 1); !errors.Is(err, application.ErrInvalidPasskey) {
+		t.Errorf("login with a lower counter = %v, want %v", err, application.ErrInvalidPasskey)
+	}
+
+	passkeys, err := app.passkeys.GetAll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get passkeys: %v", err)
+	}
+	if passkeys[0].SignCount != 5 {
+		t.Errorf("sign count = %d, want 5 to be kept", passkeys[0].SignCount)
+	}
+}
+
+func TestPasskeyRejectsOtherRelyingParties(t *testing.T) {
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@example.com")
+	a := newSoftAuthenticator(t)
+	registerPasskey(t, app, user.ID, a)
+
+	phished := *a
+	phished.origin = "https://harmony.example"
+	if err := loginPasskey(t, app, &phished, 1); !errors.Is(err, application.ErrInvalidPasskey) {
+		t.Errorf("login from another origin = %v, want %v", err, application.ErrInvalidPasskey)
+	}
+
+	otherRP := *a
+	otherRP.rpID = "harmony.example"
+	if err := loginPasskey(t, app, &otherRP, 1); !errors.Is(err, application.ErrInvalidPasskey) {
+		t.Errorf("login for another relying party = %v, want %v", err, application.ErrInvalidPasskey)
+	}
+}
+
+func TestPasskeyRejectsUnknownCredential(t *testing.T) {
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@example.com")
+	registerPasskey(t, app, user.ID, newSoftAuthenticator(t))
+
+	// Same user handle, but a key the server never saw.
+	stranger := newSoftAuthenticator(t)
+	stranger.userHandle = []byte(user.ID)
+	if err := loginPasskey(t, app, stranger, 1); !errors.Is(err, application.ErrInvalidPasskey) {
+		t.Errorf("login with an unregistered credential = %v, want %v", err, application.ErrInvalidPasskey)
+	}
+}
+
+func TestPasskeyCeremoniesAreSingleUseAndBound(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	alice := app.register(t, "alice", "alice@example.com")
+	bob := app.register(t, "bob", "bob@example.com")
+	a := newSoftAuthenticator(t)
+
+	token, options, err := app.passkeys.BeginRegistration(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	response := a.create(t, options)
+	if _, err := app.passkeys.FinishRegistration(ctx, bob.ID, token, "laptop", response); !errors.Is(err, application.ErrInvalidPasskeyCeremony) {
+		t.Errorf("finish another user's registration = %v, want %v", err, application.ErrInvalidPasskeyCeremony)
+	}
+	// The failed attempt used the ceremony up.
+	if _, err := app.passkeys.FinishRegistration(ctx, alice.ID, token, "laptop", response); !errors.Is(err, application.ErrInvalidPasskeyCeremony) {
+		t.Errorf("finish a used registration = %v, want %v", err, application.ErrInvalidPasskeyCeremony)
+	}
+
+	registerPasskey(t, app, alice.ID, a)
+	token, options, err = app.passkeys.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	assertion := a.get(t, options, 1)
+	if _, err := app.auth.LoginPasskey(ctx, token, assertion, testClient); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := app.auth.LoginPasskey(ctx, token, assertion, testClient); !errors.Is(err, application.ErrInvalidPasskeyCeremony) {
+		t.Errorf("replayed login = %v, want %v", err, application.ErrInvalidPasskeyCeremony)
+	}
+}
@@ -0,0 +1,191 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+)
+
+type PasswordService struct {
+	users     domain.UserRepository
+	resets    domain.PasswordResetRepository
+	auth      *AuthService
+	sessions  *SessionService
+	mailer    domain.Mailer
+	resetTTL  time.Duration
+	publicURL string
+}
+
+func NewPasswordService(users domain.UserRepository, resets domain.PasswordResetRepository, auth *AuthService, sessions *SessionService, mailer domain.Mailer, resetTTL time.Duration, publicURL string) *PasswordService {
+	return &PasswordService{
+		users:     users,
+		resets:    resets,
+		auth:      auth,
+		sessions:  sessions,
+		mailer:    mailer,
+		resetTTL:  resetTTL,
+		publicURL: publicURL,
+	}
+}
+
+// Change sets a new password after checking the current one. Every existing
+// session is signed out, so a fresh one is opened for the client and its
+// tokens are returned.
+func (s *PasswordService) Change(ctx context.Context, userID, current, password string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)); err != nil {
+		return nil, ErrIncorrectPassword
+	}
+
+	if err := s.setPassword(ctx, user.ID, password); err != nil {
+		return nil, err
+	}
+
+	// Reload the user to pick up the token version bumped above.
+	user, err = s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	return s.auth.openSession(ctx, user, client)
+}
+
+// RequestReset emails a password reset link to the account registered with
+// email. Unknown addresses are ignored without error so that the endpoint does
+// not reveal which emails have an account.
+func (s *PasswordService) RequestReset(ctx context.Context, email string) error {
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user == nil || user.Status != domain.UserStatusActive || user.IsBot {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the latest link works.
+	if err := s.resets.DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete password resets: %w", err)
+	}
+
+	now := time.Now().UTC()
+	reset := &domain.PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.resetTTL),
+		CreatedAt: now,
+	}
+	if err := s.resets.Create(ctx, reset); err != nil {
+		return fmt.Errorf("create password reset: %w", err)
+	}
+
+	mail := domain.Mail{
+		To:      user.Email,
+		Subject: "Reset your Harmony password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Harmony account. If it was you,
+open the link below to choose a new password. It expires in %d minutes.
+
+%s/reset-password?token=%s
+
+If you did not ask for this, you can ignore this email.
+`, user.Username, int(s.resetTTL.Minutes()), s.publicURL, token),
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("send password reset: %w", err)
+	}
+	return nil
+}
+
+// Reset sets a new password using a token from a reset email and signs the
+// user out of every session.
+func (s *PasswordService) Reset(ctx context.Context, token, password string) error {
+	hash := hashToken(token)
+	reset, err := s.resets.GetByHash(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("get password reset: %w", err)
+	}
+	if reset == nil {
+		return ErrInvalidResetToken
+	}
+
+	ok, err := s.resets.Use(ctx, hash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("use password reset: %w", err)
+	}
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	return s.setPassword(ctx, reset.UserID, password)
+}
+
+// PruneExpired deletes reset tokens that expired or were used.
+func (s *PasswordService) PruneExpired(ctx context.Context) (int64, error) {
+	n, err := s.resets.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired password resets: %w", err)
+	}
+	return n, nil
+}
+
+// setPassword stores the new password and revokes every session, token and
+// pending reset link issued with the old one.
+func (s *PasswordService) setPassword(ctx context.Context, userID, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, userID, hash); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	if err := s.resets.DeleteByUser(ctx, userID); err != nil {
+		return fmt.Errorf("delete password resets: %w", err)
+	}
+	return s.sessions.RevokeAll(ctx, userID)
+}
+
+// PasswordVerifier checks credentials against the password hashes of local
+// accounts, using the email address as the login.
+type PasswordVerifier struct {
+	users domain.UserRepository
+}
+
+func NewPasswordVerifier(users domain.UserRepository) *PasswordVerifier {
+	return &PasswordVerifier{users: users}
+}
+
+func (v *PasswordVerifier) VerifyCredentials(ctx context.Context, login, password string) (*domain.User, error) {
+	user, err := v.users.GetByEmail(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	// Bots authenticate with their token only.
+	if user == nil || user.IsBot {
+		return nil, nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
@@ -0,0 +1,240 @@
+package application_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/adapter/mail"
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// smtpMessage is a message accepted by smtpServer.
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer is a minimal SMTP relay on a local port. It accepts every
+// message without authentication or STARTTLS, which net/smtp only uses when
+// offered.
+type smtpServer struct {
+	listener net.Listener
+	messages chan smtpMessage
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpServer{listener: l, messages: make(chan smtpMessage, 16)}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// mailer returns the SMTP mailer adapter pointed at the server.
+func (s *smtpServer) mailer(t *testing.T) domain.Mailer {
+	t.Helper()
+	addr := s.listener.Addr().(*net.TCPAddr)
+	m, err := mail.NewSMTPMailer(addr.IP.String(), addr.Port, "", "", "Harmony <noreply@harmony.test>")
+	if err != nil {
+		t.Fatalf("create smtp mailer: %v", err)
+	}
+	return m
+}
+
+// next waits for the next message the server accepted.
+func (s *smtpServer) next(t *testing.T) smtpMessage {
+	t.Helper()
+	select {
+	case m := <-s.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was delivered")
+		return smtpMessage{}
+	}
+}
+
+func (s *smtpServer) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case m := <-s.messages:
+		t.Fatalf("unexpected message to %v", m.To)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(code int, text string) {
+		conn.Write([]byte(strconv.Itoa(code) + " " + text + "\r\n"))
+	}
+
+	reply(220, "harmony.test ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply(250, "harmony.test")
+		case "MAIL":
+			msg = smtpMessage{From: strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")}
+			reply(250, "OK")
+		case "RCPT":
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.messages <- msg
+			reply(250, "queued")
+		case "RSET", "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+var resetLinkPattern = regexp.MustCompile(regexp.QuoteMeta(testPublicURL) + `/reset-password\?token=([A-Za-z0-9_-]+)`)
+
+// resetToken extracts the token from the link in a reset email.
+func resetToken(t *testing.T, m smtpMessage) string {
+	t.Helper()
+	match := resetLinkPattern.FindStringSubmatch(m.Data)
+	if match == nil {
+		t.Fatalf("no reset link in message:\n%s", m.Data)
+	}
+	return match[1]
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	smtp := newSMTPServer(t)
+	app := newTestApp(t, testOptions{mailer: smtp.mailer(t)})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	login, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	// Registering sent a verification email first.
+	smtp.next(t)
+
+	if err := app.passwords.RequestReset(ctx, user.Email); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	m := smtp.next(t)
+	if len(m.To) != 1 || m.To[0] != user.Email {
+		t.Fatalf("reset sent to %v, want %s", m.To, user.Email)
+	}
+	if !strings.Contains(m.Data, "Subject: Reset your Harmony password") {
+		t.Errorf("unexpected message:\n%s", m.Data)
+	}
+	token := resetToken(t, m)
+
+	if err := app.passwords.Reset(ctx, token, "new staple battery"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	if _, err := app.auth.Login(ctx, user.Email, "correct horse battery", testClient); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("login with the old password: got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := app.auth.Login(ctx, user.Email, "new staple battery", testClient); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	if _, err := app.auth.Refresh(ctx, login.Tokens.RefreshToken, testClient); err == nil {
+		t.Error("refresh token issued before the reset still works")
+	}
+	if len(app.events.terminatedSessions) == 0 {
+		t.Error("gateway connections of the revoked sessions were not closed")
+	}
+
+	t.Run("link is single use", func(t *testing.T) {
+		if err := app.passwords.Reset(ctx, token, "another password"); !errors.Is(err, application.ErrInvalidResetToken) {
+			t.Errorf("got %v, want ErrInvalidResetToken", err)
+		}
+	})
+}
+
+func TestPasswordResetOnlyLatestLinkWorks(t *testing.T) {
+	ctx := context.Background()
+	smtp := newSMTPServer(t)
+	app := newTestApp(t, testOptions{mailer: smtp.mailer(t)})
+	user := app.register(t, "alice", "alice@harmony.test")
+	smtp.next(t)
+
+	var tokens []string
+	for range 2 {
+		if err := app.passwords.RequestReset(ctx, user.Email); err != nil {
+			t.Fatalf("request reset: %v", err)
+		}
+		tokens = append(tokens, resetToken(t, smtp.next(t)))
+	}
+
+	if err := app.passwords.Reset(ctx, tokens[0], "new staple battery"); !errors.Is(err, application.ErrInvalidResetToken) {
+		t.Errorf("first link: got %v, want ErrInvalidResetToken", err)
+	}
+	if err := app.passwords.Reset(ctx, tokens[1], "new staple battery"); err != nil {
+		t.Errorf("latest link: %v", err)
+	}
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	smtp := newSMTPServer(t)
+	app := newTestApp(t, testOptions{mailer: smtp.mailer(t)})
+
+	// Unknown addresses succeed silently so that accounts cannot be probed.
+	if err := app.passwords.RequestReset(context.Background(), "nobody@harmony.test"); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	smtp.expectNone(t)
+}
+
+func TestPasswordResetInvalidToken(t *testing.T) {
+	app := newTestApp(t, testOptions{})
+
+	if err := app.passwords.Reset(context.Background(), "not-a-token", "new staple battery"); !errors.Is(err, application.ErrInvalidResetToken) {
+		t.Errorf("got %v, want ErrInvalidResetToken", err)
+	}
+}
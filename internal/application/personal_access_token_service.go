@@ -0,0 +1,172 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	ErrInvalidTokenScope           = errors.New("invalid token scope")
+	ErrTooManyPersonalAccessTokens = errors.New("too many personal access tokens")
+)
+
+const maxPersonalAccessTokens = 50
+
+// lastUsedResolution bounds how often the last use of a token is written, so
+// that scripts calling the API in a loop do not cost a write per request.
+const lastUsedResolution = time.Minute
+
+type PersonalAccessTokenService struct {
+	repo  domain.PersonalAccessTokenRepository
+	users domain.UserRepository
+}
+
+func NewPersonalAccessTokenService(repo domain.PersonalAccessTokenRepository, users domain.UserRepository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{repo: repo, users: users}
+}
+
+func (s *PersonalAccessTokenService) GetAll(ctx context.Context, userID string) ([]domain.PersonalAccessToken, error) {
+	tokens, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get personal access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+type CreatePersonalAccessTokenInput struct {
+	Name   string
+	Scopes []domain.TokenScope
+	// ExpiresIn is how long the token lasts, or zero for a token that never
+	// expires.
+	ExpiresIn time.Duration
+}
+
+// Create issues a token for the user and returns it along with its secret,
+// which is only known at this point. The admin scope is reserved to
+// administrators.
+func (s *PersonalAccessTokenService) Create(ctx context.Context, userID string, input CreatePersonalAccessTokenInput) (*domain.PersonalAccessToken, string, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, "", ErrUserNotFound
+	}
+
+	if len(input.Scopes) == 0 {
+		return nil, "", ErrInvalidTokenScope
+	}
+	for _, scope := range input.Scopes {
+		if !scope.Valid() {
+			return nil, "", ErrInvalidTokenScope
+		}
+		if scope == domain.ScopeAdmin && !user.IsAdmin {
+			return nil, "", ErrForbidden
+		}
+	}
+	scopes := slices.Clone(input.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	existing, err := s.GetAll(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(existing) >= maxPersonalAccessTokens {
+		return nil, "", ErrTooManyPersonalAccessTokens
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret = domain.PersonalAccessTokenPrefix + secret
+
+	now := time.Now().UTC()
+	token := &domain.PersonalAccessToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(input.Name),
+		TokenHash: hashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	if input.ExpiresIn > 0 {
+		expiresAt := now.Add(input.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.Create(ctx, token); err != nil {
+		return nil, "", fmt.Errorf("create personal access token: %w", err)
+	}
+	return token, secret, nil
+}
+
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, userID, id string) error {
+	token, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get personal access token: %w", err)
+	}
+	if token == nil || token.UserID != userID {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete personal access token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAll deletes every token of the user. Tokens are meant to outlive
+// sessions, so signing out everywhere leaves them alone.
+func (s *PersonalAccessTokenService) RevokeAll(ctx context.Context, userID string) error {
+	if err := s.repo.DeleteByUser(ctx, userID); err != nil {
+		return fmt.Errorf("delete personal access tokens: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns the token matching secret, or nil when there is none,
+// it expired or its user can no longer sign in.
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, secret string) (*domain.PersonalAccessToken, error) {
+	token, err := s.repo.GetByHash(ctx, hashToken(secret))
+	if err != nil {
+		return nil, fmt.Errorf("get personal access token: %w", err)
+	}
+	now := time.Now().UTC()
+	if token == nil || (token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)) {
+		return nil, nil
+	}
+
+	user, err := s.users.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil || user.Status != domain.UserStatusActive {
+		return nil, nil
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		if err := s.repo.UpdateLastUsed(ctx, token.ID, now); err != nil {
+			return nil, fmt.Errorf("update personal access token usage: %w", err)
+		}
+		token.LastUsedAt = &now
+	}
+	return token, nil
+}
+
+func (s *PersonalAccessTokenService) PruneExpired(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpired(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired personal access tokens: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,39 @@
+package application_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+func TestPersonalAccessTokensOutliveSessions(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@harmony.test")
+
+	_, secret, err := app.accessTokens.Create(ctx, user.ID, application.CreatePersonalAccessTokenInput{
+		Name:   "ci",
+		Scopes: []domain.TokenScope{domain.ScopeMessagesRead},
+	})
+	if err != nil {
+		t.Fatalf("create personal access token: %v", err)
+	}
+
+	// Changing the password signs the user out everywhere, but scripts using
+	// the token keep working.
+	if _, err := app.passwords.Change(ctx, user.ID, "correct horse battery", "new staple battery", testClient); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if got, err := app.accessTokens.Authenticate(ctx, secret); err != nil || got == nil {
+		t.Fatalf("authenticate after a password change = %v, %v, want the token", got, err)
+	}
+
+	if err := app.accessTokens.RevokeAll(ctx, user.ID); err != nil {
+		t.Fatalf("revoke all: %v", err)
+	}
+	if got, err := app.accessTokens.Authenticate(ctx, secret); err != nil || got != nil {
+		t.Errorf("authenticate after revoking all tokens = %v, %v, want nothing", got, err)
+	}
+}
@@ -0,0 +1,272 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrRelationshipSelf      = errors.New("cannot add or block yourself")
+	ErrAlreadyFriends        = errors.New("already friends")
+	ErrFriendRequestExists   = errors.New("friend request already sent")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrNotFriends            = errors.New("not friends")
+	ErrRelationshipBlocked   = errors.New("a block prevents this action")
+	ErrNotBlocked            = errors.New("user is not blocked")
+	ErrFriendRequestBot      = errors.New("bots cannot be added as friends")
+)
+
+type RelationshipService struct {
+	repo   domain.RelationshipRepository
+	users  domain.UserRepository
+	events domain.EventPublisher
+}
+
+func NewRelationshipService(repo domain.RelationshipRepository, users domain.UserRepository, events domain.EventPublisher) *RelationshipService {
+	return &RelationshipService{repo: repo, users: users, events: events}
+}
+
+func (s *RelationshipService) GetFriends(ctx context.Context, userID string) ([]domain.Relationship, error) {
+	return s.getByUser(ctx, userID, domain.RelationshipFriend)
+}
+
+// GetFriendRequests returns both the requests the user received and the ones
+// they sent that are still pending.
+func (s *RelationshipService) GetFriendRequests(ctx context.Context, userID string) ([]domain.Relationship, error) {
+	return s.getByUser(ctx, userID, domain.RelationshipPendingIncoming, domain.RelationshipPendingOutgoing)
+}
+
+func (s *RelationshipService) GetBlocks(ctx context.Context, userID string) ([]domain.Relationship, error) {
+	return s.getByUser(ctx, userID, domain.RelationshipBlocked)
+}
+
+// SendFriendRequest asks targetID to become friends with userID. Sending a
+// request to someone who already asked accepts theirs.
+func (s *RelationshipService) SendFriendRequest(ctx context.Context, userID, targetID string) (*domain.Relationship, error) {
+	target, err := s.checkTarget(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target.IsBot {
+		return nil, ErrFriendRequestBot
+	}
+
+	mine, theirs, err := s.getPair(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if isBlock(mine) || isBlock(theirs) {
+		return nil, ErrRelationshipBlocked
+	}
+
+	if mine != nil {
+		switch mine.Type {
+		case domain.RelationshipFriend:
+			return nil, ErrAlreadyFriends
+		case domain.RelationshipPendingOutgoing:
+			return nil, ErrFriendRequestExists
+		case domain.RelationshipPendingIncoming:
+			return s.putPair(ctx, userID, targetID, domain.RelationshipFriend, domain.RelationshipFriend)
+		}
+	}
+	return s.putPair(ctx, userID, targetID, domain.RelationshipPendingOutgoing, domain.RelationshipPendingIncoming)
+}
+
+func (s *RelationshipService) AcceptFriendRequest(ctx context.Context, userID, targetID string) (*domain.Relationship, error) {
+	if _, err := s.getOfType(ctx, userID, targetID, domain.RelationshipPendingIncoming, ErrFriendRequestNotFound); err != nil {
+		return nil, err
+	}
+	return s.putPair(ctx, userID, targetID, domain.RelationshipFriend, domain.RelationshipFriend)
+}
+
+func (s *RelationshipService) DeclineFriendRequest(ctx context.Context, userID, targetID string) error {
+	if _, err := s.getOfType(ctx, userID, targetID, domain.RelationshipPendingIncoming, ErrFriendRequestNotFound); err != nil {
+		return err
+	}
+	return s.deletePair(ctx, userID, targetID)
+}
+
+func (s *RelationshipService) CancelFriendRequest(ctx context.Context, userID, targetID string) error {
+	if _, err := s.getOfType(ctx, userID, targetID, domain.RelationshipPendingOutgoing, ErrFriendRequestNotFound); err != nil {
+		return err
+	}
+	return s.deletePair(ctx, userID, targetID)
+}
+
+func (s *RelationshipService) RemoveFriend(ctx context.Context, userID, targetID string) error {
+	if _, err := s.getOfType(ctx, userID, targetID, domain.RelationshipFriend, ErrNotFriends); err != nil {
+		return err
+	}
+	return s.deletePair(ctx, userID, targetID)
+}
+
+// Block blocks targetID for userID, ending any friendship or pending request
+// between them. A block the target placed on the user is left in place.
+func (s *RelationshipService) Block(ctx context.Context, userID, targetID string) (*domain.Relationship, error) {
+	if _, err := s.checkTarget(ctx, userID, targetID); err != nil {
+		return nil, err
+	}
+
+	_, theirs, err := s.getPair(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if theirs != nil && !isBlock(theirs) {
+		if err := s.repo.Delete(ctx, targetID, userID); err != nil {
+			return nil, fmt.Errorf("delete relationship: %w", err)
+		}
+		s.events.Publish([]string{targetID}, domain.Event{Type: domain.EventRelationshipRemove, Data: theirs})
+	}
+
+	block := &domain.Relationship{
+		UserID:    userID,
+		TargetID:  targetID,
+		Type:      domain.RelationshipBlocked,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.Put(ctx, block); err != nil {
+		return nil, fmt.Errorf("put relationship: %w", err)
+	}
+
+	s.events.Publish([]string{userID}, domain.Event{Type: domain.EventRelationshipAdd, Data: block})
+	return block, nil
+}
+
+func (s *RelationshipService) Unblock(ctx context.Context, userID, targetID string) error {
+	block, err := s.getOfType(ctx, userID, targetID, domain.RelationshipBlocked, ErrNotBlocked)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID, targetID); err != nil {
+		return fmt.Errorf("delete relationship: %w", err)
+	}
+
+	s.events.Publish([]string{userID}, domain.Event{Type: domain.EventRelationshipRemove, Data: block})
+	return nil
+}
+
+func (s *RelationshipService) getByUser(ctx context.Context, userID string, types ...domain.RelationshipType) ([]domain.Relationship, error) {
+	relationships, err := s.repo.GetByUser(ctx, userID, types...)
+	if err != nil {
+		return nil, fmt.Errorf("get relationships: %w", err)
+	}
+	return relationships, nil
+}
+
+func (s *RelationshipService) checkTarget(ctx context.Context, userID, targetID string) (*domain.User, error) {
+	if userID == targetID {
+		return nil, ErrRelationshipSelf
+	}
+
+	target, err := s.users.GetByID(ctx, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if target == nil || target.Status != domain.UserStatusActive {
+		return nil, ErrUserNotFound
+	}
+	return target, nil
+}
+
+// getPair returns the user's relationship with the target and the target's
+// relationship with the user.
+func (s *RelationshipService) getPair(ctx context.Context, userID, targetID string) (*domain.Relationship, *domain.Relationship, error) {
+	mine, err := s.repo.Get(ctx, userID, targetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get relationship: %w", err)
+	}
+	theirs, err := s.repo.Get(ctx, targetID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get relationship: %w", err)
+	}
+	return mine, theirs, nil
+}
+
+func (s *RelationshipService) getOfType(ctx context.Context, userID, targetID string, relType domain.RelationshipType, notFound error) (*domain.Relationship, error) {
+	rel, err := s.repo.Get(ctx, userID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("get relationship: %w", err)
+	}
+	if rel == nil || rel.Type != relType {
+		return nil, notFound
+	}
+	return rel, nil
+}
+
+// putPair stores both sides of a relationship and returns the user's side.
+func (s *RelationshipService) putPair(ctx context.Context, userID, targetID string, mineType, theirsType domain.RelationshipType) (*domain.Relationship, error) {
+	now := time.Now().UTC()
+	mine := &domain.Relationship{UserID: userID, TargetID: targetID, Type: mineType, CreatedAt: now}
+	theirs := &domain.Relationship{UserID: targetID, TargetID: userID, Type: theirsType, CreatedAt: now}
+
+	for _, rel := range []*domain.Relationship{mine, theirs} {
+		if err := s.repo.Put(ctx, rel); err != nil {
+			return nil, fmt.Errorf("put relationship: %w", err)
+		}
+		s.events.Publish([]string{rel.UserID}, domain.Event{Type: domain.EventRelationshipAdd, Data: rel})
+	}
+	return mine, nil
+}
+
+func (s *RelationshipService) deletePair(ctx context.Context, userID, targetID string) error {
+	mine, theirs, err := s.getPair(ctx, userID, targetID)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range []*domain.Relationship{mine, theirs} {
+		if rel == nil {
+			continue
+		}
+		if err := s.repo.Delete(ctx, rel.UserID, rel.TargetID); err != nil {
+			return fmt.Errorf("delete relationship: %w", err)
+		}
+		s.events.Publish([]string{rel.UserID}, domain.Event{Type: domain.EventRelationshipRemove, Data: rel})
+	}
+	return nil
+}
+
+func isBlock(rel *domain.Relationship) bool {
+	return rel != nil && rel.Type == domain.RelationshipBlocked
+}
+
+// blockedBetween reports whether either user has blocked the other.
+func blockedBetween(ctx context.Context, repo domain.RelationshipRepository, userID, otherID string) (bool, error) {
+	for _, pair := range [][2]string{{userID, otherID}, {otherID, userID}} {
+		rel, err := repo.Get(ctx, pair[0], pair[1])
+		if err != nil {
+			return false, fmt.Errorf("get relationship: %w", err)
+		}
+		if isBlock(rel) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hideBlockedMessages withholds the content of messages written by users the
+// viewer has blocked. System messages are left untouched.
+func hideBlockedMessages(ctx context.Context, repo domain.RelationshipRepository, viewerID string, messages []domain.Message) error {
+	blocks, err := repo.GetByUser(ctx, viewerID, domain.RelationshipBlocked)
+	if err != nil {
+		return fmt.Errorf("get blocks: %w", err)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	blocked := make(map[string]bool, len(blocks))
+	for _, b := range blocks {
+		blocked[b.TargetID] = true
+	}
+	for i := range messages {
+		if messages[i].Type == domain.MessageTypeDefault && blocked[messages[i].AuthorID] {
+			messages[i].Hide()
+		}
+	}
+	return nil
+}
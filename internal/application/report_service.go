@@ -0,0 +1,213 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrReportNotFound   = errors.New("report not found")
+	ErrAlreadyReported  = errors.New("an open report already exists for this target")
+	ErrCannotReportSelf = errors.New("cannot report yourself")
+	ErrReportClosed     = errors.New("report is already closed")
+)
+
+type CreateReportInput struct {
+	TargetType domain.ReportTargetType
+	UserID     string
+	ChannelID  string
+	MessageID  string
+	Category   domain.ReportCategory
+	Reason     string
+}
+
+type ReportService struct {
+	repo     domain.ReportRepository
+	messages domain.MessageRepository
+	channels domain.ChannelRepository
+	dms      domain.DMChannelRepository
+	users    domain.UserRepository
+	audit    *AuditService
+}
+
+func NewReportService(repo domain.ReportRepository, messages domain.MessageRepository, channels domain.ChannelRepository, dms domain.DMChannelRepository, users domain.UserRepository, audit *AuditService) *ReportService {
+	return &ReportService{repo: repo, messages: messages, channels: channels, dms: dms, users: users, audit: audit}
+}
+
+// Create files a report about a message or a user, preserving a snapshot of
+// the reported content. Messages can only be reported by users who can read
+// them.
+func (s *ReportService) Create(ctx context.Context, reporterID string, input CreateReportInput) (*domain.Report, error) {
+	now := time.Now().UTC()
+	report := &domain.Report{
+		ID:         uuid.New().String(),
+		ReporterID: reporterID,
+		TargetType: input.TargetType,
+		Category:   input.Category,
+		Reason:     input.Reason,
+		Status:     domain.ReportStatusOpen,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	switch input.TargetType {
+	case domain.ReportTargetMessage:
+		message, err := s.messages.GetByID(ctx, input.MessageID)
+		if err != nil {
+			return nil, fmt.Errorf("get message: %w", err)
+		}
+		if message == nil || message.ChannelID != input.ChannelID || (message.AuthorID == "" && message.Webhook == nil) {
+			return nil, ErrMessageNotFound
+		}
+		if err := s.checkCanRead(ctx, reporterID, message.ChannelID); err != nil {
+			return nil, err
+		}
+		report.TargetUserID = message.AuthorID
+		report.MessageID = message.ID
+		report.ChannelID = message.ChannelID
+		report.Snapshot = domain.ReportSnapshot{
+			AuthorID:  message.AuthorID,
+			Content:   message.Content,
+			CreatedAt: message.CreatedAt,
+		}
+		if message.Webhook != nil {
+			report.Snapshot.WebhookID = message.Webhook.ID
+			report.Snapshot.Username = message.Webhook.Username
+		}
+	case domain.ReportTargetUser:
+		report.TargetUserID = input.UserID
+		report.Snapshot = domain.ReportSnapshot{AuthorID: input.UserID, CreatedAt: now}
+	}
+
+	if report.TargetUserID == reporterID {
+		return nil, ErrCannotReportSelf
+	}
+
+	if report.TargetUserID != "" {
+		user, err := s.users.GetByID(ctx, report.TargetUserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		report.Snapshot.Username = user.Username
+	}
+
+	exists, err := s.repo.HasOpen(ctx, reporterID, report.TargetUserID, report.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("check open report: %w", err)
+	}
+	if exists {
+		return nil, ErrAlreadyReported
+	}
+
+	if err := s.repo.Create(ctx, report); err != nil {
+		return nil, fmt.Errorf("create report: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("category", nil, string(report.Category))
+	if report.TargetUserID != "" {
+		diff.add("targetUserId", nil, report.TargetUserID)
+	}
+	if report.Snapshot.WebhookID != "" {
+		diff.add("webhookId", nil, report.Snapshot.WebhookID)
+	}
+	if report.MessageID != "" {
+		diff.add("messageId", nil, report.MessageID)
+	}
+	if err := s.audit.Record(ctx, domain.AuditReportCreate, domain.AuditTargetReport, report.ID, diff); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetQueue returns reports with the given status, or every report still
+// awaiting a decision when status is empty.
+func (s *ReportService) GetQueue(ctx context.Context, status domain.ReportStatus) ([]domain.Report, error) {
+	statuses := []domain.ReportStatus{domain.ReportStatusOpen, domain.ReportStatusEscalated}
+	if status != "" {
+		statuses = []domain.ReportStatus{status}
+	}
+
+	reports, err := s.repo.GetByStatus(ctx, statuses...)
+	if err != nil {
+		return nil, fmt.Errorf("get reports: %w", err)
+	}
+	return reports, nil
+}
+
+func (s *ReportService) Resolve(ctx context.Context, id, moderatorID, note string) (*domain.Report, error) {
+	return s.transition(ctx, id, moderatorID, note, domain.ReportStatusResolved, domain.AuditReportResolve)
+}
+
+func (s *ReportService) Dismiss(ctx context.Context, id, moderatorID, note string) (*domain.Report, error) {
+	return s.transition(ctx, id, moderatorID, note, domain.ReportStatusDismissed, domain.AuditReportDismiss)
+}
+
+// Escalate keeps the report in the queue but marks it for senior review.
+func (s *ReportService) Escalate(ctx context.Context, id, moderatorID, note string) (*domain.Report, error) {
+	return s.transition(ctx, id, moderatorID, note, domain.ReportStatusEscalated, domain.AuditReportEscalate)
+}
+
+// checkCanRead returns ErrMessageNotFound unless the channel is one of the
+// community's, which every member can read, or a direct message channel the
+// user takes part in.
+func (s *ReportService) checkCanRead(ctx context.Context, userID, channelID string) error {
+	channel, err := s.channels.GetByID(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("get channel: %w", err)
+	}
+	if channel != nil {
+		return nil
+	}
+
+	dm, err := s.dms.GetByID(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("get dm channel: %w", err)
+	}
+	if dm == nil || !slices.Contains(dm.RecipientIDs, userID) {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+func (s *ReportService) transition(ctx context.Context, id, moderatorID, note string, status domain.ReportStatus, action domain.AuditAction) (*domain.Report, error) {
+	report, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get report: %w", err)
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	if report.Status.Closed() {
+		return nil, ErrReportClosed
+	}
+
+	var diff auditDiff
+	diff.add("status", string(report.Status), string(status))
+	if note != "" {
+		diff.add("note", report.Note, note)
+		report.Note = note
+	}
+
+	report.Status = status
+	report.ModeratorID = moderatorID
+	report.UpdatedAt = time.Now().UTC()
+
+	if err := s.repo.Update(ctx, report); err != nil {
+		return nil, fmt.Errorf("update report: %w", err)
+	}
+	if err := s.audit.Record(ctx, action, domain.AuditTargetReport, report.ID, diff); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
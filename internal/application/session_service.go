@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionService struct {
+	repo       domain.SessionRepository
+	versions   domain.TokenVersionStore
+	terminator domain.SessionTerminator
+}
+
+func NewSessionService(repo domain.SessionRepository, versions domain.TokenVersionStore, terminator domain.SessionTerminator) *SessionService {
+	return &SessionService{repo: repo, versions: versions, terminator: terminator}
+}
+
+// GetAll returns the user's active sessions, most recently used first.
+func (s *SessionService) GetAll(ctx context.Context, userID string) ([]domain.Session, error) {
+	sessions, err := s.repo.GetActiveByUser(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke signs the user out of one of their sessions.
+func (s *SessionService) Revoke(ctx context.Context, userID, id string) error {
+	session, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get session: %w", err)
+	}
+	if session == nil || session.UserID != userID || !session.Active(time.Now().UTC()) {
+		return ErrSessionNotFound
+	}
+	return s.revoke(ctx, id)
+}
+
+// RevokeOthers signs the user out of every session except the current one
+// and returns how many were revoked.
+func (s *SessionService) RevokeOthers(ctx context.Context, userID, currentID string) (int, error) {
+	sessions, err := s.repo.GetActiveByUser(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("get sessions: %w", err)
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == currentID {
+			continue
+		}
+		if err := s.revoke(ctx, session.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// RevokeAll signs the user out everywhere. Bumping the token version rejects
+// their outstanding access tokens right away instead of when they expire.
+func (s *SessionService) RevokeAll(ctx context.Context, userID string) error {
+	if err := s.versions.Bump(ctx, userID); err != nil {
+		return fmt.Errorf("bump token version: %w", err)
+	}
+
+	sessions, err := s.repo.GetActiveByUser(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("get sessions: %w", err)
+	}
+	for _, session := range sessions {
+		if err := s.revoke(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revoke invalidates the session's refresh tokens and drops the gateway
+// connections opened with its access tokens.
+func (s *SessionService) revoke(ctx context.Context, id string) error {
+	if err := s.repo.Revoke(ctx, id, time.Now().UTC()); err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	s.terminator.TerminateSession(id)
+	return nil
+}
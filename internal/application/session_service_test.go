@@ -0,0 +1,95 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/tartine-studio/harmony-server/internal/application"
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+// login signs the user in with the password register gives them.
+func (a *testApp) login(t *testing.T, user *domain.User) *domain.TokenPair {
+	t.Helper()
+	result, err := a.auth.Login(context.Background(), user.Email, "correct horse battery", testClient)
+	if err != nil {
+		t.Fatalf("login as %s: %v", user.Email, err)
+	}
+	return result.Tokens
+}
+
+func TestRefreshRotatesRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@harmony.test")
+	pair := app.login(t, user)
+
+	for range 3 {
+		next, err := app.auth.Refresh(ctx, pair.RefreshToken, testClient)
+		if err != nil {
+			t.Fatalf("refresh: %v", err)
+		}
+		if next.RefreshToken == pair.RefreshToken || next.AccessToken == pair.AccessToken {
+			t.Fatal("refresh returned the tokens it was given")
+		}
+		pair = next
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t, testOptions{})
+	user := app.register(t, "alice", "alice@harmony.test")
+	stolen := app.login(t, user)
+
+	rotated, err := app.auth.Refresh(ctx, stolen.RefreshToken, testClient)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	// Whoever replays the old token, the session it belongs to is
+	// compromised, so the legitimate client is signed out too.
+	if _, err := app.auth.Refresh(ctx, stolen.RefreshToken, testClient); !errors.Is(err, application.ErrInvalidToken) {
+		t.Fatalf("replay the old refresh token = %v, want %v", err, application.ErrInvalidToken)
+	}
+	if _, err := app.auth.Refresh(ctx, rotated.RefreshToken, testClient); !errors.Is(err, application.ErrInvalidToken) {
+		t.Errorf("refresh after a replay = %v, want %v", err, application.ErrInvalidToken)
+	}
+
+	sessions, err := app.sessions.GetAll(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get sessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("active sessions after a replay = %d, want 0", len(sessions))
+	}
+	if len(app.events.terminatedSessions) != 1 {
+		t.Errorf("terminated sessions = %v, want the replayed one", app.events.terminatedSessions)
+	}
+}
+
+// recordingGateway stands in for the real-time gateway and keeps the
+// connections it was asked to close.
+type recordingGateway struct {
+	mu                 sync.Mutex
+	terminatedSessions []string
+	terminatedUsers    []string
+}
+
+func (g *recordingGateway) Publish([]string, domain.Event) {}
+
+func (g *recordingGateway) Online(string) bool { return false }
+
+func (g *recordingGateway) TerminateSession(sessionID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.terminatedSessions = append(g.terminatedSessions, sessionID)
+}
+
+func (g *recordingGateway) TerminateUser(userID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.terminatedUsers = append(g.terminatedUsers, userID)
+}
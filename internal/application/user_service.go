@@ -9,14 +9,33 @@ import (
 	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserNotPending    = errors.New("user is not awaiting approval")
+	ErrUserNotDisabled   = errors.New("user is not disabled")
+	ErrUserDisabled      = errors.New("user is already disabled")
+	ErrCannotDisableSelf = errors.New("cannot disable your own account")
+)
+
+// UpdateUserInput holds the profile fields to change. Empty values leave the
+// corresponding field untouched.
+type UpdateUserInput struct {
+	Username string
+	Email    string
+	DMPolicy domain.DMPolicy
+}
 
 type UserService struct {
-	repo domain.UserRepository
+	repo        domain.UserRepository
+	bots        domain.BotRepository
+	sessions    *SessionService
+	emails      *EmailService
+	audit       *AuditService
+	connections domain.ConnectionTerminator
 }
 
-func NewUserService(repo domain.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo domain.UserRepository, bots domain.BotRepository, sessions *SessionService, emails *EmailService, audit *AuditService, connections domain.ConnectionTerminator) *UserService {
+	return &UserService{repo: repo, bots: bots, sessions: sessions, emails: emails, audit: audit, connections: connections}
 }
 
 func (s *UserService) GetAll(ctx context.Context) ([]domain.User, error) {
@@ -38,7 +57,12 @@ func (s *UserService) GetByID(ctx context.Context, id string) (*domain.User, err
 	return user, nil
 }
 
-func (s *UserService) Update(ctx context.Context, id, username, email string) (*domain.User, error) {
+// Update changes the user's profile. A user changing their own email only
+// records it as pending and is sent a verification link; the address is
+// switched once they verify it. Administrators change it directly, leaving
+// the new address unverified. When the link cannot be sent the updated user
+// is returned along with ErrVerificationNotSent.
+func (s *UserService) Update(ctx context.Context, id string, input UpdateUserInput) (*domain.User, error) {
 	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
@@ -47,17 +71,61 @@ func (s *UserService) Update(ctx context.Context, id, username, email string) (*
 		return nil, ErrUserNotFound
 	}
 
-	if username != "" {
-		user.Username = username
+	actor, hasActor := domain.ActorFromContext(ctx)
+	self := hasActor && actor.UserID == user.ID
+
+	var diff auditDiff
+	if input.Username != "" {
+		diff.add("username", user.Username, input.Username)
+		user.Username = input.Username
+	}
+
+	verify := false
+	switch {
+	case input.Email == "":
+	case input.Email == user.Email:
+		// Asking for the current address cancels a pending change.
+		user.PendingEmail = ""
+	default:
+		existing, err := s.repo.GetByEmail(ctx, input.Email)
+		if err != nil {
+			return nil, fmt.Errorf("check email: %w", err)
+		}
+		if existing != nil {
+			return nil, ErrEmailTaken
+		}
+		if self {
+			user.PendingEmail = input.Email
+			verify = true
+		} else {
+			diff.add("email", user.Email, input.Email)
+			user.Email = input.Email
+			user.EmailVerified = false
+			user.PendingEmail = ""
+		}
 	}
-	if email != "" {
-		user.Email = email
+	if input.DMPolicy != "" {
+		diff.add("dmPolicy", string(user.DMPolicy), string(input.DMPolicy))
+		user.DMPolicy = input.DMPolicy
 	}
 	user.UpdatedAt = time.Now().UTC()
 
 	if err := s.repo.Update(ctx, user); err != nil {
 		return nil, fmt.Errorf("update user: %w", err)
 	}
+
+	// Users editing their own profile are not administrative actions.
+	if hasActor && !self {
+		if err := s.audit.Record(ctx, domain.AuditUserUpdate, domain.AuditTargetUser, user.ID, diff); err != nil {
+			return nil, err
+		}
+	}
+
+	if verify {
+		if err := s.emails.SendVerification(ctx, user); err != nil {
+			return user, fmt.Errorf("%w: %w", ErrVerificationNotSent, err)
+		}
+	}
 	return user, nil
 }
 
@@ -69,8 +137,156 @@ func (s *UserService) Delete(ctx context.Context, id string) error {
 	if user == nil {
 		return ErrUserNotFound
 	}
+	// Revoke before deleting: the user's sessions and bots go with the row.
+	if err := s.sessions.RevokeAll(ctx, id); err != nil {
+		return err
+	}
+	bots, err := s.bots.GetByOwner(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get bots: %w", err)
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	s.disconnectBots(bots)
+
+	var diff auditDiff
+	diff.add("username", user.Username, nil)
+	diff.add("email", user.Email, nil)
+	return s.audit.Record(ctx, domain.AuditUserDelete, domain.AuditTargetUser, user.ID, diff)
+}
+
+// Disable prevents the user from logging in and signs them out everywhere.
+func (s *UserService) Disable(ctx context.Context, id string) (*domain.User, error) {
+	if actor, ok := domain.ActorFromContext(ctx); ok && actor.UserID == id {
+		return nil, ErrCannotDisableSelf
+	}
+
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == domain.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+
+	var diff auditDiff
+	diff.add("status", string(user.Status), string(domain.UserStatusDisabled))
+
+	user.Status = domain.UserStatusDisabled
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	if err := s.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	// Bots connect without a session, so RevokeAll leaves them connected,
+	// and the user's own bots stop authenticating along with them.
+	s.connections.TerminateUser(user.ID)
+	bots, err := s.bots.GetByOwner(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get bots: %w", err)
+	}
+	s.disconnectBots(bots)
+	if err := s.audit.Record(ctx, domain.AuditUserDisable, domain.AuditTargetUser, user.ID, diff); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Enable(ctx context.Context, id string) (*domain.User, error) {
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != domain.UserStatusDisabled {
+		return nil, ErrUserNotDisabled
+	}
+
+	var diff auditDiff
+	diff.add("status", string(user.Status), string(domain.UserStatusActive))
+
+	user.Status = domain.UserStatusActive
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	if err := s.audit.Record(ctx, domain.AuditUserEnable, domain.AuditTargetUser, user.ID, diff); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ForceLogout signs the user out of every session and revokes their
+// outstanding access tokens.
+func (s *UserService) ForceLogout(ctx context.Context, id string) error {
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.audit.Record(ctx, domain.AuditUserForceLogout, domain.AuditTargetUser, user.ID, nil)
+}
+
+func (s *UserService) GetPending(ctx context.Context) ([]domain.User, error) {
+	users, err := s.repo.GetByStatus(ctx, domain.UserStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("get pending users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *UserService) Approve(ctx context.Context, id string) (*domain.User, error) {
+	user, err := s.getPending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff auditDiff
+	diff.add("status", string(user.Status), string(domain.UserStatusActive))
+
+	user.Status = domain.UserStatusActive
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	if err := s.audit.Record(ctx, domain.AuditRegistrationApprove, domain.AuditTargetUser, user.ID, diff); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) Reject(ctx context.Context, id string) error {
+	user, err := s.getPending(ctx, id)
+	if err != nil {
+		return err
+	}
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
-	return nil
+
+	var diff auditDiff
+	diff.add("username", user.Username, nil)
+	diff.add("email", user.Email, nil)
+	return s.audit.Record(ctx, domain.AuditRegistrationReject, domain.AuditTargetUser, user.ID, diff)
+}
+
+func (s *UserService) getPending(ctx context.Context, id string) (*domain.User, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Status != domain.UserStatusPending {
+		return nil, ErrUserNotPending
+	}
+	return user, nil
+}
+
+func (s *UserService) disconnectBots(bots []domain.Bot) {
+	for _, bot := range bots {
+		s.connections.TerminateUser(bot.UserID)
+	}
 }
@@ -0,0 +1,221 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrTooManyWebhooks     = errors.New("too many webhooks")
+	ErrEmptyWebhookMessage = errors.New("webhook message has no content")
+	ErrEmptyEmbed          = errors.New("embed has no title, description or fields")
+)
+
+const maxWebhooksPerChannel = 15
+
+type WebhookService struct {
+	repo      domain.WebhookRepository
+	channels  domain.ChannelRepository
+	messages  domain.MessageRepository
+	audit     *AuditService
+	publicURL string
+}
+
+// NewWebhookService creates a webhook service. publicURL is used to build the
+// URLs webhooks are executed at.
+func NewWebhookService(repo domain.WebhookRepository, channels domain.ChannelRepository, messages domain.MessageRepository, audit *AuditService, publicURL string) *WebhookService {
+	return &WebhookService{repo: repo, channels: channels, messages: messages, audit: audit, publicURL: publicURL}
+}
+
+func (s *WebhookService) GetAll(ctx context.Context, channelID string) ([]domain.Webhook, error) {
+	if err := s.checkChannel(ctx, channelID); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repo.GetByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// Create adds a webhook to the channel and returns it along with its token,
+// which is only known at this point.
+func (s *WebhookService) Create(ctx context.Context, channelID, creatorID, name, avatarURL string) (*domain.Webhook, string, error) {
+	if err := s.checkChannel(ctx, channelID); err != nil {
+		return nil, "", err
+	}
+
+	existing, err := s.repo.GetByChannel(ctx, channelID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get webhooks: %w", err)
+	}
+	if len(existing) >= maxWebhooksPerChannel {
+		return nil, "", ErrTooManyWebhooks
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	webhook := &domain.Webhook{
+		ID:        uuid.New().String(),
+		ChannelID: channelID,
+		Name:      strings.TrimSpace(name),
+		AvatarURL: avatarURL,
+		TokenHash: hashToken(secret),
+		CreatorID: creatorID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, webhook); err != nil {
+		return nil, "", fmt.Errorf("create webhook: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("channelId", nil, channelID)
+	diff.add("name", nil, webhook.Name)
+	if err := s.audit.Record(ctx, domain.AuditWebhookCreate, domain.AuditTargetWebhook, webhook.ID, diff); err != nil {
+		return nil, "", err
+	}
+	return webhook, secret, nil
+}
+
+// RotateToken replaces the webhook's token and returns the new one. The
+// previous URL stops working immediately.
+func (s *WebhookService) RotateToken(ctx context.Context, channelID, id string) (*domain.Webhook, string, error) {
+	webhook, err := s.get(ctx, channelID, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	webhook.TokenHash = hashToken(secret)
+	if err := s.repo.UpdateToken(ctx, id, webhook.TokenHash); err != nil {
+		return nil, "", fmt.Errorf("update webhook token: %w", err)
+	}
+
+	if err := s.audit.Record(ctx, domain.AuditWebhookTokenRotate, domain.AuditTargetWebhook, id, nil); err != nil {
+		return nil, "", err
+	}
+	return webhook, secret, nil
+}
+
+// Delete removes the webhook. Messages posted through it are kept.
+func (s *WebhookService) Delete(ctx context.Context, channelID, id string) error {
+	webhook, err := s.get(ctx, channelID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+
+	var diff auditDiff
+	diff.add("channelId", channelID, nil)
+	diff.add("name", webhook.Name, nil)
+	return s.audit.Record(ctx, domain.AuditWebhookDelete, domain.AuditTargetWebhook, id, diff)
+}
+
+type ExecuteWebhookInput struct {
+	Content string
+	// Username and AvatarURL override the webhook's name and avatar for this
+	// message when set.
+	Username  string
+	AvatarURL string
+	Embeds    []domain.Embed
+}
+
+// Execute posts a message into the webhook's channel. Unknown webhooks and
+// wrong tokens are reported the same way, so that the endpoint does not
+// reveal which webhooks exist. Webhook messages have no author, so slowmode
+// and automod, which act on authors, do not apply to them.
+func (s *WebhookService) Execute(ctx context.Context, id, secret string, input ExecuteWebhookInput) (*domain.Message, error) {
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get webhook: %w", err)
+	}
+	if webhook == nil || subtle.ConstantTimeCompare([]byte(webhook.TokenHash), []byte(hashToken(secret))) != 1 {
+		return nil, ErrWebhookNotFound
+	}
+
+	if strings.TrimSpace(input.Content) == "" && len(input.Embeds) == 0 {
+		return nil, ErrEmptyWebhookMessage
+	}
+	for _, embed := range input.Embeds {
+		if strings.TrimSpace(embed.Title) == "" && strings.TrimSpace(embed.Description) == "" && len(embed.Fields) == 0 {
+			return nil, ErrEmptyEmbed
+		}
+	}
+
+	author := &domain.MessageWebhook{
+		ID:        webhook.ID,
+		Username:  strings.TrimSpace(input.Username),
+		AvatarURL: input.AvatarURL,
+	}
+	if author.Username == "" {
+		author.Username = webhook.Name
+	}
+	if author.AvatarURL == "" {
+		author.AvatarURL = webhook.AvatarURL
+	}
+
+	message := &domain.Message{
+		ID:        uuid.New().String(),
+		ChannelID: webhook.ChannelID,
+		Type:      domain.MessageTypeDefault,
+		Content:   input.Content,
+		Embeds:    input.Embeds,
+		Webhook:   author,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.messages.Create(ctx, message); err != nil {
+		return nil, fmt.Errorf("create message: %w", err)
+	}
+	return message, nil
+}
+
+// URL returns the address the webhook is executed at, which embeds its token.
+func (s *WebhookService) URL(webhook *domain.Webhook, secret string) string {
+	return fmt.Sprintf("%s/api/webhooks/%s/%s", s.publicURL, webhook.ID, secret)
+}
+
+// checkChannel only accepts text channels, the only ones messages can be
+// posted in.
+func (s *WebhookService) checkChannel(ctx context.Context, channelID string) error {
+	channel, err := s.channels.GetByID(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("get channel: %w", err)
+	}
+	if channel == nil {
+		return ErrChannelNotFound
+	}
+	if channel.Type != domain.ChannelTypeText {
+		return ErrNotTextChannel
+	}
+	return nil
+}
+
+func (s *WebhookService) get(ctx context.Context, channelID, id string) (*domain.Webhook, error) {
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get webhook: %w", err)
+	}
+	if webhook == nil || webhook.ChannelID != channelID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
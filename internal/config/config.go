@@ -1,63 +1,350 @@
 package config
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/tartine-studio/harmony-server/internal/domain"
 )
 
 type Config struct {
 	Host          string        `env:"HARMONY_HOST"            envDefault:"0.0.0.0"`
 	Port          int           `env:"HARMONY_PORT"            envDefault:"8080"`
 	DataDir       string        `env:"HARMONY_DATA_DIR"        envDefault:"./data"`
-	JWTSecret     string        `env:"HARMONY_JWT_SECRET"`
 	JWTAccessTTL  time.Duration `env:"HARMONY_JWT_ACCESS_TTL"  envDefault:"15m"`
 	JWTRefreshTTL time.Duration `env:"HARMONY_JWT_REFRESH_TTL" envDefault:"168h"`
+	// JWTAlgorithm is the algorithm of the signing keys generated from now
+	// on, "EdDSA" or "ES256". Changing it takes effect at the next rotation.
+	JWTAlgorithm string `env:"HARMONY_JWT_ALGORITHM" envDefault:"EdDSA"`
+	// JWTSecret is the HS256 secret tokens were signed with before signing
+	// keys, read from .jwt_secret in DataDir when unset. Access tokens it
+	// signed keep working for JWTAccessTTL after upgrading, and it is ignored
+	// from then on.
+	JWTSecret string `env:"HARMONY_JWT_SECRET"`
+
+	// TokenVersionCacheTTL bounds how long the auth middleware reuses a user's
+	// token version before looking it up again.
+	TokenVersionCacheTTL time.Duration `env:"HARMONY_TOKEN_VERSION_CACHE_TTL" envDefault:"1m"`
+
+	// RequireAdminMFA denies administrators access to admin endpoints until
+	// they enable two-factor authentication.
+	RequireAdminMFA bool `env:"HARMONY_REQUIRE_ADMIN_MFA" envDefault:"false"`
+
+	// After LoginFreeAttempts failed logins in a row for an account, or
+	// LoginIPFreeAttempts from an address, each further attempt waits twice
+	// as long as the last, up to LoginBackoffMax. LoginLockoutThreshold failures for an account,
+	// or LoginIPLockoutThreshold from an address, lock it for
+	// LoginLockoutDuration. Failures are forgotten LoginFailureWindow after
+	// the last one.
+	LoginFreeAttempts       int           `env:"HARMONY_LOGIN_FREE_ATTEMPTS"        envDefault:"3"`
+	LoginIPFreeAttempts     int           `env:"HARMONY_LOGIN_IP_FREE_ATTEMPTS"     envDefault:"20"`
+	LoginBackoffBase        time.Duration `env:"HARMONY_LOGIN_BACKOFF_BASE"         envDefault:"1s"`
+	LoginBackoffMax         time.Duration `env:"HARMONY_LOGIN_BACKOFF_MAX"          envDefault:"1m"`
+	LoginLockoutThreshold   int           `env:"HARMONY_LOGIN_LOCKOUT_THRESHOLD"    envDefault:"10"`
+	LoginIPLockoutThreshold int           `env:"HARMONY_LOGIN_IP_LOCKOUT_THRESHOLD" envDefault:"50"`
+	LoginLockoutDuration    time.Duration `env:"HARMONY_LOGIN_LOCKOUT_DURATION"     envDefault:"15m"`
+	LoginFailureWindow      time.Duration `env:"HARMONY_LOGIN_FAILURE_WINDOW"       envDefault:"1h"`
+
+	// Rate limits are written "<burst>/<period>", such as "30/1m", or "off".
+	// RateLimitPublic applies to each address making requests without
+	// authentication and RateLimitUser to each user. RateLimitRoutes
+	// overrides the limits of the stricter buckets some routes also count
+	// against, as in "login:5/1m,messages:off"; see defaultRouteRateLimits.
+	RateLimitPublic domain.RateLimit            `env:"HARMONY_RATE_LIMIT_PUBLIC" envDefault:"60/1m"`
+	RateLimitUser   domain.RateLimit            `env:"HARMONY_RATE_LIMIT_USER"   envDefault:"300/1m"`
+	RateLimitRoutes map[string]domain.RateLimit `env:"HARMONY_RATE_LIMIT_ROUTES"`
+	// RateLimitStore selects where buckets are kept: "memory" suits a single
+	// instance, "redis" shares them between instances through RedisURL.
+	RateLimitStore string `env:"HARMONY_RATE_LIMIT_STORE" envDefault:"memory"`
+	RedisURL       string `env:"HARMONY_REDIS_URL"`
+
+	RegistrationMode        domain.RegistrationMode `env:"HARMONY_REGISTRATION_MODE"         envDefault:"open"`
+	InvitePruneInterval     time.Duration           `env:"HARMONY_INVITE_PRUNE_INTERVAL"     envDefault:"1h"`
+	ModerationPruneInterval time.Duration           `env:"HARMONY_MODERATION_PRUNE_INTERVAL" envDefault:"1m"`
+	AuditLogRetention       time.Duration           `env:"HARMONY_AUDIT_LOG_RETENTION"       envDefault:"2160h"`
+	GroupDMMaxRecipients    int                     `env:"HARMONY_GROUP_DM_MAX_RECIPIENTS"   envDefault:"10"`
+
+	// PublicURL is where users reach the instance. It is used to build the
+	// links sent by email.
+	PublicURL            string        `env:"HARMONY_PUBLIC_URL"             envDefault:"http://localhost:8080"`
+	PasswordResetTTL     time.Duration `env:"HARMONY_PASSWORD_RESET_TTL"     envDefault:"1h"`
+	EmailVerificationTTL time.Duration `env:"HARMONY_EMAIL_VERIFICATION_TTL" envDefault:"48h"`
+	// RequireVerifiedEmail stops users from posting messages until they have
+	// verified their email address.
+	RequireVerifiedEmail bool `env:"HARMONY_REQUIRE_VERIFIED_EMAIL" envDefault:"false"`
+
+	// WebAuthnRPID is the domain passkeys are bound to. It defaults to the
+	// host of PublicURL, and WebAuthnOrigins to PublicURL itself.
+	WebAuthnRPID    string   `env:"HARMONY_WEBAUTHN_RP_ID"`
+	WebAuthnRPName  string   `env:"HARMONY_WEBAUTHN_RP_NAME" envDefault:"Harmony"`
+	WebAuthnOrigins []string `env:"HARMONY_WEBAUTHN_ORIGINS" envSeparator:","`
+
+	// GatewayOrigins are the web origins allowed to open gateway connections,
+	// which default to PublicURL. Clients that send no origin, such as bots,
+	// are always allowed.
+	GatewayOrigins []string `env:"HARMONY_GATEWAY_ORIGINS" envSeparator:","`
+
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies
+	// in front of the instance. Only their X-Forwarded-For and X-Real-IP
+	// headers are believed; clients are identified by the address they
+	// connect from otherwise.
+	TrustedProxies []netip.Prefix `env:"HARMONY_TRUSTED_PROXIES"`
+
+	// OIDCProviders are the OpenID Connect providers users can sign in with,
+	// configured as HARMONY_OIDC_0_NAME, HARMONY_OIDC_0_ISSUER and so on.
+	OIDCProviders []OIDCProvider `envPrefix:"HARMONY_OIDC_"`
+
+	// LDAPURL enables signing in with the credentials of an LDAP directory,
+	// such as ldaps://ldap.example.com. Users are found with LDAPUserFilter
+	// while bound as LDAPBindDN, then authenticated by binding as their entry.
+	LDAPURL          string `env:"HARMONY_LDAP_URL"`
+	LDAPStartTLS     bool   `env:"HARMONY_LDAP_START_TLS"     envDefault:"false"`
+	LDAPBindDN       string `env:"HARMONY_LDAP_BIND_DN"`
+	LDAPBindPassword string `env:"HARMONY_LDAP_BIND_PASSWORD"`
+	LDAPBaseDN       string `env:"HARMONY_LDAP_BASE_DN"`
+	LDAPUserFilter   string `env:"HARMONY_LDAP_USER_FILTER"   envDefault:"(|(uid={login})(mail={login}))"`
+	// LDAPIDAttribute holds a stable identifier of entries. Set it to "dn" to
+	// use the distinguished name instead.
+	LDAPIDAttribute    string `env:"HARMONY_LDAP_ID_ATTRIBUTE"    envDefault:"entryUUID"`
+	LDAPEmailAttribute string `env:"HARMONY_LDAP_EMAIL_ATTRIBUTE" envDefault:"mail"`
+	LDAPNameAttribute  string `env:"HARMONY_LDAP_NAME_ATTRIBUTE"  envDefault:"displayName"`
+	LDAPGroupAttribute string `env:"HARMONY_LDAP_GROUP_ATTRIBUTE" envDefault:"memberOf"`
+	// LDAPAdminGroups are separated by semicolons, since group DNs contain
+	// commas. Administrator is the only role Harmony has and every user is a
+	// member of the one community, so this is the only use of groups.
+	LDAPAdminGroups   []string      `env:"HARMONY_LDAP_ADMIN_GROUPS"    envSeparator:";"`
+	LDAPAutoProvision bool          `env:"HARMONY_LDAP_AUTO_PROVISION"  envDefault:"true"`
+	LDAPSyncInterval  time.Duration `env:"HARMONY_LDAP_SYNC_INTERVAL"   envDefault:"1h"`
+	// LDAPLocalLogin keeps local passwords working alongside the directory,
+	// which is checked second.
+	LDAPLocalLogin bool `env:"HARMONY_LDAP_LOCAL_LOGIN" envDefault:"true"`
+
+	// MailDriver selects how emails are delivered: "smtp" sends them through
+	// an SMTP relay, "outbox" writes them to MailOutboxDir instead.
+	MailDriver    string `env:"HARMONY_MAIL_DRIVER"     envDefault:"outbox"`
+	MailFrom      string `env:"HARMONY_MAIL_FROM"       envDefault:"Harmony <noreply@localhost>"`
+	MailOutboxDir string `env:"HARMONY_MAIL_OUTBOX_DIR"`
+	SMTPHost      string `env:"HARMONY_SMTP_HOST"`
+	SMTPPort      int    `env:"HARMONY_SMTP_PORT"       envDefault:"587"`
+	SMTPUsername  string `env:"HARMONY_SMTP_USERNAME"`
+	SMTPPassword  string `env:"HARMONY_SMTP_PASSWORD"`
+}
+
+type OIDCProvider struct {
+	// Name identifies the provider in URLs and linked identities, so it
+	// should not change once users signed in with it.
+	Name         string   `env:"NAME"`
+	DisplayName  string   `env:"DISPLAY_NAME"`
+	Issuer       string   `env:"ISSUER"`
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	Scopes       []string `env:"SCOPES" envDefault:"openid,email,profile" envSeparator:","`
+	// RedirectURL is the page of the web client that receives the code. It
+	// defaults to PublicURL + "/auth/oidc/<name>/callback".
+	RedirectURL string `env:"REDIRECT_URL"`
+	GroupsClaim string `env:"GROUPS_CLAIM" envDefault:"groups"`
+	// AdminGroups makes members of these groups administrators and everyone
+	// else not. Administrator status is not managed by the provider when
+	// it is empty.
+	AdminGroups   []string `env:"ADMIN_GROUPS"   envSeparator:","`
+	AutoProvision bool     `env:"AUTO_PROVISION" envDefault:"true"`
+}
+
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverOutbox = "outbox"
+)
+
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// defaultRouteRateLimits are the limits of the buckets the router mounts on
+// some routes: "login" covers sign-ins and the tokens sent by email,
+// "register" registrations, "email" requests that send an email, "invites"
+// uses of invite codes, "messages" new messages, "reports" new reports and
+// "webhooks" messages posted with each incoming webhook token.
+var defaultRouteRateLimits = map[string]domain.RateLimit{
+	"login":    {Burst: 10, Period: time.Minute},
+	"register": {Burst: 5, Period: time.Hour},
+	"email":    {Burst: 5, Period: time.Hour},
+	"invites":  {Burst: 10, Period: time.Minute},
+	"messages": {Burst: 5, Period: 5 * time.Second},
+	"reports":  {Burst: 5, Period: time.Minute},
+	"webhooks": {Burst: 5, Period: 2 * time.Second},
 }
 
 func Load() (Config, error) {
 	var cfg Config
-	if err := env.Parse(&cfg); err != nil {
+	err := env.ParseWithOptions(&cfg, env.Options{
+		FuncMap: map[reflect.Type]env.ParserFunc{
+			reflect.TypeOf(domain.RateLimit{}): func(v string) (any, error) {
+				return domain.ParseRateLimit(v)
+			},
+			// netip.Prefix rejects bare addresses when unmarshalling itself.
+			reflect.TypeOf([]netip.Prefix(nil)): parsePrefixes,
+		},
+	})
+	if err != nil {
 		return Config{}, err
 	}
 
-	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
-		return Config{}, fmt.Errorf("create data dir: %w", err)
+	if !cfg.RegistrationMode.Valid() {
+		return Config{}, fmt.Errorf("invalid registration mode %q", cfg.RegistrationMode)
 	}
 
-	if cfg.JWTSecret == "" {
-		secret, err := resolveJWTSecret(cfg.DataDir)
-		if err != nil {
-			return Config{}, fmt.Errorf("resolve jwt secret: %w", err)
+	switch cfg.JWTAlgorithm {
+	case "EdDSA", "ES256":
+	default:
+		return Config{}, fmt.Errorf("invalid jwt algorithm %q", cfg.JWTAlgorithm)
+	}
+
+	if cfg.TokenVersionCacheTTL <= 0 {
+		return Config{}, fmt.Errorf("token version cache ttl must be positive, got %s", cfg.TokenVersionCacheTTL)
+	}
+
+	if cfg.LoginFreeAttempts < 0 || cfg.LoginIPFreeAttempts < 0 || cfg.LoginLockoutThreshold < 1 || cfg.LoginIPLockoutThreshold < 1 {
+		return Config{}, fmt.Errorf("login attempt thresholds must be positive and free attempts not negative")
+	}
+	if cfg.LoginBackoffBase <= 0 || cfg.LoginBackoffMax < cfg.LoginBackoffBase || cfg.LoginLockoutDuration <= 0 || cfg.LoginFailureWindow <= 0 {
+		return Config{}, fmt.Errorf("login backoff and lockout durations must be positive, with the maximum backoff at least the base")
+	}
+
+	routes := maps.Clone(defaultRouteRateLimits)
+	for name, limit := range cfg.RateLimitRoutes {
+		if _, ok := routes[name]; !ok {
+			return Config{}, fmt.Errorf("unknown rate limit bucket %q", name)
 		}
-		cfg.JWTSecret = secret
+		routes[name] = limit
 	}
+	cfg.RateLimitRoutes = routes
 
-	return cfg, nil
-}
+	switch cfg.RateLimitStore {
+	case RateLimitStoreRedis:
+		if cfg.RedisURL == "" {
+			return Config{}, fmt.Errorf("redis url is required by the redis rate limit store")
+		}
+	case RateLimitStoreMemory:
+	default:
+		return Config{}, fmt.Errorf("invalid rate limit store %q", cfg.RateLimitStore)
+	}
+
+	if cfg.GroupDMMaxRecipients < 2 {
+		return Config{}, fmt.Errorf("group dm max recipients must be at least 2, got %d", cfg.GroupDMMaxRecipients)
+	}
+
+	switch cfg.MailDriver {
+	case MailDriverSMTP:
+		if cfg.SMTPHost == "" {
+			return Config{}, fmt.Errorf("smtp host is required by the smtp mail driver")
+		}
+	case MailDriverOutbox:
+	default:
+		return Config{}, fmt.Errorf("invalid mail driver %q", cfg.MailDriver)
+	}
+
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+
+	if cfg.WebAuthnRPID == "" {
+		u, err := url.Parse(cfg.PublicURL)
+		if err != nil || u.Hostname() == "" {
+			return Config{}, fmt.Errorf("invalid public url %q", cfg.PublicURL)
+		}
+		cfg.WebAuthnRPID = u.Hostname()
+	}
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{cfg.PublicURL}
+	}
+	if len(cfg.GatewayOrigins) == 0 {
+		cfg.GatewayOrigins = []string{cfg.PublicURL}
+	}
+
+	if cfg.LDAPURL != "" {
+		if cfg.LDAPBaseDN == "" {
+			return Config{}, fmt.Errorf("ldap base dn is required when ldap is enabled")
+		}
+		if cfg.LDAPSyncInterval <= 0 {
+			return Config{}, fmt.Errorf("ldap sync interval must be positive, got %s", cfg.LDAPSyncInterval)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.OIDCProviders))
+	for i := range cfg.OIDCProviders {
+		p := &cfg.OIDCProviders[i]
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" {
+			return Config{}, fmt.Errorf("oidc provider %d needs a name, an issuer and a client id", i)
+		}
+		if names[p.Name] {
+			return Config{}, fmt.Errorf("duplicate oidc provider name %q", p.Name)
+		}
+		names[p.Name] = true
 
-func resolveJWTSecret(dataDir string) (string, error) {
-	path := filepath.Join(dataDir, ".jwt_secret")
+		if p.DisplayName == "" {
+			p.DisplayName = p.Name
+		}
+		if p.RedirectURL == "" {
+			p.RedirectURL = cfg.PublicURL + "/auth/oidc/" + url.PathEscape(p.Name) + "/callback"
+		}
+	}
 
-	data, err := os.ReadFile(path)
-	if err == nil {
-		return string(data), nil
+	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+		return Config{}, fmt.Errorf("create data dir: %w", err)
 	}
 
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", fmt.Errorf("generate secret: %w", err)
+	if cfg.MailOutboxDir == "" {
+		cfg.MailOutboxDir = filepath.Join(cfg.DataDir, "outbox")
 	}
 
-	secret := hex.EncodeToString(b)
-	if err := os.WriteFile(path, []byte(secret), 0o600); err != nil {
-		return "", fmt.Errorf("persist secret: %w", err)
+	return cfg, nil
+}
+
+// LegacyJWTSecret returns JWTSecret, or the secret earlier versions generated
+// in the data directory when it is unset. It is empty when there is neither.
+func (c Config) LegacyJWTSecret() (string, error) {
+	if c.JWTSecret != "" {
+		return c.JWTSecret, nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.DataDir, ".jwt_secret"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
 	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
 
-	return secret, nil
+// parsePrefixes reads a comma-separated list of CIDR ranges and single
+// addresses, which become the range holding only them.
+func parsePrefixes(v string) (any, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
 }
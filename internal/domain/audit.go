@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditChannelCreate       AuditAction = "channel.create"
+	AuditChannelUpdate       AuditAction = "channel.update"
+	AuditChannelDelete       AuditAction = "channel.delete"
+	AuditUserCreate          AuditAction = "user.create"
+	AuditUserUpdate          AuditAction = "user.update"
+	AuditUserDelete          AuditAction = "user.delete"
+	AuditUserDisable         AuditAction = "user.disable"
+	AuditUserEnable          AuditAction = "user.enable"
+	AuditUserForceLogout     AuditAction = "user.force_logout"
+	AuditUserMFAReset        AuditAction = "user.mfa_reset"
+	AuditUserUnlock          AuditAction = "user.unlock"
+	AuditRegistrationApprove AuditAction = "registration.approve"
+	AuditRegistrationReject  AuditAction = "registration.reject"
+	AuditMemberKick          AuditAction = "member.kick"
+	AuditMemberBan           AuditAction = "member.ban"
+	AuditMemberUnban         AuditAction = "member.unban"
+	AuditMemberTimeout       AuditAction = "member.timeout"
+	AuditMemberTimeoutRemove AuditAction = "member.timeout_remove"
+	AuditMessageDelete       AuditAction = "message.delete"
+	AuditAutoModRuleCreate   AuditAction = "automod_rule.create"
+	AuditAutoModRuleUpdate   AuditAction = "automod_rule.update"
+	AuditAutoModRuleDelete   AuditAction = "automod_rule.delete"
+	AuditReportCreate        AuditAction = "report.create"
+	AuditReportResolve       AuditAction = "report.resolve"
+	AuditReportDismiss       AuditAction = "report.dismiss"
+	AuditReportEscalate      AuditAction = "report.escalate"
+	AuditBotAuthorize        AuditAction = "bot.authorize"
+	AuditWebhookCreate       AuditAction = "webhook.create"
+	AuditWebhookTokenRotate  AuditAction = "webhook.token_rotate"
+	AuditWebhookDelete       AuditAction = "webhook.delete"
+)
+
+type AuditTargetType string
+
+const (
+	AuditTargetChannel     AuditTargetType = "channel"
+	AuditTargetUser        AuditTargetType = "user"
+	AuditTargetMessage     AuditTargetType = "message"
+	AuditTargetAutoModRule AuditTargetType = "automod_rule"
+	AuditTargetReport      AuditTargetType = "report"
+	AuditTargetWebhook     AuditTargetType = "webhook"
+)
+
+// AuditChange describes a single field modified by an audited action. Old is
+// nil for created values and New is nil for removed ones.
+type AuditChange struct {
+	Key string `json:"key"`
+	Old any    `json:"old,omitempty"`
+	New any    `json:"new,omitempty"`
+}
+
+type AuditLogEntry struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actorId"`
+	Action     AuditAction     `json:"action"`
+	TargetType AuditTargetType `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	Changes    []AuditChange   `json:"changes"`
+	Reason     string          `json:"reason"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+type AuditLogFilter struct {
+	ActorID    string
+	Action     AuditAction
+	TargetType AuditTargetType
+	TargetID   string
+	// Before is the ID of the entry to page back from.
+	Before string
+	Limit  int
+}
+
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *AuditLogEntry) error
+	Find(ctx context.Context, filter AuditLogFilter) ([]AuditLogEntry, error)
+	DeleteOlderThan(ctx context.Context, t time.Time) (int64, error)
+}
+
+// Actor identifies who performs an administrative action and why.
+type Actor struct {
+	UserID string
+	Reason string
+}
+
+type actorContextKey struct{}
+
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorContextKey{}).(Actor)
+	return actor, ok
+}
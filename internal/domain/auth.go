@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"context"
+	"crypto"
+)
+
 type TokenType string
 
 const (
@@ -7,9 +12,29 @@ const (
 	RefreshToken TokenType = "refresh"
 )
 
+type RegistrationMode string
+
+const (
+	RegistrationOpen       RegistrationMode = "open"
+	RegistrationInviteOnly RegistrationMode = "invite"
+	RegistrationApproval   RegistrationMode = "approval"
+	RegistrationClosed     RegistrationMode = "closed"
+)
+
+func (m RegistrationMode) Valid() bool {
+	switch m {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationApproval, RegistrationClosed:
+		return true
+	}
+	return false
+}
+
 type AuthClaims struct {
-	UserID string
-	Type   TokenType
+	UserID    string
+	SessionID string
+	// Version is the user's token version when the token was issued.
+	Version int
+	Type    TokenType
 }
 
 type TokenPair struct {
@@ -18,6 +43,30 @@ type TokenPair struct {
 }
 
 type TokenProvider interface {
-	GenerateTokenPair(userId string) (TokenPair, error)
+	GenerateTokenPair(userID, sessionID string, version int) (TokenPair, error)
 	ValidateToken(token string) (*AuthClaims, error)
 }
+
+// TokenVersionStore looks up and bumps user token versions. Lookups happen on
+// every authenticated request, so implementations are expected to cache them.
+type TokenVersionStore interface {
+	// Get returns the user's current token version. ok is false when the
+	// user no longer exists.
+	Get(ctx context.Context, userID string) (version int, ok bool, err error)
+	// Bump increments the user's token version, revoking every token issued
+	// so far.
+	Bump(ctx context.Context, userID string) error
+}
+
+// PublicKey verifies the tokens signed by one of the server's signing keys.
+type PublicKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+// KeySet lists the public keys that tokens still valid may be signed with,
+// so that other services can verify them.
+type KeySet interface {
+	PublicKeys() []PublicKey
+}
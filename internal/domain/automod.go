@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type AutoModTrigger string
+
+const (
+	AutoModTriggerKeyword         AutoModTrigger = "keyword"
+	AutoModTriggerRegex           AutoModTrigger = "regex"
+	AutoModTriggerMentionSpam     AutoModTrigger = "mention_spam"
+	AutoModTriggerLink            AutoModTrigger = "link"
+	AutoModTriggerRepeatedMessage AutoModTrigger = "repeated_message"
+	AutoModTriggerInviteLink      AutoModTrigger = "invite_link"
+)
+
+type AutoModActionType string
+
+const (
+	AutoModActionBlock   AutoModActionType = "block"
+	AutoModActionFlag    AutoModActionType = "flag"
+	AutoModActionTimeout AutoModActionType = "timeout"
+	AutoModActionAlert   AutoModActionType = "alert"
+)
+
+// AutoModConfig holds the trigger-specific settings of a rule. Only the
+// fields relevant to the rule's trigger are used.
+type AutoModConfig struct {
+	// Keywords are matched case-insensitively as whole words.
+	Keywords []string `json:"keywords,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	// MentionLimit is the number of mentions above which a message triggers.
+	MentionLimit int `json:"mentionLimit,omitempty"`
+	// AllowedDomains, when set, rejects links to any other domain.
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+	DeniedDomains  []string `json:"deniedDomains,omitempty"`
+	// RepeatLimit is the number of identical messages allowed per author
+	// within RepeatWindow seconds.
+	RepeatLimit  int `json:"repeatLimit,omitempty"`
+	RepeatWindow int `json:"repeatWindow,omitempty"`
+}
+
+type AutoModAction struct {
+	Type AutoModActionType `json:"type"`
+	// Duration is the timeout length in seconds for timeout actions.
+	Duration int `json:"duration,omitempty"`
+	// ChannelID is the log channel for alert actions.
+	ChannelID string `json:"channelId,omitempty"`
+}
+
+type AutoModRule struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Trigger        AutoModTrigger  `json:"trigger"`
+	Config         AutoModConfig   `json:"config"`
+	Actions        []AutoModAction `json:"actions"`
+	ExemptChannels []string        `json:"exemptChannels"`
+	ExemptAdmins   bool            `json:"exemptAdmins"`
+	Enabled        bool            `json:"enabled"`
+	CreatorID      string          `json:"creatorId"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// AutoModFlag records a message that matched a rule with a flag action.
+type AutoModFlag struct {
+	ID        string    `json:"id"`
+	RuleID    string    `json:"ruleId"`
+	UserID    string    `json:"userId"`
+	ChannelID string    `json:"channelId"`
+	Content   string    `json:"content"`
+	Blocked   bool      `json:"blocked"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AutoModRepository interface {
+	CreateRule(ctx context.Context, rule *AutoModRule) error
+	GetRules(ctx context.Context) ([]AutoModRule, error)
+	GetRuleByID(ctx context.Context, id string) (*AutoModRule, error)
+	UpdateRule(ctx context.Context, rule *AutoModRule) error
+	DeleteRule(ctx context.Context, id string) error
+	CreateFlag(ctx context.Context, flag *AutoModFlag) error
+	GetFlags(ctx context.Context, limit int) ([]AutoModFlag, error)
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Ban struct {
+	UserID      string     `json:"userId"`
+	ModeratorID string     `json:"moderatorId"`
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the ban is still in effect at the given time.
+func (b *Ban) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
+
+type BanRepository interface {
+	Create(ctx context.Context, ban *Ban) error
+	GetByUserID(ctx context.Context, userID string) (*Ban, error)
+	GetActive(ctx context.Context, now time.Time) ([]Ban, error)
+	Delete(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// BotTokenPrefix starts every bot token, so that they can be told apart from
+// session and personal access tokens and found by secret scanners.
+const BotTokenPrefix = "hbot_"
+
+// BotEmailDomain holds the placeholder addresses of bot accounts, which have
+// no mailbox. The .invalid top-level domain is reserved and never resolves.
+const BotEmailDomain = "bots.invalid"
+
+// Bot holds what sets a bot account apart from other users. Bots are owned by
+// the user who created them and can only act in the community once an
+// administrator authorized them, which grants them Permissions.
+type Bot struct {
+	UserID    string
+	OwnerID   string
+	TokenHash string
+	// Permissions are the scopes the community granted the bot. They are
+	// empty until it is authorized.
+	Permissions []TokenScope
+	// AuthorizedBy is the administrator who last authorized the bot. It is
+	// empty when the bot was never authorized or the administrator was
+	// deleted.
+	AuthorizedBy string
+	AuthorizedAt *time.Time
+	CreatedAt    time.Time
+}
+
+// Authorized reports whether the community granted the bot its permissions.
+func (b *Bot) Authorized() bool {
+	return b.AuthorizedAt != nil
+}
+
+// HasPermission reports whether the bot was granted scope.
+func (b *Bot) HasPermission(scope TokenScope) bool {
+	for _, s := range b.Permissions {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type BotRepository interface {
+	// Create stores the bot along with its user account.
+	Create(ctx context.Context, user *User, bot *Bot) error
+	GetByUserID(ctx context.Context, userID string) (*Bot, error)
+	GetByHash(ctx context.Context, hash string) (*Bot, error)
+	GetByOwner(ctx context.Context, ownerID string) ([]Bot, error)
+	UpdateToken(ctx context.Context, userID, hash string) error
+	UpdateAuthorization(ctx context.Context, bot *Bot) error
+}
+
+// ConnectionTerminator closes every real-time connection of a user, so that
+// resetting a bot's token or deleting it takes effect immediately.
+type ConnectionTerminator interface {
+	TerminateUser(userID string)
+}
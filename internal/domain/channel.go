@@ -8,16 +8,21 @@ import (
 type ChannelType string
 
 const (
-	ChannelTypeText  ChannelType = "text"
-	ChannelTypeVoice ChannelType = "voice"
+	ChannelTypeText    ChannelType = "text"
+	ChannelTypeVoice   ChannelType = "voice"
+	ChannelTypeDM      ChannelType = "dm"
+	ChannelTypeGroupDM ChannelType = "group_dm"
 )
 
 type Channel struct {
-	ID        string      `json:"id"`
-	Name      string      `json:"name"`
-	Type      ChannelType `json:"type"`
-	CreatedAt time.Time   `json:"createdAt"`
-	UpdatedAt time.Time   `json:"updatedAt"`
+	ID   string      `json:"id"`
+	Name string      `json:"name"`
+	Type ChannelType `json:"type"`
+	// Slowmode is the minimum number of seconds a member must wait between
+	// two messages in the channel. Zero disables it.
+	Slowmode  int       `json:"slowmode"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type ChannelRepository interface {
@@ -27,3 +32,45 @@ type ChannelRepository interface {
 	Update(ctx context.Context, channel *Channel) error
 	Delete(ctx context.Context, id string) error
 }
+
+// SlowmodeRepository tracks when members last posted in channels with
+// slowmode enabled.
+type SlowmodeRepository interface {
+	// Take records a message by the user in the channel at now, unless their
+	// previous one was posted less than interval ago. It then returns false
+	// along with the time of that message.
+	Take(ctx context.Context, channelID, userID string, now time.Time, interval time.Duration) (bool, time.Time, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DMChannel is a private conversation between users, kept apart from the
+// community's channels and their moderation rules.
+type DMChannel struct {
+	Channel
+	// OwnerID and Icon are only set on group channels.
+	OwnerID string `json:"ownerId,omitempty"`
+	Icon    string `json:"icon,omitempty"`
+	// RecipientIDs lists the participants in the order they joined.
+	RecipientIDs []string `json:"recipientIds"`
+	// LastActivityAt is the time of the latest message, or the creation time
+	// of the channel when it has none.
+	LastActivityAt time.Time `json:"lastActivityAt"`
+}
+
+type DMChannelRepository interface {
+	Create(ctx context.Context, channel *DMChannel) error
+	// CreateDM creates a one-to-one channel, unless its two recipients already
+	// have one. It then returns that channel and false instead.
+	CreateDM(ctx context.Context, channel *DMChannel) (*DMChannel, bool, error)
+	GetByID(ctx context.Context, id string) (*DMChannel, error)
+	// GetByRecipients returns the one-to-one channel between the two users, or
+	// nil when they have never talked.
+	GetByRecipients(ctx context.Context, userID, otherID string) (*DMChannel, error)
+	// GetByUser returns the user's channels, most recently active first.
+	GetByUser(ctx context.Context, userID string) ([]DMChannel, error)
+	// Update saves the name, icon and owner of a group channel.
+	Update(ctx context.Context, channel *DMChannel) error
+	AddRecipient(ctx context.Context, channelID, userID string) error
+	RemoveRecipient(ctx context.Context, channelID, userID string) error
+	Delete(ctx context.Context, id string) error
+}
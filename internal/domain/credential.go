@@ -0,0 +1,30 @@
+package domain
+
+import "context"
+
+// CredentialVerifier checks the login and password a user signs in with.
+type CredentialVerifier interface {
+	// VerifyCredentials returns the user the credentials belong to, or nil
+	// when they are wrong.
+	VerifyCredentials(ctx context.Context, login, password string) (*User, error)
+}
+
+// DirectoryEntry is a user account held in an external directory.
+type DirectoryEntry struct {
+	// ID identifies the entry for as long as it exists, even when it is
+	// renamed.
+	ID          string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+// Directory authenticates users against an external directory, such as an
+// LDAP server, and looks their entries up.
+type Directory interface {
+	// Authenticate returns the entry found for login when password is its
+	// password, or nil when no entry matches or the password is wrong.
+	Authenticate(ctx context.Context, login, password string) (*DirectoryEntry, error)
+	// Lookup returns the entry with the given ID, or nil when it was removed.
+	Lookup(ctx context.Context, id string) (*DirectoryEntry, error)
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// EmailVerification is a single-use token proving that a user controls
+// Email, which is either their current address or the one they asked to
+// switch to. Only the hash of the token is stored.
+type EmailVerification struct {
+	TokenHash string
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, verification *EmailVerification) error
+	GetByHash(ctx context.Context, hash string) (*EmailVerification, error)
+	// Delete consumes the token, returning false when it no longer exists.
+	Delete(ctx context.Context, hash string) (bool, error)
+	DeleteByUser(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
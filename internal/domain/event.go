@@ -0,0 +1,68 @@
+package domain
+
+type EventType string
+
+const (
+	EventReady         EventType = "READY"
+	EventChannelCreate EventType = "CHANNEL_CREATE"
+	EventChannelUpdate EventType = "CHANNEL_UPDATE"
+	EventChannelDelete EventType = "CHANNEL_DELETE"
+	EventMessageCreate EventType = "MESSAGE_CREATE"
+	EventMessageUpdate EventType = "MESSAGE_UPDATE"
+	EventMessageDelete EventType = "MESSAGE_DELETE"
+	EventMemberUpdate  EventType = "MEMBER_UPDATE"
+
+	EventRelationshipAdd    EventType = "RELATIONSHIP_ADD"
+	EventRelationshipRemove EventType = "RELATIONSHIP_REMOVE"
+)
+
+// GatewayIntent subscribes a bot's gateway connection to a group of events.
+// Bots declare their intents when connecting and only receive the events of
+// those; users receive every event.
+type GatewayIntent string
+
+const (
+	IntentChannels      GatewayIntent = "channels"
+	IntentMessages      GatewayIntent = "messages"
+	IntentRelationships GatewayIntent = "relationships"
+)
+
+func (i GatewayIntent) Valid() bool {
+	switch i {
+	case IntentChannels, IntentMessages, IntentRelationships:
+		return true
+	}
+	return false
+}
+
+// Intent returns the intent that subscribes to the event, or an empty intent
+// for events every connection receives, such as READY.
+func (t EventType) Intent() GatewayIntent {
+	switch t {
+	case EventChannelCreate, EventChannelUpdate, EventChannelDelete:
+		return IntentChannels
+	case EventMessageCreate, EventMessageUpdate, EventMessageDelete:
+		return IntentMessages
+	case EventRelationshipAdd, EventRelationshipRemove:
+		return IntentRelationships
+	}
+	return ""
+}
+
+// Event is a real-time notification pushed to connected clients. Data holds
+// the domain object the event is about.
+type Event struct {
+	Type EventType
+	Data any
+}
+
+type MessageDeleteEvent struct {
+	ID        string
+	ChannelID string
+}
+
+// EventPublisher delivers events to the connected sessions of the given
+// users. Delivery is best effort: offline users simply miss the event.
+type EventPublisher interface {
+	Publish(userIDs []string, event Event)
+}
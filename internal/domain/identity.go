@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// UserIdentity links a user to their account at an external identity source,
+// such as an OpenID Connect provider or an LDAP directory.
+type UserIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    string
+	Email     string
+	CreatedAt time.Time
+}
+
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	GetIdentitiesByProvider(ctx context.Context, provider string) ([]UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *UserIdentity) error
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Invite struct {
+	Code      string     `json:"code"`
+	CreatorID string     `json:"creatorId"`
+	ChannelID string     `json:"channelId,omitempty"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	Temporary bool       `json:"temporary"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// Usable reports whether the invite can still be redeemed at the given time.
+func (i *Invite) Usable(now time.Time) bool {
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+type InviteRepository interface {
+	Create(ctx context.Context, invite *Invite) error
+	GetAll(ctx context.Context) ([]Invite, error)
+	GetByCode(ctx context.Context, code string) (*Invite, error)
+	// Use atomically consumes one use of the invite, returning false when the
+	// invite is expired or has no uses left.
+	Use(ctx context.Context, code string, now time.Time) (bool, error)
+	Delete(ctx context.Context, code string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttempts counts the recent failed logins for an account or an IP
+// address, identified by Key. Attempts are counted as failures before the
+// credentials are checked, so Failures includes logins still in progress.
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil is set while logins are refused outright.
+	LockedUntil *time.Time
+}
+
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*LoginAttempts, error)
+	// Reserve counts a login attempt at now, provided the attempts are still
+	// the seen ones (nil when none were tracked), and reports whether it did.
+	// Failures recorded before resetBefore are forgotten first.
+	Reserve(ctx context.Context, key string, seen *LoginAttempts, now, resetBefore time.Time) (bool, error)
+	// Release gives back an attempt counted by Reserve. LastFailureAt is
+	// left as it is, since earlier failures may have set it.
+	Release(ctx context.Context, key string) error
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Delete forgets the attempts for the keys and returns how many were
+	// tracked.
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	// DeleteStale forgets attempts whose last failure is older than before
+	// and whose lock, if any, has ended.
+	DeleteStale(ctx context.Context, before, now time.Time) (int64, error)
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "context"
+
+// Mail is a plain text email.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Member struct {
+	UserID     string `json:"userId"`
+	InviteCode string `json:"inviteCode,omitempty"`
+	// Temporary members joined through a temporary invite and are removed
+	// once they are no longer connected to the gateway.
+	Temporary     bool       `json:"temporary"`
+	TimedOutUntil *time.Time `json:"timedOutUntil,omitempty"`
+	JoinedAt      time.Time  `json:"joinedAt"`
+}
+
+// TimedOut reports whether the member is prevented from communicating at the
+// given time.
+func (m *Member) TimedOut(now time.Time) bool {
+	return m.TimedOutUntil != nil && now.Before(*m.TimedOutUntil)
+}
+
+type MemberRepository interface {
+	Create(ctx context.Context, member *Member) error
+	GetByUserID(ctx context.Context, userID string) (*Member, error)
+	GetTimedOut(ctx context.Context, now time.Time) ([]Member, error)
+	// GetTemporary returns the temporary members who joined before the given
+	// time.
+	GetTemporary(ctx context.Context, joinedBefore time.Time) ([]Member, error)
+	SetTimeout(ctx context.Context, userID string, until *time.Time) error
+	ClearExpiredTimeouts(ctx context.Context, now time.Time) (int64, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// PresenceTracker reports whether a user has a connection open to the
+// gateway.
+type PresenceTracker interface {
+	Online(userID string) bool
+}
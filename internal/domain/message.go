@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type MessageType string
+
+const (
+	MessageTypeDefault      MessageType = "default"
+	MessageTypeAutoModAlert MessageType = "automod_alert"
+
+	// Group DM system messages are authored by the user who made the change.
+	// Their content holds the affected user ID, or the new name or icon.
+	MessageTypeRecipientAdd      MessageType = "recipient_add"
+	MessageTypeRecipientRemove   MessageType = "recipient_remove"
+	MessageTypeChannelNameChange MessageType = "channel_name_change"
+	MessageTypeChannelIconChange MessageType = "channel_icon_change"
+	MessageTypeOwnerChange       MessageType = "owner_change"
+)
+
+type Message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channelId"`
+	// AuthorID is empty for messages generated by the server or posted
+	// through a webhook.
+	AuthorID string      `json:"authorId"`
+	Type     MessageType `json:"type"`
+	Content  string      `json:"content"`
+	Embeds   []Embed     `json:"embeds,omitempty"`
+	// Webhook is set on messages posted through an incoming webhook.
+	Webhook   *MessageWebhook `json:"webhook,omitempty"`
+	EditedAt  *time.Time      `json:"editedAt,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	// Blocked is set when the author is blocked by the user the message is
+	// shown to. Its content is then withheld.
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+// MessageWebhook identifies the webhook a message was posted through, along
+// with the name and avatar it was posted under.
+type MessageWebhook struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+}
+
+// Embed is a block of rich content attached to a message.
+type Embed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	// Color is the RGB color of the embed's border, or zero for the default.
+	Color  int          `json:"color,omitempty"`
+	Fields []EmbedField `json:"fields,omitempty"`
+	Footer string       `json:"footer,omitempty"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Hide withholds the message content from a viewer who blocked its author.
+func (m *Message) Hide() {
+	m.Content = ""
+	m.Embeds = nil
+	m.Blocked = true
+}
+
+type MessageQuery struct {
+	ChannelID string
+	// Before is the ID of the message to page back from.
+	Before string
+	Limit  int
+}
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *Message) error
+	Find(ctx context.Context, query MessageQuery) ([]Message, error)
+	GetByID(ctx context.Context, id string) (*Message, error)
+	// CountByAuthorSince counts the author's messages with the given content
+	// posted after since, across all channels.
+	CountByAuthorSince(ctx context.Context, authorID, content string, since time.Time) (int, error)
+	// DeleteByAuthorSince deletes the messages the author posted in
+	// community channels after since. Direct messages are kept.
+	DeleteByAuthorSince(ctx context.Context, authorID string, since time.Time) (int64, error)
+	Update(ctx context.Context, message *Message) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TOTPCredential is a user's authenticator app secret. It only protects the
+// account once Enabled, after the user proved they set it up by entering a
+// first code.
+type TOTPCredential struct {
+	UserID  string
+	Secret  string
+	Enabled bool
+	// LastUsedStep is the time step of the last accepted code. Codes from
+	// that step or earlier are rejected so that they cannot be replayed.
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// MFAChallenge is issued by a login that passed the password check but still
+// needs a second factor. Only the hash of its token is stored.
+type MFAChallenge struct {
+	TokenHash string
+	UserID    string
+	Attempts  int
+	// PasskeyState is the state of the passkey assertion started for the
+	// challenge, if any.
+	PasskeyState []byte
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userID string) (*TOTPCredential, error)
+	// PutTOTP creates or replaces the user's credential.
+	PutTOTP(ctx context.Context, credential *TOTPCredential) error
+	// UseTOTPStep records step as used, returning false when a code from the
+	// same or a later step was already accepted.
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID string) error
+
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores the
+	// given hashes instead.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string, now time.Time) error
+	// UseRecoveryCode marks the code as used, returning false when it does not
+	// exist or was already used.
+	UseRecoveryCode(ctx context.Context, userID, hash string, now time.Time) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	DeleteRecoveryCodes(ctx context.Context, userID string) error
+
+	CreateChallenge(ctx context.Context, challenge *MFAChallenge) error
+	GetChallenge(ctx context.Context, hash string) (*MFAChallenge, error)
+	// UseChallengeAttempt counts an attempt at completing the challenge,
+	// returning false when it does not exist or already had max attempts.
+	UseChallengeAttempt(ctx context.Context, hash string, max int) (bool, error)
+	SetChallengePasskeyState(ctx context.Context, hash string, state []byte) error
+	// DeleteChallenge returns false when the challenge was already gone.
+	DeleteChallenge(ctx context.Context, hash string) (bool, error)
+	DeleteExpiredChallenges(ctx context.Context, now time.Time) (int64, error)
+}
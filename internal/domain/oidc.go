@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ExternalIdentity is what an OpenID Connect provider asserts about the user
+// who just authenticated with it.
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// IdentityProvider runs the authorization code flow with PKCE against an
+// OpenID Connect provider.
+type IdentityProvider interface {
+	// AuthCodeURL returns the URL to send the user to. The code verifier is
+	// only sent as its S256 challenge.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange redeems the code returned to the redirect URL and verifies the
+	// ID token, including its nonce.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error)
+}
+
+// OIDCState is kept between sending a user to a provider and their return.
+// Only the hash of the state parameter is stored.
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	// UserID is set when a signed-in user is linking the provider to their
+	// account rather than signing in with it.
+	UserID    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type OIDCRepository interface {
+	CreateState(ctx context.Context, state *OIDCState) error
+	// TakeState returns the state and deletes it, so that each one can only
+	// be used once.
+	TakeState(ctx context.Context, hash string) (*OIDCState, error)
+	DeleteExpiredStates(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Passkey is a WebAuthn public key credential registered by a user. It can be
+// used to log in without a password or as a second factor.
+type Passkey struct {
+	// ID is the base64url-encoded credential ID chosen by the authenticator.
+	ID              string
+	UserID          string
+	Name            string
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	// SignCount is the signature counter last reported by the authenticator.
+	// A counter that does not increase hints at a cloned authenticator.
+	SignCount  uint32
+	Transports []string
+	// BackupEligible and BackupState tell whether the passkey can be, and
+	// currently is, synced across devices by its provider.
+	BackupEligible bool
+	BackupState    bool
+	LastUsedAt     *time.Time
+	CreatedAt      time.Time
+}
+
+type PasskeyCeremonyType string
+
+const (
+	PasskeyCeremonyRegistration PasskeyCeremonyType = "registration"
+	PasskeyCeremonyLogin        PasskeyCeremonyType = "login"
+)
+
+// PasskeyCeremony keeps the server side state of a WebAuthn ceremony between
+// the options sent to the client and its response. Only the hash of the token
+// identifying it is stored.
+type PasskeyCeremony struct {
+	TokenHash string
+	Type      PasskeyCeremonyType
+	// UserID is empty for logins, where the user is only known once the
+	// authenticator answered.
+	UserID    string
+	State     []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type PasskeyRepository interface {
+	Create(ctx context.Context, passkey *Passkey) error
+	GetByID(ctx context.Context, id string) (*Passkey, error)
+	GetByUser(ctx context.Context, userID string) ([]Passkey, error)
+	// UpdateUsage records a successful assertion made with the passkey.
+	UpdateUsage(ctx context.Context, id string, signCount uint32, backupState bool, now time.Time) error
+	Delete(ctx context.Context, id string) error
+	DeleteByUser(ctx context.Context, userID string) error
+
+	CreateCeremony(ctx context.Context, ceremony *PasskeyCeremony) error
+	// TakeCeremony returns the ceremony and deletes it, so that each one can
+	// only be finished once.
+	TakeCeremony(ctx context.Context, hash string) (*PasskeyCeremony, error)
+	DeleteExpiredCeremonies(ctx context.Context, now time.Time) (int64, error)
+}
+
+// PasskeyLookup returns the user with the given ID along with their passkeys,
+// or a nil user when there is none.
+type PasskeyLookup func(userID string) (*User, []Passkey, error)
+
+// PasskeyProvider runs the WebAuthn registration and assertion ceremonies.
+// The Begin methods return the options to hand to the browser and an opaque
+// state that must be passed back to the matching Finish method along with the
+// browser's response.
+type PasskeyProvider interface {
+	// BeginRegistration excludes the user's existing passkeys so that an
+	// authenticator is not registered twice.
+	BeginRegistration(user *User, existing []Passkey) (json.RawMessage, []byte, error)
+	FinishRegistration(user *User, state, response []byte) (*Passkey, error)
+	// BeginLogin asks for an assertion from one of the user's passkeys. When
+	// user is nil the authenticator picks a discoverable passkey by itself,
+	// and user verification is required as the passkey is the only factor.
+	BeginLogin(user *User, passkeys []Passkey) (json.RawMessage, []byte, error)
+	// FinishLogin verifies the assertion and returns the passkey that made it
+	// with its new sign counter and backup state.
+	FinishLogin(state, response []byte, lookup PasskeyLookup) (*Passkey, error)
+}
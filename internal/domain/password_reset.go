@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordReset is a single-use token letting a user choose a new password.
+// Only the hash of the token is stored.
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *PasswordReset) error
+	GetByHash(ctx context.Context, hash string) (*PasswordReset, error)
+	// Use atomically consumes the token, returning false when it was already
+	// used or has expired.
+	Use(ctx context.Context, hash string, now time.Time) (bool, error)
+	DeleteByUser(ctx context.Context, userID string) error
+	// DeleteExpired removes tokens that expired or were used before now.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
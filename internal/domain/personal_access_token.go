@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so that they
+// can be told apart from session tokens and found by secret scanners.
+const PersonalAccessTokenPrefix = "hpat_"
+
+// TokenScope grants a personal access token access to a set of routes.
+type TokenScope string
+
+const (
+	ScopeMessagesRead   TokenScope = "messages.read"
+	ScopeMessagesWrite  TokenScope = "messages.write"
+	ScopeChannelsManage TokenScope = "channels.manage"
+	// ScopeAdmin grants the administration routes to tokens of
+	// administrators.
+	ScopeAdmin TokenScope = "admin"
+)
+
+func (s TokenScope) Valid() bool {
+	switch s {
+	case ScopeMessagesRead, ScopeMessagesWrite, ScopeChannelsManage, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// PersonalAccessToken lets scripts and integrations call the API on behalf of
+// a user without their password. Only the hash of the token is stored.
+type PersonalAccessToken struct {
+	ID        string
+	UserID    string
+	Name      string
+	TokenHash string
+	Scopes    []TokenScope
+	// ExpiresAt is nil for tokens that never expire.
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *PersonalAccessToken) HasScope(scope TokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *PersonalAccessToken) error
+	GetByID(ctx context.Context, id string) (*PersonalAccessToken, error)
+	GetByHash(ctx context.Context, hash string) (*PersonalAccessToken, error)
+	GetByUser(ctx context.Context, userID string) ([]PersonalAccessToken, error)
+	UpdateLastUsed(ctx context.Context, id string, now time.Time) error
+	Delete(ctx context.Context, id string) error
+	DeleteByUser(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
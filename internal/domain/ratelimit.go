@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket holding Burst requests, refilled evenly so that
+// it fills up again over Period. The zero value allows every request.
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<burst>/<period>", such as
+// "30/1m", or "off" for no limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return RateLimit{}, nil
+	}
+
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q should be in \"<burst>/<period>\" format", s)
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 1 {
+		return RateLimit{}, fmt.Errorf("rate limit %q should allow at least one request", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q should have a positive period", s)
+	}
+	return RateLimit{Burst: n, Period: d}, nil
+}
+
+// Unlimited reports whether the limit allows every request.
+func (l RateLimit) Unlimited() bool {
+	return l.Burst <= 0
+}
+
+func (l RateLimit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// RateLimitResult is the state of a bucket after taking a request from it.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is how many more requests the bucket allows right away.
+	Remaining int
+	// ResetAfter is how long the bucket takes to fill up again.
+	ResetAfter time.Duration
+	// RetryAfter is how long to wait before a denied request may be retried.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds token buckets. Instances sharing a store share their
+// buckets.
+type RateLimitStore interface {
+	// Take takes a request from the bucket stored under key, creating it
+	// full when it does not exist.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type RelationshipType string
+
+const (
+	RelationshipFriend          RelationshipType = "friend"
+	RelationshipBlocked         RelationshipType = "blocked"
+	RelationshipPendingIncoming RelationshipType = "pending_incoming"
+	RelationshipPendingOutgoing RelationshipType = "pending_outgoing"
+)
+
+// Relationship is how UserID relates to TargetID. Friendships and pending
+// requests are stored once for each side; a block only on the blocker's side.
+type Relationship struct {
+	UserID    string           `json:"userId"`
+	TargetID  string           `json:"targetId"`
+	Type      RelationshipType `json:"type"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type RelationshipRepository interface {
+	// Put stores the relationship, replacing any previous one between the
+	// same users in the same direction.
+	Put(ctx context.Context, relationship *Relationship) error
+	// Get returns nil when the user has no relationship with the target.
+	Get(ctx context.Context, userID, targetID string) (*Relationship, error)
+	GetByUser(ctx context.Context, userID string, types ...RelationshipType) ([]Relationship, error)
+	Delete(ctx context.Context, userID, targetID string) error
+}
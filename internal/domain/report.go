@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type ReportTargetType string
+
+const (
+	ReportTargetMessage ReportTargetType = "message"
+	ReportTargetUser    ReportTargetType = "user"
+)
+
+type ReportCategory string
+
+const (
+	ReportCategorySpam       ReportCategory = "spam"
+	ReportCategoryHarassment ReportCategory = "harassment"
+	ReportCategoryHate       ReportCategory = "hate"
+	ReportCategoryNSFW       ReportCategory = "nsfw"
+	ReportCategoryViolence   ReportCategory = "violence"
+	ReportCategoryOther      ReportCategory = "other"
+)
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusEscalated ReportStatus = "escalated"
+	ReportStatusResolved  ReportStatus = "resolved"
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+// Closed reports whether the report has left the moderation queue.
+func (s ReportStatus) Closed() bool {
+	return s == ReportStatusResolved || s == ReportStatusDismissed
+}
+
+// ReportSnapshot preserves the reported content as it was when the report
+// was filed, so that later edits or deletions do not affect the review.
+type ReportSnapshot struct {
+	AuthorID string `json:"authorId"`
+	// WebhookID is set instead of AuthorID for messages posted by a webhook,
+	// and Username then holds the name the message was posted under.
+	WebhookID string    `json:"webhookId,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Report struct {
+	ID         string           `json:"id"`
+	ReporterID string           `json:"reporterId"`
+	TargetType ReportTargetType `json:"targetType"`
+	// TargetUserID is empty for reports about webhook messages.
+	TargetUserID string         `json:"targetUserId"`
+	MessageID    string         `json:"messageId,omitempty"`
+	ChannelID    string         `json:"channelId,omitempty"`
+	Category     ReportCategory `json:"category"`
+	Reason       string         `json:"reason"`
+	Snapshot     ReportSnapshot `json:"snapshot"`
+	Status       ReportStatus   `json:"status"`
+	ModeratorID  string         `json:"moderatorId,omitempty"`
+	Note         string         `json:"note,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+}
+
+type ReportRepository interface {
+	Create(ctx context.Context, report *Report) error
+	GetByID(ctx context.Context, id string) (*Report, error)
+	GetByStatus(ctx context.Context, statuses ...ReportStatus) ([]Report, error)
+	// HasOpen reports whether the reporter already has a report in the queue
+	// for the same user or message.
+	HasOpen(ctx context.Context, reporterID, targetUserID, messageID string) (bool, error)
+	Update(ctx context.Context, report *Report) error
+}
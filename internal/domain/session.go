@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Session is a login on one device. Every refresh token issued for it belongs
+// to the same family, so revoking the session invalidates all of them.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Device     string     `json:"device"`
+	IPAddress  string     `json:"ipAddress"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// ClientInfo describes the client a request came from. It is recorded on the
+// session so that users can recognise their devices.
+type ClientInfo struct {
+	Device    string
+	IPAddress string
+}
+
+// RefreshTokenRecord records a refresh token issued for a session. Only its hash is
+// stored. A token is used once: refreshing rotates it for a new one.
+type RefreshTokenRecord struct {
+	Hash      string
+	SessionID string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	GetByID(ctx context.Context, id string) (*Session, error)
+	// GetActiveByUser returns the user's sessions that are neither revoked nor
+	// expired at now, most recently used first.
+	GetActiveByUser(ctx context.Context, userID string, now time.Time) ([]Session, error)
+	// Touch records that the session was just used from ipAddress and extends
+	// its expiry.
+	Touch(ctx context.Context, id, ipAddress string, now, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string, now time.Time) error
+	// DeleteExpired removes sessions that expired or were revoked before now,
+	// along with their refresh tokens.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+
+	CreateRefreshToken(ctx context.Context, token *RefreshTokenRecord) error
+	GetRefreshToken(ctx context.Context, hash string) (*RefreshTokenRecord, error)
+	// UseRefreshToken marks the token as used. It returns false when the token
+	// had already been used, which means it is being replayed.
+	UseRefreshToken(ctx context.Context, hash string, now time.Time) (bool, error)
+}
+
+// SessionTerminator closes the real-time connections that were opened with a
+// session's access tokens, so that revoking it takes effect immediately.
+type SessionTerminator interface {
+	TerminateSession(sessionID string)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// SigningKey is a private key tokens are signed with. Only the newest key
+// signs new tokens; retired keys are kept to verify the tokens they signed
+// until the last of them expires.
+type SigningKey struct {
+	// ID is sent as the kid header of the tokens the key signs.
+	ID        string
+	Algorithm string
+	// PrivateKey is PKCS #8 DER.
+	PrivateKey []byte
+	CreatedAt  time.Time
+	// RetiredAt is when a newer key replaced this one, and ExpiresAt when the
+	// last token it signed expires. Both are nil for the active key.
+	RetiredAt *time.Time
+	ExpiresAt *time.Time
+}
+
+type SigningKeyRepository interface {
+	// GetSigningKeys returns the keys that have not expired, newest first.
+	GetSigningKeys(ctx context.Context, now time.Time) ([]SigningKey, error)
+	// RotateSigningKey retires the active key, if any, so that it expires at
+	// expiresAt, and makes key the active one.
+	RotateSigningKey(ctx context.Context, key *SigningKey, expiresAt time.Time) error
+	DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int64, error)
+}
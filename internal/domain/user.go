@@ -5,20 +5,68 @@ import (
 	"time"
 )
 
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusPending  UserStatus = "pending"
+	UserStatusDisabled UserStatus = "disabled"
+)
+
+// DMPolicy controls who may open a direct message with a user.
+type DMPolicy string
+
+const (
+	DMPolicyEveryone DMPolicy = "everyone"
+	// DMPolicyMembers only accepts direct messages from members of the
+	// community.
+	DMPolicyMembers DMPolicy = "members"
+	DMPolicyFriends DMPolicy = "friends"
+)
+
+func (p DMPolicy) Valid() bool {
+	switch p {
+	case DMPolicyEveryone, DMPolicyMembers, DMPolicyFriends:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string     `json:"id"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	Password  string     `json:"-"`
+	IsAdmin   bool       `json:"isAdmin"`
+	Status    UserStatus `json:"status"`
+	DMPolicy  DMPolicy   `json:"dmPolicy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	// EmailVerified reports whether the user proved they own Email.
+	EmailVerified bool `json:"emailVerified"`
+	// PendingEmail is the address the user asked to switch to. Email stays
+	// in use until the new one is verified.
+	PendingEmail string `json:"pendingEmail,omitempty"`
+	// IsBot reports whether the account is a bot, which authenticates with a
+	// bot token instead of a password.
+	IsBot bool `json:"bot"`
+	// TokenVersion is embedded in every token issued to the user. Bumping it
+	// revokes all of them at once.
+	TokenVersion int `json:"-"`
 }
 
 type UserRepository interface {
 	Create(ctx context.Context, user *User) error
+	// CreateFirst creates the user only when there are no users yet, and
+	// reports whether it did.
+	CreateFirst(ctx context.Context, user *User) (bool, error)
 	GetAll(ctx context.Context) ([]User, error)
+	GetByStatus(ctx context.Context, status UserStatus) ([]User, error)
+	Count(ctx context.Context) (int, error)
 	GetByID(ctx context.Context, id string) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	Update(ctx context.Context, user *User) error
+	// UpdatePassword replaces the user's password hash.
+	UpdatePassword(ctx context.Context, id, hash string) error
 	Delete(ctx context.Context, id string) error
 }
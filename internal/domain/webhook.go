@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Webhook lets external systems post messages into a channel with a secret
+// token instead of an account. Only the hash of the token is stored.
+type Webhook struct {
+	ID        string
+	ChannelID string
+	// Name and AvatarURL are what messages are posted under, unless the
+	// payload overrides them.
+	Name      string
+	AvatarURL string
+	TokenHash string
+	// CreatorID is empty once the user who created the webhook was deleted.
+	CreatorID string
+	CreatedAt time.Time
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	GetByID(ctx context.Context, id string) (*Webhook, error)
+	GetByChannel(ctx context.Context, channelID string) ([]Webhook, error)
+	UpdateToken(ctx context.Context, id, hash string) error
+	Delete(ctx context.Context, id string) error
+}